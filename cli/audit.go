@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// auditQuery holds the filters of the audit command
+type auditQuery struct {
+	action   string
+	clientID string
+	actor    string
+	since    string
+	until    string
+	limit    int
+	cursor   string
+	output   string
+}
+
+// auditEntry is one entry as returned by GET /audit
+type auditEntry struct {
+	ID         uint64            `json:"id"`
+	Time       time.Time         `json:"time"`
+	Action     string            `json:"action"`
+	Result     string            `json:"result"`
+	Actor      string            `json:"actor,omitempty"`
+	Method     string            `json:"method,omitempty"`
+	Path       string            `json:"path,omitempty"`
+	StatusCode int               `json:"status_code,omitempty"`
+	ClientID   string            `json:"client_id,omitempty"`
+	UploadID   string            `json:"upload_id,omitempty"`
+	S3Key      string            `json:"s3_key,omitempty"`
+	Details    map[string]string `json:"details,omitempty"`
+}
+
+// auditList is the response of GET /audit
+type auditList struct {
+	Entries    []auditEntry `json:"entries"`
+	Count      int          `json:"count"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
+func listAudit(serverURL string, query auditQuery) {
+	params := url.Values{}
+	if query.action != "" {
+		params.Set("action", query.action)
+	}
+	if query.clientID != "" {
+		params.Set("client_id", query.clientID)
+	}
+	if query.actor != "" {
+		params.Set("actor", query.actor)
+	}
+	if query.since != "" {
+		params.Set("since", query.since)
+	}
+	if query.until != "" {
+		params.Set("until", query.until)
+	}
+	if query.output == "jsonl" {
+		// The server streams every matching entry
+		params.Set("format", "jsonl")
+	} else {
+		if query.limit > 0 {
+			params.Set("limit", strconv.Itoa(query.limit))
+		}
+		if query.cursor != "" {
+			params.Set("cursor", query.cursor)
+		}
+	}
+
+	listURL := fmt.Sprintf("%s/audit", serverURL)
+	if len(params) > 0 {
+		listURL += "?" + params.Encode()
+	}
+
+	resp, err := http.Get(listURL)
+	if err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if query.output == "jsonl" {
+		if resp.StatusCode != http.StatusOK {
+			readResponse(resp, http.StatusOK, nil)
+		}
+		if _, err := io.Copy(os.Stdout, resp.Body); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Error writing output: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Printf("❌ Error reading response: %v\n", err)
+		os.Exit(1)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		fmt.Printf("❌ Server returned error (status %d):\n", resp.StatusCode)
+		fmt.Println(string(body))
+		os.Exit(1)
+	}
+
+	var result auditList
+	if err := json.Unmarshal(body, &result); err != nil {
+		fmt.Printf("❌ Error parsing response: %v\n", err)
+		fmt.Println(string(body))
+		os.Exit(1)
+	}
+
+	if query.output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(json.RawMessage(body)); err != nil {
+			fmt.Printf("❌ Error writing output: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Printf("📜 Audit entries: %d\n", result.Count)
+	fmt.Printf("🔗 Server: %s\n\n", serverURL)
+
+	if len(result.Entries) == 0 {
+		fmt.Println("   (no audit entries found)")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTIME\tACTION\tRESULT\tACTOR\tCLIENT\tSUBJECT")
+	for _, entry := range result.Entries {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			entry.ID,
+			entry.Time.Local().Format("2006-01-02 15:04:05"),
+			entry.Action,
+			entry.Result,
+			entry.Actor,
+			entry.ClientID,
+			auditSubject(entry),
+		)
+	}
+	w.Flush()
+
+	if result.NextCursor != "" {
+		fmt.Printf("\n➡️  More entries available: --cursor=%s\n", result.NextCursor)
+	}
+}
+
+// auditSubject summarises what an entry acted on
+func auditSubject(entry auditEntry) string {
+	switch {
+	case entry.Method != "":
+		return fmt.Sprintf("%s %s (%d)", entry.Method, entry.Path, entry.StatusCode)
+	case entry.S3Key != "":
+		return entry.S3Key
+	case entry.Details["schedule_id"] != "":
+		return "schedule " + entry.Details["schedule_id"]
+	case entry.Details["duration"] != "":
+		return "after " + entry.Details["duration"]
+	}
+	return entry.UploadID
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// batchInfo is a batch as returned by the server
+type batchInfo struct {
+	BatchID  string         `json:"batch_id"`
+	FilePath string         `json:"file_path"`
+	Status   string         `json:"status"`
+	Total    int            `json:"total"`
+	Counts   map[string]int `json:"counts"`
+	Progress float64        `json:"progress"`
+	Items    []struct {
+		ClientID string `json:"client_id"`
+		UploadID string `json:"upload_id"`
+		State    string `json:"state"`
+		Error    string `json:"error"`
+	} `json:"items"`
+}
+
+func triggerBulkDownload(serverURL string, all bool, selector, filePath string) {
+	request := map[string]interface{}{}
+	if filePath != "" {
+		request["file_path"] = filePath
+	}
+
+	if all {
+		fmt.Println("📥 Triggering download on all connected clients")
+		request["selector"] = map[string]interface{}{"all": true}
+	} else {
+		labels, err := parseLabels(selector)
+		if err != nil {
+			fmt.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("📥 Triggering download on clients matching: %s\n", selector)
+		request["selector"] = map[string]interface{}{"labels": labels}
+	}
+	fmt.Printf("🔗 Server: %s\n", serverURL)
+
+	data, err := json.Marshal(request)
+	if err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	resp, err := http.Post(fmt.Sprintf("%s/trigger-download", serverURL), "application/json", bytes.NewReader(data))
+	if err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	info := readBatch(resp)
+	fmt.Println("\n✅ Batch started!")
+	fmt.Printf("   Batch ID: %s\n", info.BatchID)
+	fmt.Printf("   Clients: %d\n", info.Total)
+	fmt.Printf("   Follow it with: cli batch --batch-id=%s\n", info.BatchID)
+}
+
+func checkBatch(serverURL, batchID string) {
+	fmt.Printf("📦 Checking batch: %s\n", batchID)
+	fmt.Printf("🔗 Server: %s\n", serverURL)
+
+	resp, err := http.Get(fmt.Sprintf("%s/batches/%s", serverURL, batchID))
+	if err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	info := readBatch(resp)
+
+	fmt.Printf("\n📦 Batch %s: %s (%.1f%%)\n", info.BatchID, info.Status, info.Progress)
+	fmt.Printf("   File: %s\n", info.FilePath)
+
+	states := make([]string, 0, len(info.Counts))
+	for state := range info.Counts {
+		states = append(states, state)
+	}
+	sort.Strings(states)
+	counts := make([]string, 0, len(states))
+	for _, state := range states {
+		counts = append(counts, fmt.Sprintf("%s=%d", state, info.Counts[state]))
+	}
+	fmt.Printf("   Clients: %d (%s)\n\n", info.Total, strings.Join(counts, ", "))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLIENT\tUPLOAD ID\tSTATE\tERROR")
+	for _, item := range info.Items {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", item.ClientID, item.UploadID, item.State, item.Error)
+	}
+	w.Flush()
+}
+
+// readBatch parses a batch response, exiting on errors
+func readBatch(resp *http.Response) batchInfo {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Printf("❌ Error reading response: %v\n", err)
+		os.Exit(1)
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		fmt.Printf("❌ Server returned error (status %d):\n", resp.StatusCode)
+		fmt.Println(string(body))
+		os.Exit(1)
+	}
+
+	var info batchInfo
+	if err := json.Unmarshal(body, &info); err != nil {
+		fmt.Printf("❌ Error parsing response: %v\n", err)
+		fmt.Println(string(body))
+		os.Exit(1)
+	}
+	return info
+}
+
+// parseLabels parses key=value pairs separated by commas
+func parseLabels(selector string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(selector, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid label %q, expected key=value", pair)
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
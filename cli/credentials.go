@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// profile holds the server and credentials for one environment in the
+// profile file
+type profile struct {
+	ServerURL string `json:"server_url,omitempty"`
+	APIKey    string `json:"api_key,omitempty"`
+	Token     string `json:"token,omitempty"` // Operator JWT, used if there is no API key
+}
+
+// profileFile is the JSON file at CLI_PROFILE_FILE, by default
+// ~/.file-download-cli.json
+type profileFile struct {
+	Profiles map[string]profile `json:"profiles"`
+}
+
+// loadProfile picks the CLI_PROFILE profile (default "default") from the
+// profile file and overrides it with SERVER_URL, API_KEY and API_TOKEN
+func loadProfile() (profile, error) {
+	name := os.Getenv("CLI_PROFILE")
+	path := os.Getenv("CLI_PROFILE_FILE")
+	if path == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, ".file-download-cli.json")
+		}
+	}
+
+	var selected profile
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			// Without a profile file only the environment counts
+			if name != "" {
+				return profile{}, fmt.Errorf("profile %s requested but %s does not exist", name, path)
+			}
+		case err != nil:
+			return profile{}, fmt.Errorf("failed to read %s: %w", path, err)
+		default:
+			var file profileFile
+			if err := json.Unmarshal(data, &file); err != nil {
+				return profile{}, fmt.Errorf("failed to parse %s: %w", path, err)
+			}
+			if name == "" {
+				name = "default"
+			}
+			p, exists := file.Profiles[name]
+			if !exists && os.Getenv("CLI_PROFILE") != "" {
+				return profile{}, fmt.Errorf("profile %s not found in %s", name, path)
+			}
+			selected = p
+		}
+	}
+
+	if serverURL := os.Getenv("SERVER_URL"); serverURL != "" {
+		selected.ServerURL = serverURL
+	}
+	switch {
+	case os.Getenv("API_KEY") != "":
+		selected.APIKey, selected.Token = os.Getenv("API_KEY"), ""
+	case os.Getenv("API_TOKEN") != "":
+		selected.APIKey, selected.Token = "", os.Getenv("API_TOKEN")
+	}
+	if selected.ServerURL == "" {
+		selected.ServerURL = "http://localhost:8080"
+	}
+	return selected, nil
+}
+
+// credentialTransport adds the operator's credentials to every request
+type credentialTransport struct {
+	credential string
+	base       http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *credentialTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.credential)
+	return t.base.RoundTrip(req)
+}
+
+// useCredentials makes the default HTTP client, used by every command, send
+// the profile's API key or token
+func useCredentials(p profile) {
+	credential := p.APIKey
+	if credential == "" {
+		credential = p.Token
+	}
+	if credential == "" {
+		return
+	}
+	http.DefaultClient.Transport = &credentialTransport{
+		credential: credential,
+		base:       http.DefaultTransport,
+	}
+}
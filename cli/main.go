@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -22,19 +23,62 @@ func main() {
 	var (
 		command   string
 		clientID  string
+		uploadID  string
+		batchID   string
 		serverURL string
+		all       bool
+		selector  string
+		filePath  string
+		queue     bool
+		queueTTL  string
 	)
 
 	// Subcommands
 	downloadCmd := flag.NewFlagSet("download", flag.ExitOnError)
-	downloadCmd.StringVar(&clientID, "client-id", "", "Client ID to trigger download from (required)")
+	downloadCmd.StringVar(&clientID, "client-id", "", "Client ID to trigger download from")
+	downloadCmd.BoolVar(&all, "all", false, "Trigger the download on every connected client")
+	downloadCmd.StringVar(&selector, "selector", "", "Trigger the download on clients with these labels (e.g. region=eu,tier=gold)")
+	downloadCmd.StringVar(&filePath, "file", "", "File to upload (server default if empty)")
+	downloadCmd.BoolVar(&queue, "queue", false, "Queue the download if the client is offline (--client-id only)")
+	downloadCmd.StringVar(&queueTTL, "queue-ttl", "", "How long a queued download waits for the client, e.g. 6h (server default if empty)")
+
+	batchCmd := flag.NewFlagSet("batch", flag.ExitOnError)
+	batchCmd.StringVar(&batchID, "batch-id", "", "Batch ID to check (required)")
 
 	statusCmd := flag.NewFlagSet("status", flag.ExitOnError)
 	statusCmd.StringVar(&clientID, "client-id", "", "Client ID to check status (required)")
 
 	listCmd := flag.NewFlagSet("list", flag.ExitOnError)
 
-	fmt.Println("🚀 File Download System - CLI")
+	cancelCmd := flag.NewFlagSet("cancel", flag.ExitOnError)
+	cancelCmd.StringVar(&uploadID, "upload-id", "", "Upload ID to cancel (required)")
+
+	watchCmd := flag.NewFlagSet("watch", flag.ExitOnError)
+	watchCmd.StringVar(&uploadID, "upload-id", "", "Upload ID to watch (required)")
+
+	var query uploadQuery
+	uploadsCmd := flag.NewFlagSet("uploads", flag.ExitOnError)
+	uploadsCmd.StringVar(&query.clientID, "client-id", "", "Only show uploads from this client")
+	uploadsCmd.StringVar(&query.state, "state", "", "Comma-separated states to show (e.g. in_progress,failed)")
+	uploadsCmd.StringVar(&query.since, "since", "", "Only show uploads started at or after this RFC 3339 time")
+	uploadsCmd.StringVar(&query.until, "until", "", "Only show uploads started before this RFC 3339 time")
+	uploadsCmd.IntVar(&query.limit, "limit", 0, "Maximum number of uploads to show (server default 50)")
+	uploadsCmd.StringVar(&query.cursor, "cursor", "", "Cursor from a previous page")
+	uploadsCmd.StringVar(&query.output, "output", "table", "Output format: table or json")
+
+	var audit auditQuery
+	auditCmd := flag.NewFlagSet("audit", flag.ExitOnError)
+	auditCmd.StringVar(&audit.action, "action", "", "Only show entries of this action (e.g. api.call, client.connected)")
+	auditCmd.StringVar(&audit.clientID, "client-id", "", "Only show entries about this client")
+	auditCmd.StringVar(&audit.actor, "actor", "", "Only show entries by this operator")
+	auditCmd.StringVar(&audit.since, "since", "", "Only show entries at or after this RFC 3339 time")
+	auditCmd.StringVar(&audit.until, "until", "", "Only show entries before this RFC 3339 time")
+	auditCmd.IntVar(&audit.limit, "limit", 0, "Maximum number of entries to show (server default 50)")
+	auditCmd.StringVar(&audit.cursor, "cursor", "", "Cursor from a previous page")
+	auditCmd.StringVar(&audit.output, "output", "table", "Output format: table, json or jsonl (every matching entry)")
+
+	// The banner goes to stderr so JSON output can be piped
+	fmt.Fprintln(os.Stderr, "🚀 File Download System - CLI")
 
 	if len(os.Args) < 2 {
 		printUsage()
@@ -43,20 +87,54 @@ func main() {
 
 	command = os.Args[1]
 
-	serverURL = os.Getenv("SERVER_URL")
-	if serverURL == "" {
-		serverURL = "http://localhost:8080"
+	// Server and credentials come from the environment or the profile file
+	operatorProfile, err := loadProfile()
+	if err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
 	}
+	serverURL = operatorProfile.ServerURL
+	useCredentials(operatorProfile)
 
 	switch command {
 	case "download":
 		downloadCmd.Parse(os.Args[2:])
-		if clientID == "" {
-			fmt.Println("❌ Error: --client-id is required")
+		targets := 0
+		for _, set := range []bool{clientID != "", all, selector != ""} {
+			if set {
+				targets++
+			}
+		}
+		if targets != 1 {
+			fmt.Println("❌ Error: exactly one of --client-id, --all or --selector is required")
+			downloadCmd.PrintDefaults()
+			os.Exit(1)
+		}
+		if (queue || queueTTL != "") && clientID == "" {
+			fmt.Println("❌ Error: --queue and --queue-ttl require --client-id")
 			downloadCmd.PrintDefaults()
 			os.Exit(1)
 		}
-		triggerDownload(serverURL, clientID)
+		if clientID != "" {
+			triggerDownload(serverURL, clientID, filePath, queue, queueTTL)
+		} else {
+			triggerBulkDownload(serverURL, all, selector, filePath)
+		}
+
+	case "batch":
+		batchCmd.Parse(os.Args[2:])
+		if batchID == "" {
+			fmt.Println("❌ Error: --batch-id is required")
+			batchCmd.PrintDefaults()
+			os.Exit(1)
+		}
+		checkBatch(serverURL, batchID)
+
+	case "schedule":
+		runSchedule(serverURL, os.Args[2:])
+
+	case "token":
+		runToken(serverURL, os.Args[2:])
 
 	case "status":
 		statusCmd.Parse(os.Args[2:])
@@ -71,6 +149,42 @@ func main() {
 		listCmd.Parse(os.Args[2:])
 		listClients(serverURL)
 
+	case "cancel":
+		cancelCmd.Parse(os.Args[2:])
+		if uploadID == "" {
+			fmt.Println("❌ Error: --upload-id is required")
+			cancelCmd.PrintDefaults()
+			os.Exit(1)
+		}
+		cancelUpload(serverURL, uploadID)
+
+	case "watch":
+		watchCmd.Parse(os.Args[2:])
+		if uploadID == "" {
+			fmt.Println("❌ Error: --upload-id is required")
+			watchCmd.PrintDefaults()
+			os.Exit(1)
+		}
+		watchUpload(serverURL, uploadID)
+
+	case "uploads":
+		uploadsCmd.Parse(os.Args[2:])
+		if query.output != "table" && query.output != "json" {
+			fmt.Println("❌ Error: --output must be table or json")
+			uploadsCmd.PrintDefaults()
+			os.Exit(1)
+		}
+		listUploads(serverURL, query)
+
+	case "audit":
+		auditCmd.Parse(os.Args[2:])
+		if audit.output != "table" && audit.output != "json" && audit.output != "jsonl" {
+			fmt.Println("❌ Error: --output must be table, json or jsonl")
+			auditCmd.PrintDefaults()
+			os.Exit(1)
+		}
+		listAudit(serverURL, audit)
+
 	default:
 		printUsage()
 		os.Exit(1)
@@ -79,22 +193,69 @@ func main() {
 
 func printUsage() {
 	fmt.Println("\nUsage:")
-	fmt.Println("  cli download --client-id=<client-id>")
+	fmt.Println("  cli download --client-id=<client-id> [--file=<path>] [--queue] [--queue-ttl=<duration>]")
+	fmt.Println("  cli download --all | --selector=<key=value,...> [--file=<path>]")
+	fmt.Println("  cli batch --batch-id=<batch-id>")
+	fmt.Println("  cli schedule list")
+	fmt.Println("  cli schedule create --cron=<expr> [--timezone=<zone>] --client-id=<ids> | --all | --selector=<key=value,...> [--file=<path>] [--jitter=<duration>] [--skip-missed] [--name=<name>]")
+	fmt.Println("  cli schedule delete --schedule-id=<schedule-id>")
+	fmt.Println("  cli token issue --client-id=<client-id> [--expires-in=<duration>]")
+	fmt.Println("  cli token revoke --token-id=<token-id> | --client-id=<client-id> [--reason=<reason>]")
+	fmt.Println("  cli token revocations")
+	fmt.Println("  cli token operator --name=<name> --role=viewer|operator|admin [--expires-in=<duration>]")
 	fmt.Println("  cli status --client-id=<client-id>")
 	fmt.Println("  cli list")
+	fmt.Println("  cli cancel --upload-id=<upload-id>")
+	fmt.Println("  cli watch --upload-id=<upload-id>")
+	fmt.Println("  cli uploads [--client-id=<id>] [--state=<states>] [--since=<time>] [--until=<time>] [--limit=<n>] [--cursor=<cursor>] [--output=table|json]")
+	fmt.Println("  cli audit [--action=<action>] [--client-id=<id>] [--actor=<name>] [--since=<time>] [--until=<time>] [--limit=<n>] [--cursor=<cursor>] [--output=table|json|jsonl]")
 	fmt.Println("\nExamples:")
 	fmt.Println("  cli download --client-id=restaurant-1")
+	fmt.Println("  cli download --client-id=restaurant-1 --queue --queue-ttl=12h")
+	fmt.Println("  cli download --all")
+	fmt.Println("  cli download --selector=region=eu")
+	fmt.Println("  cli batch --batch-id=batch_1a2b3c4d5e6f7a8b")
+	fmt.Println("  cli schedule create --cron=\"0 2 * * *\" --timezone=Europe/Berlin --selector=region=eu --jitter=10m")
+	fmt.Println("  cli token issue --client-id=restaurant-1 --expires-in=720h")
+	fmt.Println("  cli token revoke --client-id=restaurant-1 --reason=\"device stolen\"")
+	fmt.Println("  cli token operator --name=dashboard --role=viewer --expires-in=8h")
 	fmt.Println("  cli status --client-id=restaurant-1")
 	fmt.Println("  cli list")
+	fmt.Println("  cli cancel --upload-id=abc123")
+	fmt.Println("  cli watch --upload-id=abc123")
+	fmt.Println("  cli uploads --client-id=restaurant-1 --state=failed")
+	fmt.Println("  cli uploads --since=2024-01-01T00:00:00Z --output=json")
+	fmt.Println("  cli audit --action=api.call --actor=alice")
+	fmt.Println("  cli audit --since=2024-01-01T00:00:00Z --output=jsonl > audit.jsonl")
+	fmt.Println("\nCredentials:")
+	fmt.Println("  API_KEY or API_TOKEN, or the CLI_PROFILE profile (default \"default\") in CLI_PROFILE_FILE")
+	fmt.Println("  (default ~/.file-download-cli.json): {\"profiles\": {\"default\": {\"server_url\": \"...\", \"api_key\": \"...\"}}}")
 }
 
-func triggerDownload(serverURL, clientID string) {
+func triggerDownload(serverURL, clientID, filePath string, queue bool, queueTTL string) {
 	fmt.Printf("📥 Triggering download for client: %s\n", clientID)
 	fmt.Printf("🔗 Server: %s\n", serverURL)
 
 	url := fmt.Sprintf("%s/trigger-download/%s", serverURL, clientID)
 
-	resp, err := http.Post(url, "application/json", nil)
+	request := map[string]interface{}{}
+	if filePath != "" {
+		request["file_path"] = filePath
+	}
+	if queue {
+		request["queue_if_offline"] = true
+	}
+	if queueTTL != "" {
+		request["queue_ttl"] = queueTTL
+	}
+
+	var reqBody io.Reader
+	if len(request) > 0 {
+		data, _ := json.Marshal(request)
+		reqBody = bytes.NewReader(data)
+	}
+
+	resp, err := http.Post(url, "application/json", reqBody)
 	if err != nil {
 		fmt.Printf("❌ Error: %v\n", err)
 		os.Exit(1)
@@ -107,7 +268,7 @@ func triggerDownload(serverURL, clientID string) {
 		os.Exit(1)
 	}
 
-	if resp.StatusCode != http.StatusOK {
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
 		fmt.Printf("❌ Server returned error (status %d):\n", resp.StatusCode)
 		fmt.Println(string(body))
 		os.Exit(1)
@@ -121,7 +282,11 @@ func triggerDownload(serverURL, clientID string) {
 		os.Exit(1)
 	}
 
-	fmt.Println("\n✅ Download triggered successfully!")
+	if result["status"] == "queued" {
+		fmt.Println("\n⏳ Client is offline, download queued!")
+	} else {
+		fmt.Println("\n✅ Download triggered successfully!")
+	}
 	fmt.Printf("   Upload ID: %v\n", result["upload_id"])
 	fmt.Printf("   S3 Key: %v\n", result["s3_key"])
 	fmt.Printf("   Message: %v\n", result["message"])
@@ -233,3 +398,52 @@ func listClients(serverURL string) {
 		fmt.Println("   (no clients connected)")
 	}
 }
+
+func cancelUpload(serverURL, uploadID string) {
+	fmt.Printf("🛑 Cancelling upload: %s\n", uploadID)
+	fmt.Printf("🔗 Server: %s\n", serverURL)
+
+	url := fmt.Sprintf("%s/uploads/%s", serverURL, uploadID)
+
+	req, err := http.NewRequest(http.MethodDelete, url, nil)
+	if err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Printf("❌ Error reading response: %v\n", err)
+		os.Exit(1)
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		fmt.Printf("❌ Server returned error (status %d):\n", resp.StatusCode)
+		fmt.Println(string(body))
+		os.Exit(1)
+	}
+
+	// Parse and pretty print response
+	var result map[string]interface{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		fmt.Printf("❌ Error parsing response: %v\n", err)
+		fmt.Println(string(body))
+		os.Exit(1)
+	}
+
+	if resp.StatusCode == http.StatusAccepted {
+		fmt.Println("\n⏳ Cancellation requested, waiting for the client to confirm")
+	} else {
+		fmt.Println("\n✅ Upload cancelled")
+	}
+	fmt.Printf("   Upload ID: %v\n", result["upload_id"])
+	fmt.Printf("   Status: %v\n", result["status"])
+	fmt.Printf("   Message: %v\n", result["message"])
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// scheduleInfo is a schedule as returned by the server
+type scheduleInfo struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Cron     string `json:"cron"`
+	Timezone string `json:"timezone"`
+	Selector struct {
+		ClientIDs []string          `json:"client_ids"`
+		All       bool              `json:"all"`
+		Labels    map[string]string `json:"labels"`
+	} `json:"selector"`
+	FilePath      string               `json:"file_path"`
+	JitterSeconds int                  `json:"jitter_seconds"`
+	SkipMissed    bool                 `json:"skip_missed"`
+	LastRunAt     *time.Time           `json:"last_run_at"`
+	LastBatchID   string               `json:"last_batch_id"`
+	NextRunAt     *time.Time           `json:"next_run_at"`
+	Missed        map[string]time.Time `json:"missed"`
+}
+
+// runSchedule dispatches the schedule list|create|delete subcommands
+func runSchedule(serverURL string, args []string) {
+	if len(args) < 1 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "list":
+		listSchedules(serverURL)
+
+	case "create":
+		var (
+			name, cronExpr, timezone string
+			clientID, selector, file string
+			all, skipMissed          bool
+			jitter                   time.Duration
+		)
+		createCmd := flag.NewFlagSet("schedule create", flag.ExitOnError)
+		createCmd.StringVar(&cronExpr, "cron", "", "Cron expression, e.g. \"0 2 * * *\" or @daily (required)")
+		createCmd.StringVar(&timezone, "timezone", "", "IANA timezone for the expression (server local time if empty)")
+		createCmd.StringVar(&name, "name", "", "Schedule name")
+		createCmd.StringVar(&clientID, "client-id", "", "Comma-separated client IDs to download from")
+		createCmd.BoolVar(&all, "all", false, "Download from every connected client")
+		createCmd.StringVar(&selector, "selector", "", "Download from clients with these labels (e.g. region=eu,tier=gold)")
+		createCmd.StringVar(&file, "file", "", "File to upload (server default if empty)")
+		createCmd.DurationVar(&jitter, "jitter", 0, "Random delay per client, up to this (e.g. 5m)")
+		createCmd.BoolVar(&skipMissed, "skip-missed", false, "Don't run for clients that were offline when the schedule fired")
+		createCmd.Parse(args[1:])
+
+		targets := 0
+		for _, set := range []bool{clientID != "", all, selector != ""} {
+			if set {
+				targets++
+			}
+		}
+		if cronExpr == "" || targets != 1 {
+			fmt.Println("❌ Error: --cron and exactly one of --client-id, --all or --selector are required")
+			createCmd.PrintDefaults()
+			os.Exit(1)
+		}
+
+		request := map[string]interface{}{
+			"name":           name,
+			"cron":           cronExpr,
+			"timezone":       timezone,
+			"jitter_seconds": int(jitter.Seconds()),
+			"skip_missed":    skipMissed,
+		}
+		if file != "" {
+			request["file_path"] = file
+		}
+		switch {
+		case all:
+			request["selector"] = map[string]interface{}{"all": true}
+		case selector != "":
+			labels, err := parseLabels(selector)
+			if err != nil {
+				fmt.Printf("❌ Error: %v\n", err)
+				os.Exit(1)
+			}
+			request["selector"] = map[string]interface{}{"labels": labels}
+		default:
+			request["selector"] = map[string]interface{}{"client_ids": strings.Split(clientID, ",")}
+		}
+		createSchedule(serverURL, request)
+
+	case "delete":
+		var scheduleID string
+		deleteCmd := flag.NewFlagSet("schedule delete", flag.ExitOnError)
+		deleteCmd.StringVar(&scheduleID, "schedule-id", "", "Schedule ID to delete (required)")
+		deleteCmd.Parse(args[1:])
+		if scheduleID == "" {
+			fmt.Println("❌ Error: --schedule-id is required")
+			deleteCmd.PrintDefaults()
+			os.Exit(1)
+		}
+		deleteSchedule(serverURL, scheduleID)
+
+	default:
+		printUsage()
+		os.Exit(1)
+	}
+}
+
+func listSchedules(serverURL string) {
+	resp, err := http.Get(fmt.Sprintf("%s/schedules", serverURL))
+	if err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Schedules []scheduleInfo `json:"schedules"`
+		Count     int            `json:"count"`
+	}
+	readResponse(resp, http.StatusOK, &result)
+
+	if result.Count == 0 {
+		fmt.Println("\n⏰ No schedules")
+		return
+	}
+
+	fmt.Printf("\n⏰ Schedules (%d):\n\n", result.Count)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tCRON\tTARGET\tNEXT RUN\tLAST BATCH\tMISSED")
+	for _, schedule := range result.Schedules {
+		cronExpr := schedule.Cron
+		if schedule.Timezone != "" {
+			cronExpr += " (" + schedule.Timezone + ")"
+		}
+		nextRun := "-"
+		if schedule.NextRunAt != nil {
+			nextRun = schedule.NextRunAt.Local().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\n",
+			schedule.ID, schedule.Name, cronExpr, scheduleTarget(schedule), nextRun, schedule.LastBatchID, len(schedule.Missed))
+	}
+	w.Flush()
+}
+
+func createSchedule(serverURL string, request map[string]interface{}) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	resp, err := http.Post(fmt.Sprintf("%s/schedules", serverURL), "application/json", bytes.NewReader(data))
+	if err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	var schedule scheduleInfo
+	readResponse(resp, http.StatusCreated, &schedule)
+
+	fmt.Println("\n✅ Schedule created!")
+	fmt.Printf("   Schedule ID: %s\n", schedule.ID)
+	fmt.Printf("   Cron: %s\n", schedule.Cron)
+	fmt.Printf("   Target: %s\n", scheduleTarget(schedule))
+	if schedule.NextRunAt != nil {
+		fmt.Printf("   Next run: %s\n", schedule.NextRunAt.Local().Format(time.RFC3339))
+	}
+}
+
+func deleteSchedule(serverURL, scheduleID string) {
+	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/schedules/%s", serverURL, scheduleID), nil)
+	if err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	readResponse(resp, http.StatusNoContent, nil)
+	fmt.Printf("\n✅ Schedule %s deleted\n", scheduleID)
+}
+
+// readResponse checks the status and parses the response into v, exiting
+// on errors
+func readResponse(resp *http.Response, expected int, v interface{}) {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Printf("❌ Error reading response: %v\n", err)
+		os.Exit(1)
+	}
+
+	if resp.StatusCode != expected {
+		fmt.Printf("❌ Server returned error (status %d):\n", resp.StatusCode)
+		fmt.Println(string(body))
+		os.Exit(1)
+	}
+	if v == nil {
+		return
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		fmt.Printf("❌ Error parsing response: %v\n", err)
+		fmt.Println(string(body))
+		os.Exit(1)
+	}
+}
+
+// scheduleTarget describes which clients a schedule downloads from
+func scheduleTarget(schedule scheduleInfo) string {
+	switch {
+	case schedule.Selector.All:
+		return "all"
+	case len(schedule.Selector.Labels) > 0:
+		pairs := make([]string, 0, len(schedule.Selector.Labels))
+		for key, value := range schedule.Selector.Labels {
+			pairs = append(pairs, key+"="+value)
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ",")
+	default:
+		return strings.Join(schedule.Selector.ClientIDs, ",")
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// runToken dispatches the token subcommands
+func runToken(serverURL string, args []string) {
+	if len(args) < 1 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "issue":
+		var clientID, expiresIn string
+		issueCmd := flag.NewFlagSet("token issue", flag.ExitOnError)
+		issueCmd.StringVar(&clientID, "client-id", "", "Client ID to issue the token for (required)")
+		issueCmd.StringVar(&expiresIn, "expires-in", "", "Token lifetime, e.g. 720h (server default if empty)")
+		issueCmd.Parse(args[1:])
+		if clientID == "" {
+			fmt.Println("❌ Error: --client-id is required")
+			issueCmd.PrintDefaults()
+			os.Exit(1)
+		}
+
+		issueToken(serverURL, clientID, expiresIn)
+
+	case "revoke":
+		var tokenID, clientID, reason string
+		revokeCmd := flag.NewFlagSet("token revoke", flag.ExitOnError)
+		revokeCmd.StringVar(&tokenID, "token-id", "", "ID (jti) of the token to revoke")
+		revokeCmd.StringVar(&clientID, "client-id", "", "Revoke every token issued to this client so far")
+		revokeCmd.StringVar(&reason, "reason", "", "Why the token is revoked, e.g. \"device stolen\"")
+		revokeCmd.Parse(args[1:])
+		if tokenID == "" && clientID == "" {
+			fmt.Println("❌ Error: --token-id or --client-id is required")
+			revokeCmd.PrintDefaults()
+			os.Exit(1)
+		}
+
+		revokeToken(serverURL, tokenID, clientID, reason)
+
+	case "revocations":
+		listRevocations(serverURL)
+
+	case "operator":
+		var name, role, expiresIn string
+		operatorCmd := flag.NewFlagSet("token operator", flag.ExitOnError)
+		operatorCmd.StringVar(&name, "name", "", "Operator the token is for, e.g. dashboard (required)")
+		operatorCmd.StringVar(&role, "role", "", "Role: viewer, operator or admin (required)")
+		operatorCmd.StringVar(&expiresIn, "expires-in", "", "Token lifetime, e.g. 8h (server default if empty)")
+		operatorCmd.Parse(args[1:])
+		if name == "" || role == "" {
+			fmt.Println("❌ Error: --name and --role are required")
+			operatorCmd.PrintDefaults()
+			os.Exit(1)
+		}
+
+		issueOperatorToken(serverURL, name, role, expiresIn)
+
+	default:
+		printUsage()
+		os.Exit(1)
+	}
+}
+
+func issueToken(serverURL, clientID, expiresIn string) {
+	// Status lines go to stderr so the token alone can be captured
+	fmt.Fprintf(os.Stderr, "🔑 Issuing token for client: %s\n", clientID)
+	fmt.Fprintf(os.Stderr, "🔗 Server: %s\n", serverURL)
+
+	data, err := json.Marshal(map[string]string{"expires_in": expiresIn})
+	if err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	resp, err := http.Post(fmt.Sprintf("%s/admin/clients/%s/token", serverURL, clientID), "application/json", bytes.NewReader(data))
+	if err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Printf("❌ Error reading response: %v\n", err)
+		os.Exit(1)
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		fmt.Printf("❌ Server returned error (status %d):\n", resp.StatusCode)
+		fmt.Println(string(body))
+		os.Exit(1)
+	}
+
+	var result struct {
+		TokenID   string    `json:"token_id"`
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		fmt.Printf("❌ Error parsing response: %v\n", err)
+		fmt.Println(string(body))
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "\n✅ Token %s issued, valid until %s\n", result.TokenID, result.ExpiresAt.Local().Format(time.RFC3339))
+	fmt.Fprintf(os.Stderr, "   Set it as CLIENT_TOKEN on %s:\n\n", clientID)
+	fmt.Println(result.Token)
+}
+
+func issueOperatorToken(serverURL, name, role, expiresIn string) {
+	// Status lines go to stderr so the token alone can be captured
+	fmt.Fprintf(os.Stderr, "🔑 Issuing %s token for operator: %s\n", role, name)
+	fmt.Fprintf(os.Stderr, "🔗 Server: %s\n", serverURL)
+
+	data, err := json.Marshal(map[string]string{"role": role, "expires_in": expiresIn})
+	if err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	resp, err := http.Post(fmt.Sprintf("%s/admin/operators/%s/token", serverURL, name), "application/json", bytes.NewReader(data))
+	if err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		TokenID   string    `json:"token_id"`
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	readResponse(resp, http.StatusCreated, &result)
+
+	fmt.Fprintf(os.Stderr, "\n✅ Token %s issued, valid until %s\n", result.TokenID, result.ExpiresAt.Local().Format(time.RFC3339))
+	fmt.Fprintf(os.Stderr, "   Set it as API_TOKEN or in a CLI profile:\n\n")
+	fmt.Println(result.Token)
+}
+
+func revokeToken(serverURL, tokenID, clientID, reason string) {
+	data, err := json.Marshal(map[string]string{
+		"token_id":  tokenID,
+		"client_id": clientID,
+		"reason":    reason,
+	})
+	if err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	resp, err := http.Post(fmt.Sprintf("%s/admin/revocations", serverURL), "application/json", bytes.NewReader(data))
+	if err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Disconnected []string `json:"disconnected"`
+	}
+	readResponse(resp, http.StatusCreated, &result)
+
+	if tokenID != "" {
+		fmt.Printf("\n🚫 Token %s revoked\n", tokenID)
+	} else {
+		fmt.Printf("\n🚫 All tokens issued to %s so far revoked\n", clientID)
+	}
+	if len(result.Disconnected) > 0 {
+		fmt.Printf("   Disconnected: %s\n", strings.Join(result.Disconnected, ", "))
+	}
+}
+
+func listRevocations(serverURL string) {
+	resp, err := http.Get(fmt.Sprintf("%s/admin/revocations", serverURL))
+	if err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Revocations []struct {
+			TokenID   string    `json:"token_id"`
+			ClientID  string    `json:"client_id"`
+			Reason    string    `json:"reason"`
+			RevokedAt time.Time `json:"revoked_at"`
+		} `json:"revocations"`
+		Count int `json:"count"`
+	}
+	readResponse(resp, http.StatusOK, &result)
+
+	if result.Count == 0 {
+		fmt.Println("\n🚫 No revoked tokens")
+		return
+	}
+
+	fmt.Printf("\n🚫 Revocations (%d):\n\n", result.Count)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TOKEN\tCLIENT\tREVOKED AT\tREASON")
+	for _, revocation := range result.Revocations {
+		tokenID := revocation.TokenID
+		if tokenID == "" {
+			tokenID = "(all)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+			tokenID, revocation.ClientID, revocation.RevokedAt.Local().Format(time.RFC3339), revocation.Reason)
+	}
+	w.Flush()
+}
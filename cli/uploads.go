@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// uploadQuery holds the filters of the uploads command
+type uploadQuery struct {
+	clientID string
+	state    string
+	since    string
+	until    string
+	limit    int
+	cursor   string
+	output   string
+}
+
+// uploadEntry is one upload as returned by GET /uploads
+type uploadEntry struct {
+	UploadID       string    `json:"upload_id"`
+	ClientID       string    `json:"client_id"`
+	FilePath       string    `json:"file_path"`
+	FileSize       int64     `json:"file_size"`
+	Status         string    `json:"status"`
+	Progress       float64   `json:"progress"`
+	CompletedParts int       `json:"completed_parts"`
+	TotalParts     int       `json:"total_parts"`
+	StartTime      time.Time `json:"start_time"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// uploadList is the response of GET /uploads
+type uploadList struct {
+	Uploads    []uploadEntry `json:"uploads"`
+	Count      int           `json:"count"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+func listUploads(serverURL string, query uploadQuery) {
+	params := url.Values{}
+	if query.clientID != "" {
+		params.Set("client_id", query.clientID)
+	}
+	if query.state != "" {
+		params.Set("state", query.state)
+	}
+	if query.since != "" {
+		params.Set("since", query.since)
+	}
+	if query.until != "" {
+		params.Set("until", query.until)
+	}
+	if query.limit > 0 {
+		params.Set("limit", strconv.Itoa(query.limit))
+	}
+	if query.cursor != "" {
+		params.Set("cursor", query.cursor)
+	}
+
+	listURL := fmt.Sprintf("%s/uploads", serverURL)
+	if len(params) > 0 {
+		listURL += "?" + params.Encode()
+	}
+
+	resp, err := http.Get(listURL)
+	if err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Printf("❌ Error reading response: %v\n", err)
+		os.Exit(1)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		fmt.Printf("❌ Server returned error (status %d):\n", resp.StatusCode)
+		fmt.Println(string(body))
+		os.Exit(1)
+	}
+
+	var result uploadList
+	if err := json.Unmarshal(body, &result); err != nil {
+		fmt.Printf("❌ Error parsing response: %v\n", err)
+		fmt.Println(string(body))
+		os.Exit(1)
+	}
+
+	if query.output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(json.RawMessage(body)); err != nil {
+			fmt.Printf("❌ Error writing output: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Printf("📋 Uploads: %d\n", result.Count)
+	fmt.Printf("🔗 Server: %s\n\n", serverURL)
+
+	if len(result.Uploads) == 0 {
+		fmt.Println("   (no uploads found)")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "UPLOAD ID\tCLIENT\tSTATE\tPROGRESS\tSIZE\tSTARTED")
+	for _, upload := range result.Uploads {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.1f%%\t%s\t%s\n",
+			upload.UploadID,
+			upload.ClientID,
+			upload.Status,
+			upload.Progress,
+			formatSize(upload.FileSize),
+			upload.StartTime.Local().Format("2006-01-02 15:04:05"),
+		)
+	}
+	w.Flush()
+
+	if result.NextCursor != "" {
+		fmt.Printf("\n➡️  More uploads available: --cursor=%s\n", result.NextCursor)
+	}
+}
+
+// formatSize renders a byte count in human-readable units
+func formatSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
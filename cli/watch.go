@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// watchEvent is an event from GET /uploads/{upload_id}/events
+type watchEvent struct {
+	Type  string `json:"type"`
+	State *struct {
+		From   string `json:"from"`
+		To     string `json:"to"`
+		Reason string `json:"reason"`
+	} `json:"state"`
+	Progress *struct {
+		BytesUploaded  int64   `json:"bytes_uploaded"`
+		FileSize       int64   `json:"file_size"`
+		CompletedParts int     `json:"completed_parts"`
+		TotalParts     int     `json:"total_parts"`
+		Progress       float64 `json:"progress"`
+		ThroughputBps  float64 `json:"throughput_bps"`
+		ETASeconds     float64 `json:"eta_seconds"`
+		LastError      string  `json:"last_error"`
+	} `json:"progress"`
+}
+
+// watchSnapshot is the upload state sent when the stream opens
+type watchSnapshot struct {
+	UploadID       string  `json:"upload_id"`
+	FilePath       string  `json:"file_path"`
+	FileSize       int64   `json:"file_size"`
+	Status         string  `json:"status"`
+	Progress       float64 `json:"progress"`
+	CompletedParts int     `json:"completed_parts"`
+	TotalParts     int     `json:"total_parts"`
+	Error          string  `json:"error"`
+}
+
+const progressBarWidth = 30
+
+func watchUpload(serverURL, uploadID string) {
+	fmt.Printf("👀 Watching upload: %s\n", uploadID)
+	fmt.Printf("🔗 Server: %s\n", serverURL)
+
+	url := fmt.Sprintf("%s/uploads/%s/events", serverURL, uploadID)
+	lastEventID := ""
+
+	// The server ends the stream at the final state; any other end is a
+	// dropped connection that is resumed from the last event seen
+	for {
+		state, err := streamUpload(url, &lastEventID)
+		if err != nil {
+			fmt.Printf("\n❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		switch state {
+		case "completed":
+			fmt.Println("\n✅ Upload completed")
+			return
+		case "failed", "cancelled":
+			fmt.Printf("\n❌ Upload %s\n", state)
+			os.Exit(1)
+		}
+
+		time.Sleep(time.Second)
+	}
+}
+
+// streamUpload reads the event stream until it ends and returns the last
+// state seen
+func streamUpload(url string, lastEventID *string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if *lastEventID != "" {
+		req.Header.Set("Last-Event-ID", *lastEventID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var body strings.Builder
+		bufio.NewReader(resp.Body).WriteTo(&body)
+		return "", fmt.Errorf("server returned error (status %d): %s", resp.StatusCode, body.String())
+	}
+
+	state := ""
+	eventType := ""
+	var data strings.Builder
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			*lastEventID = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			eventType = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data.WriteString(strings.TrimPrefix(line, "data: "))
+		case line == "":
+			if data.Len() > 0 {
+				if next := renderWatchEvent(eventType, []byte(data.String())); next != "" {
+					state = next
+				}
+			}
+			eventType = ""
+			data.Reset()
+		}
+	}
+
+	return state, scanner.Err()
+}
+
+// renderWatchEvent prints one event and returns the upload state it reports,
+// if any
+func renderWatchEvent(eventType string, data []byte) string {
+	if eventType == "snapshot" {
+		var snapshot watchSnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return ""
+		}
+		fmt.Printf("   File: %s (%s)\n", snapshot.FilePath, formatSize(snapshot.FileSize))
+		fmt.Printf("   Status: %s\n", snapshot.Status)
+		if snapshot.Error != "" {
+			fmt.Printf("   Error: %s\n", snapshot.Error)
+		}
+		drawProgressBar(snapshot.Progress, snapshot.CompletedParts, snapshot.TotalParts, 0, 0)
+		return snapshot.Status
+	}
+
+	var event watchEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return ""
+	}
+
+	switch {
+	case event.State != nil:
+		if event.State.From == "" {
+			fmt.Printf("\n🔄 %s", event.State.To)
+		} else {
+			fmt.Printf("\n🔄 %s → %s", event.State.From, event.State.To)
+		}
+		if event.State.Reason != "" {
+			fmt.Printf(" (%s)", event.State.Reason)
+		}
+		fmt.Println()
+		return event.State.To
+
+	case event.Progress != nil:
+		p := event.Progress
+		drawProgressBar(p.Progress, p.CompletedParts, p.TotalParts, p.ThroughputBps, p.ETASeconds)
+	}
+	return ""
+}
+
+// drawProgressBar redraws the progress line in place
+func drawProgressBar(progress float64, completedParts, totalParts int, throughputBps, etaSeconds float64) {
+	filled := int(progress / 100 * progressBarWidth)
+	if filled > progressBarWidth {
+		filled = progressBarWidth
+	}
+	bar := strings.Repeat("█", filled) + strings.Repeat("░", progressBarWidth-filled)
+
+	line := fmt.Sprintf("\r   [%s] %5.1f%%  %d/%d parts", bar, progress, completedParts, totalParts)
+	if throughputBps > 0 {
+		line += fmt.Sprintf("  %s/s", formatSize(int64(throughputBps)))
+	}
+	if etaSeconds > 0 {
+		line += fmt.Sprintf("  ETA %s", (time.Duration(etaSeconds) * time.Second).String())
+	}
+	// Pad to clear what remains of a longer previous line
+	fmt.Printf("%-100s", line)
+}
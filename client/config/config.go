@@ -2,7 +2,10 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Config holds the client configuration
@@ -12,16 +15,73 @@ type Config struct {
 	ClientToken string
 	FilePath    string
 	LogLevel    string
+
+	// ClientTokenFile keeps the token refreshed over the WebSocket so it
+	// survives restarts; when it exists it is used instead of ClientToken
+	ClientTokenFile string
+
+	// ClientCert and ClientKey authenticate the client to a server that
+	// verifies client certificates; CABundle verifies the server's
+	// certificate instead of the system roots
+	ClientCert string
+	ClientKey  string
+	CABundle   string
+
+	// AllowedPaths are the directories and glob patterns the server may
+	// have the client upload from; MaxFileSize limits the size of those
+	// files in bytes, zero for no limit
+	AllowedPaths []string
+	MaxFileSize  int64
+
+	// Labels are advertised to the server for selecting clients in bulk
+	// operations, e.g. CLIENT_LABELS=region=eu,tier=gold
+	Labels map[string]string
+
+	// UploadConcurrency is the number of parts uploaded in parallel
+	UploadConcurrency int
+
+	// UploadJournalDir is where in-flight uploads are recorded so they can be
+	// resumed after a restart; empty disables resuming
+	UploadJournalDir string
+
+	// Per-part retry policy
+	UploadMaxAttempts       int
+	UploadRetryBaseDelay    time.Duration
+	UploadRetryMaxDelay     time.Duration
+	UploadRetryJitter       float64
+	UploadRetryableStatuses []int
 }
 
 // Load loads the configuration from environment variables
 func Load() *Config {
+	filePath := getEnv("FILE_PATH", "/data/report.bin")
+
 	return &Config{
 		ClientID:    getEnv("CLIENT_ID", "default-client"),
 		ServerWSURL: getEnv("SERVER_WS_URL", "ws://localhost:8080/ws/connect"),
 		ClientToken: getEnv("CLIENT_TOKEN", ""),
-		FilePath:    getEnv("FILE_PATH", "/data/report.bin"),
+		FilePath:    filePath,
 		LogLevel:    getEnv("LOG_LEVEL", "info"),
+		Labels:      getEnvMap("CLIENT_LABELS"),
+
+		// Only the default file's directory unless configured otherwise
+		AllowedPaths: getEnvList("CLIENT_ALLOWED_PATHS", []string{filepath.Dir(filePath)}),
+		MaxFileSize:  getEnvInt64("CLIENT_MAX_FILE_SIZE", 0),
+
+		ClientTokenFile: getEnv("CLIENT_TOKEN_FILE", ""),
+
+		ClientCert: getEnv("CLIENT_CERT", ""),
+		ClientKey:  getEnv("CLIENT_KEY", ""),
+		CABundle:   getEnv("CA_BUNDLE", ""),
+
+		UploadConcurrency: getEnvInt("UPLOAD_CONCURRENCY", 4),
+		UploadJournalDir:  getEnv("UPLOAD_JOURNAL_DIR", ".upload-journal"),
+
+		UploadMaxAttempts:       getEnvInt("UPLOAD_MAX_ATTEMPTS", 5),
+		UploadRetryBaseDelay:    getEnvDuration("UPLOAD_RETRY_BASE_DELAY", 500*time.Millisecond),
+		UploadRetryMaxDelay:     getEnvDuration("UPLOAD_RETRY_MAX_DELAY", 30*time.Second),
+		UploadRetryJitter:       getEnvFloat("UPLOAD_RETRY_JITTER", 0.5),
+		UploadRetryableStatuses: getEnvIntList("UPLOAD_RETRY_STATUSES", []int{408, 429, 500, 502, 503, 504}),
 	}
 }
 
@@ -47,3 +107,99 @@ func getEnvInt(key string, defaultValue int) int {
 	}
 	return intValue
 }
+
+// getEnvInt64 gets a 64-bit integer environment variable with a default value
+func getEnvInt64(key string, defaultValue int64) int64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	intValue, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return intValue
+}
+
+// getEnvDuration gets a duration environment variable with a default value
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return defaultValue
+	}
+	return duration
+}
+
+// getEnvFloat gets a float environment variable with a default value
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	floatValue, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return floatValue
+}
+
+// getEnvIntList gets a comma-separated list of integers with a default value
+func getEnvIntList(key string, defaultValue []int) []int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var values []int
+	for _, item := range strings.Split(value, ",") {
+		intValue, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil {
+			return defaultValue
+		}
+		values = append(values, intValue)
+	}
+	return values
+}
+
+// getEnvList gets a comma-separated list of strings with a default value;
+// empty items are skipped
+func getEnvList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var values []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+// getEnvMap gets a comma-separated list of key=value pairs; malformed pairs
+// are skipped
+func getEnvMap(key string) map[string]string {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+
+	values := make(map[string]string)
+	for _, item := range strings.Split(value, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok || k == "" {
+			continue
+		}
+		values[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return values
+}
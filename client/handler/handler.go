@@ -1,26 +1,67 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
+	"github.com/iriyanto1027/file-download-system/client/journal"
+	"github.com/iriyanto1027/file-download-system/client/sandbox"
 	"github.com/iriyanto1027/file-download-system/client/uploader"
 	"github.com/iriyanto1027/file-download-system/client/websocket"
 	sharedModels "github.com/iriyanto1027/file-download-system/shared/models"
 )
 
+// partURLsTimeout bounds how long the uploader waits for fresh presigned URLs
+const partURLsTimeout = 30 * time.Second
+
 // CommandHandler handles commands from the server
 type CommandHandler struct {
-	wsClient *websocket.Client
-	filePath string
+	wsClient      *websocket.Client
+	filePath      string
+	sandbox       *sandbox.Sandbox // Files the server may ask for
+	uploadOptions uploader.Options
+	journal       *journal.Journal         // Optional, enables resuming uploads
+	active        map[string]*activeUpload // Uploads currently running, by upload ID
+	activeMu      sync.Mutex
+}
+
+// activeUpload lets a running upload be cancelled and waited for
+type activeUpload struct {
+	cancel context.CancelFunc
+	done   chan struct{}
 }
 
-// NewCommandHandler creates a new command handler
-func NewCommandHandler(wsClient *websocket.Client, filePath string) *CommandHandler {
+// cancelWaitTimeout bounds how long a cancel_upload waits for the upload to stop
+const cancelWaitTimeout = 30 * time.Second
+
+// NewCommandHandler creates a new command handler; only files the sandbox
+// allows are stat'ed or uploaded
+func NewCommandHandler(wsClient *websocket.Client, filePath string, fileSandbox *sandbox.Sandbox, uploadOptions uploader.Options, uploadJournal *journal.Journal) *CommandHandler {
 	return &CommandHandler{
-		wsClient: wsClient,
-		filePath: filePath,
+		wsClient:      wsClient,
+		filePath:      filePath,
+		sandbox:       fileSandbox,
+		uploadOptions: uploadOptions,
+		journal:       uploadJournal,
+		active:        make(map[string]*activeUpload),
+	}
+}
+
+// Capabilities implements websocket.CapabilityProvider; the actions listed
+// here must match the ones HandleCommand dispatches
+func (h *CommandHandler) Capabilities() sharedModels.ClientCapabilities {
+	return sharedModels.ClientCapabilities{
+		SupportedActions: []sharedModels.CommandAction{
+			sharedModels.CommandActionStatFile,
+			sharedModels.CommandActionDownloadFile,
+			sharedModels.CommandActionHealthCheck,
+			sharedModels.CommandActionCancelUpload,
+		},
+		MaxConcurrency: max(h.uploadOptions.Concurrency, 1),
 	}
 }
 
@@ -29,6 +70,9 @@ func (h *CommandHandler) HandleCommand(cmd *sharedModels.CommandMessage) error {
 	log.Printf("📥 Received command: %s (ID: %s)", cmd.Action, cmd.MessageID)
 
 	switch cmd.Action {
+	case sharedModels.CommandActionStatFile:
+		return h.handleStatFile(cmd)
+
 	case sharedModels.CommandActionDownloadFile:
 		return h.handleDownloadFile(cmd)
 
@@ -44,68 +88,189 @@ func (h *CommandHandler) HandleCommand(cmd *sharedModels.CommandMessage) error {
 	}
 }
 
-// handleDownloadFile handles the download file command
-func (h *CommandHandler) handleDownloadFile(cmd *sharedModels.CommandMessage) error {
-	// Parse payload
-	payloadMap, ok := cmd.Payload.(map[string]interface{})
-	if !ok {
-		return h.sendErrorResponse(cmd.MessageID, cmd.Action, "invalid payload format")
+// handleStatFile handles the stat file command
+func (h *CommandHandler) handleStatFile(cmd *sharedModels.CommandMessage) error {
+	var payload sharedModels.StatFilePayload
+	if err := cmd.DecodePayload(&payload); err != nil {
+		return h.sendErrorResponse(cmd.MessageID, cmd.Action, err.Error())
 	}
 
-	// Extract upload config
-	uploadConfigMap, ok := payloadMap["upload_config"].(map[string]interface{})
-	if !ok {
-		return h.sendErrorResponse(cmd.MessageID, cmd.Action, "missing upload_config")
+	// Get file path (use from command or default)
+	filePath := h.filePath
+	if payload.FilePath != "" {
+		filePath = payload.FilePath
 	}
 
-	uploadConfig, err := parseUploadConfig(uploadConfigMap)
+	log.Printf("🔍 Stat requested for file: %s", filePath)
+
+	resolvedPath, info, err := h.sandbox.Resolve(filePath)
 	if err != nil {
-		return h.sendErrorResponse(cmd.MessageID, cmd.Action, fmt.Sprintf("failed to parse upload config: %v", err))
+		return h.sendFileError(cmd.MessageID, cmd.Action, fmt.Errorf("failed to stat file: %w", err))
 	}
 
+	response := sharedModels.StatFileResponse{
+		FilePath: filePath,
+		FileSize: info.Size(),
+		ModTime:  info.ModTime(),
+	}
+
+	switch {
+	case payload.ChecksumAlgorithm.IsValid() && payload.ChunkSize > 0:
+		// One pass for the file hash and the checksum S3 verifies each part against
+		hash, parts, err := uploader.ChecksumFile(resolvedPath, payload.ChunkSize, payload.ChecksumAlgorithm)
+		if err != nil {
+			return h.sendErrorResponse(cmd.MessageID, cmd.Action, fmt.Sprintf("failed to hash file: %v", err))
+		}
+		response.SHA256 = hash
+		response.PartChecksums = parts
+
+	case payload.ComputeHash || payload.ChecksumAlgorithm != "":
+		// An algorithm this build doesn't know still gets the file hash
+		hash, err := uploader.HashFile(resolvedPath)
+		if err != nil {
+			return h.sendErrorResponse(cmd.MessageID, cmd.Action, fmt.Sprintf("failed to hash file: %v", err))
+		}
+		response.SHA256 = hash
+	}
+
+	log.Printf("📦 File size: %.2f MB", float64(info.Size())/(1024*1024))
+
+	return h.wsClient.SendResponse(
+		sharedModels.ResponseStatusSuccess,
+		cmd.MessageID,
+		cmd.Action,
+		response,
+		"",
+	)
+}
+
+// handleDownloadFile handles the download file command
+func (h *CommandHandler) handleDownloadFile(cmd *sharedModels.CommandMessage) error {
+	// Parse payload
+	var payload sharedModels.DownloadFilePayload
+	if err := cmd.DecodePayload(&payload); err != nil {
+		return h.sendErrorResponse(cmd.MessageID, cmd.Action, err.Error())
+	}
+	uploadConfig := payload.UploadConfig
+
 	// Get file path (use from command or default)
 	filePath := h.filePath
-	if fp, ok := payloadMap["file_path"].(string); ok && fp != "" {
-		filePath = fp
+	if payload.FilePath != "" {
+		filePath = payload.FilePath
 	}
 
 	log.Printf("📤 Starting upload for file: %s", filePath)
 	log.Printf("   Upload ID: %s", uploadConfig.UploadID)
 	log.Printf("   S3 Key: %s", uploadConfig.Key)
-	log.Printf("   Total parts: %d", len(uploadConfig.PresignedURLs))
+	log.Printf("   Total parts: %d", uploadConfig.TotalParts)
+
+	// Check the file against the sandbox before reading anything
+	resolvedPath, info, err := h.sandbox.Resolve(filePath)
+	if err != nil {
+		return h.sendFileError(cmd.MessageID, cmd.Action, fmt.Errorf("failed to get file size: %w", err))
+	}
+	fileSize := info.Size()
 
 	// Send in-progress response
 	h.wsClient.SendResponse(
 		sharedModels.ResponseStatusInProgress,
 		cmd.MessageID,
 		cmd.Action,
-		map[string]interface{}{
-			"upload_id": uploadConfig.UploadID,
-			"file_path": filePath,
-			"status":    "starting",
+		sharedModels.DownloadFileResponse{
+			UploadID:   uploadConfig.UploadID,
+			FilePath:   filePath,
+			TotalParts: uploadConfig.TotalParts,
 		},
 		"",
 	)
 
-	// Get file size
-	fileSize, err := uploader.GetFileSize(filePath)
-	if err != nil {
-		errMsg := fmt.Sprintf("failed to get file size: %v", err)
+	log.Printf("📦 File size: %.2f MB", float64(fileSize)/(1024*1024))
+
+	// The multipart upload was sized from stat_file, so the file must not have changed since
+	if uploadConfig.FileSize > 0 && fileSize != uploadConfig.FileSize {
+		errMsg := fmt.Sprintf("file size changed since stat: expected %d bytes, found %d", uploadConfig.FileSize, fileSize)
 		log.Printf("❌ %s", errMsg)
 		return h.sendErrorResponse(cmd.MessageID, cmd.Action, errMsg)
 	}
 
-	log.Printf("📦 File size: %.2f MB", float64(fileSize)/(1024*1024))
+	startTime := time.Now()
+
+	// Journal the upload so it can be resumed after a restart or disconnect
+	if h.journal != nil && uploadConfig.S3UploadID != "" {
+		err := h.journal.Save(&journal.Entry{
+			UploadID:   uploadConfig.UploadID,
+			S3UploadID: uploadConfig.S3UploadID,
+			FilePath:   filePath,
+			Bucket:     uploadConfig.Bucket,
+			Key:        uploadConfig.Key,
+			ChunkSize:  uploadConfig.ChunkSize,
+			FileSize:   fileSize,
+			ModTime:    info.ModTime(),
+			TotalParts: uploadConfig.TotalParts,
+			Parts:      make(map[int]string),
+			StartTime:  startTime,
+		})
+		if err != nil {
+			log.Printf("⚠️ Failed to journal upload %s, it will not be resumable: %v", uploadConfig.UploadID, err)
+		}
+	}
+
+	return h.runUpload(cmd.MessageID, resolvedPath, uploadConfig, nil, startTime)
+}
+
+// runUpload uploads the file, reports progress and sends the final
+// download_file response; filePath has been resolved by the sandbox, and
+// completed holds parts uploaded by an earlier run
+func (h *CommandHandler) runUpload(commandID, filePath string, uploadConfig sharedModels.UploadConfig, completed map[int]string, startTime time.Time) error {
+	action := sharedModels.CommandActionDownloadFile
+
+	// A reconnect may try to resume an upload this process is still running
+	h.activeMu.Lock()
+	if _, running := h.active[uploadConfig.UploadID]; running {
+		h.activeMu.Unlock()
+		log.Printf("Upload %s is already running", uploadConfig.UploadID)
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	running := &activeUpload{cancel: cancel, done: make(chan struct{})}
+	h.active[uploadConfig.UploadID] = running
+	h.activeMu.Unlock()
+
+	defer func() {
+		cancel()
+		h.activeMu.Lock()
+		delete(h.active, uploadConfig.UploadID)
+		h.activeMu.Unlock()
+		close(running.done)
+	}()
+
+	fileSize := uploadConfig.FileSize
 
 	// Create uploader
-	up := uploader.NewUploader(filePath, uploadConfig)
+	up := uploader.NewUploader(filePath, uploadConfig, h, h.uploadOptions)
+	up.SetCompletedParts(completed)
 
-	// Upload with progress callback
-	result, err := up.Upload(func(partNumber, totalParts int, bytesUploaded, totalBytes int64) {
-		progress := float64(bytesUploaded) / float64(totalBytes) * 100
+	if h.journal != nil {
+		up.SetPartCallback(func(partNumber int, etag string) {
+			if err := h.journal.RecordPart(uploadConfig.UploadID, partNumber, etag); err != nil {
+				log.Printf("⚠️ Failed to journal part %d of upload %s: %v", partNumber, uploadConfig.UploadID, err)
+			}
+		})
+	}
 
-		// Send status update
-		h.wsClient.SendStatus("uploading", &sharedModels.UploadStatus{
+	// Retries happen on worker goroutines, progress on the uploader's collector
+	var retryMu sync.Mutex
+	retries := 0
+	lastError := ""
+	currentStatus := func(partNumber, totalParts int, bytesUploaded, totalBytes int64) *sharedModels.UploadStatus {
+		retryMu.Lock()
+		defer retryMu.Unlock()
+
+		progress := 0.0
+		if totalBytes > 0 {
+			progress = float64(bytesUploaded) / float64(totalBytes) * 100
+		}
+		return &sharedModels.UploadStatus{
 			UploadID:       uploadConfig.UploadID,
 			FilePath:       filePath,
 			FileSize:       totalBytes,
@@ -113,38 +278,212 @@ func (h *CommandHandler) handleDownloadFile(cmd *sharedModels.CommandMessage) er
 			CompletedParts: partNumber,
 			BytesUploaded:  bytesUploaded,
 			Progress:       progress,
-			StartTime:      time.Now().Add(-time.Second * time.Duration(partNumber*2)), // Approximate
+			StartTime:      startTime,
 			LastUpdate:     time.Now(),
-		}, nil)
+			Retries:        retries,
+			LastError:      lastError,
+		}
+	}
+
+	var progressMu sync.Mutex
+	completedParts := 0
+	var uploadedBytes int64
+
+	up.SetRetryCallback(func(partNumber, attempt int, delay time.Duration, err error) {
+		retryMu.Lock()
+		retries++
+		lastError = fmt.Sprintf("part %d attempt %d: %v", partNumber, attempt, err)
+		retryMu.Unlock()
+
+		progressMu.Lock()
+		status := currentStatus(completedParts, uploadConfig.TotalParts, uploadedBytes, fileSize)
+		progressMu.Unlock()
+
+		// Send status update
+		h.wsClient.SendStatus("retrying", status, nil)
+	})
 
-		log.Printf("📊 Progress: %.1f%% (%d/%d parts)", progress, partNumber, totalParts)
+	// Upload with progress callback
+	result, err := up.Upload(ctx, func(partNumber, totalParts int, bytesUploaded, totalBytes int64) {
+		progressMu.Lock()
+		completedParts = partNumber
+		uploadedBytes = bytesUploaded
+		progressMu.Unlock()
+
+		status := currentStatus(partNumber, totalParts, bytesUploaded, totalBytes)
+
+		// Send status update
+		h.wsClient.SendStatus("uploading", status, nil)
+
+		log.Printf("📊 Progress: %.1f%% (%d/%d parts)", status.Progress, partNumber, totalParts)
 	})
 
+	if errors.Is(err, context.Canceled) {
+		log.Printf("🛑 Upload %s cancelled", uploadConfig.UploadID)
+		h.forget(uploadConfig.UploadID)
+		return h.wsClient.SendResponse(
+			sharedModels.ResponseStatusCancelled,
+			commandID,
+			action,
+			sharedModels.DownloadFileResponse{
+				UploadID:   uploadConfig.UploadID,
+				FilePath:   filePath,
+				TotalParts: uploadConfig.TotalParts,
+			},
+			"",
+		)
+	}
+
 	if err != nil {
 		errMsg := fmt.Sprintf("upload failed: %v", err)
 		log.Printf("❌ %s", errMsg)
-		return h.sendErrorResponse(cmd.MessageID, cmd.Action, errMsg)
+		var code sharedModels.ErrorCode
+		if errors.Is(err, uploader.ErrChecksumMismatch) {
+			code = sharedModels.ErrorCodeChecksumMismatch
+		}
+		if sendErr := h.wsClient.SendError(commandID, action, code, errMsg); sendErr != nil {
+			// The server never heard about the failure, keep the journal to resume later
+			return sendErr
+		}
+		h.forget(uploadConfig.UploadID)
+		return nil
 	}
 
 	// Send success response
-	log.Printf("✅ Upload completed successfully")
-	return h.wsClient.SendResponse(
+	log.Printf("✅ Upload completed successfully (%d retries)", result.Retries)
+	err = h.wsClient.SendResponse(
 		sharedModels.ResponseStatusSuccess,
-		cmd.MessageID,
-		cmd.Action,
+		commandID,
+		action,
 		sharedModels.DownloadFileResponse{
 			UploadID:       result.UploadID,
 			FilePath:       filePath,
 			FileSize:       result.FileSize,
 			TotalParts:     result.TotalParts,
 			CompletedParts: result.CompletedParts,
-			StartTime:      time.Now().Add(-result.Duration),
+			StartTime:      startTime,
 			EndTime:        time.Now(),
 			S3Key:          uploadConfig.Key,
-			ETags:          result.ETags, // Include ETags for multipart completion
+			ETags:          result.ETags,     // Include ETags for multipart completion
+			Checksums:      result.Checksums, // And the checksums the server verifies them with
 		},
 		"",
 	)
+	if err == nil {
+		h.forget(uploadConfig.UploadID)
+	}
+	return err
+}
+
+// HandleConnected implements websocket.ConnectHandler by resuming every
+// upload left in the journal
+func (h *CommandHandler) HandleConnected() {
+	if h.journal == nil {
+		return
+	}
+
+	entries, err := h.journal.List()
+	if err != nil {
+		log.Printf("⚠️ Failed to read upload journal: %v", err)
+		return
+	}
+
+	for _, entry := range entries {
+		h.resumeUpload(entry)
+	}
+}
+
+// resumeUpload asks the server to reconcile a journaled upload and uploads
+// the parts that are still missing
+func (h *CommandHandler) resumeUpload(entry *journal.Entry) {
+	action := sharedModels.CommandActionDownloadFile
+	log.Printf("🔁 Resuming upload %s (%d/%d parts journaled)", entry.UploadID, len(entry.Parts), entry.TotalParts)
+
+	// The allowlist may have changed since the upload started
+	resolvedPath, info, err := h.sandbox.Resolve(entry.FilePath)
+	if errors.Is(err, sandbox.ErrForbiddenPath) || errors.Is(err, sandbox.ErrFileTooLarge) {
+		if h.sendFileError(entry.UploadID, action, fmt.Errorf("cannot resume upload: %w", err)) == nil {
+			h.forget(entry.UploadID)
+		}
+		return
+	}
+
+	// Parts already on S3 are only valid if the file is unchanged
+	if err != nil || info.Size() != entry.FileSize || !info.ModTime().Equal(entry.ModTime) {
+		errMsg := fmt.Sprintf("cannot resume upload: file %s changed or disappeared since the upload started", entry.FilePath)
+		log.Printf("❌ %s", errMsg)
+		if h.sendErrorResponse(entry.UploadID, action, errMsg) == nil {
+			h.forget(entry.UploadID)
+		}
+		return
+	}
+
+	resp, err := h.wsClient.Request(
+		sharedModels.CommandActionResumeUpload,
+		sharedModels.ResumeUploadPayload{
+			UploadID:       entry.UploadID,
+			S3UploadID:     entry.S3UploadID,
+			FilePath:       entry.FilePath,
+			FileSize:       entry.FileSize,
+			ModTime:        entry.ModTime,
+			CompletedParts: entry.Parts,
+		},
+		partURLsTimeout,
+	)
+	if err != nil {
+		log.Printf("❌ Failed to resume upload %s: %v", entry.UploadID, err)
+		if resp != nil {
+			// The server knows the upload can't continue
+			h.forget(entry.UploadID)
+		}
+		return
+	}
+
+	var payload sharedModels.ResumeUploadResponse
+	if err := resp.DecodePayload(&payload); err != nil {
+		log.Printf("❌ Invalid resume response for upload %s: %v", entry.UploadID, err)
+		return
+	}
+
+	if err := h.runUpload(entry.UploadID, resolvedPath, payload.UploadConfig, payload.CompletedParts, entry.StartTime); err != nil {
+		log.Printf("❌ Resumed upload %s did not finish: %v", entry.UploadID, err)
+	}
+}
+
+// forget removes a finished upload from the journal
+func (h *CommandHandler) forget(uploadID string) {
+	if h.journal == nil {
+		return
+	}
+	if err := h.journal.Remove(uploadID); err != nil {
+		log.Printf("⚠️ Failed to remove upload %s from journal: %v", uploadID, err)
+	}
+}
+
+// RequestPartURLs implements uploader.URLProvider by asking the server for a
+// fresh batch of presigned URLs over the WebSocket
+func (h *CommandHandler) RequestPartURLs(uploadID string, partNumbers []int) ([]sharedModels.PresignedURL, error) {
+	resp, err := h.wsClient.Request(
+		sharedModels.CommandActionRequestPartURLs,
+		sharedModels.RequestPartURLsPayload{
+			UploadID:    uploadID,
+			PartNumbers: partNumbers,
+		},
+		partURLsTimeout,
+	)
+	if err != nil {
+		// An answer carrying an error is a refusal; retrying won't change it
+		if resp != nil {
+			return nil, fmt.Errorf("%w: %v", uploader.ErrPartURLsRefused, err)
+		}
+		return nil, err
+	}
+
+	var payload sharedModels.PartURLsResponse
+	if err := resp.DecodePayload(&payload); err != nil {
+		return nil, fmt.Errorf("invalid part URLs response: %w", err)
+	}
+	return payload.PresignedURLs, nil
 }
 
 // handleHealthCheck handles the health check command
@@ -155,31 +494,75 @@ func (h *CommandHandler) handleHealthCheck(cmd *sharedModels.CommandMessage) err
 		sharedModels.ResponseStatusSuccess,
 		cmd.MessageID,
 		cmd.Action,
-		map[string]interface{}{
-			"status":    "healthy",
-			"timestamp": time.Now(),
+		sharedModels.HealthCheckResponse{
+			Status:    "healthy",
+			Timestamp: time.Now(),
 		},
 		"",
 	)
 }
 
-// handleCancelUpload handles the cancel upload command
+// handleCancelUpload handles the cancel upload command; it only confirms
+// once the upload has actually stopped
 func (h *CommandHandler) handleCancelUpload(cmd *sharedModels.CommandMessage) error {
-	log.Printf("🛑 Cancel upload requested")
+	var payload sharedModels.CancelUploadPayload
+	if err := cmd.DecodePayload(&payload); err != nil {
+		return h.sendErrorResponse(cmd.MessageID, cmd.Action, err.Error())
+	}
+	uploadID := payload.UploadID
+
+	log.Printf("🛑 Cancel upload requested: %s", uploadID)
+	reply := sharedModels.CancelUploadPayload{UploadID: uploadID}
+
+	h.activeMu.Lock()
+	running, ok := h.active[uploadID]
+	h.activeMu.Unlock()
+
+	if ok {
+		running.cancel()
+		select {
+		case <-running.done:
+		case <-time.After(cancelWaitTimeout):
+			return h.wsClient.SendResponse(sharedModels.ResponseStatusError, cmd.MessageID, cmd.Action, reply,
+				fmt.Sprintf("upload %s did not stop within %v", uploadID, cancelWaitTimeout))
+		}
+	} else if h.journal == nil {
+		return h.wsClient.SendResponse(sharedModels.ResponseStatusError, cmd.MessageID, cmd.Action, reply,
+			fmt.Sprintf("upload %s is not running", uploadID))
+	} else if _, err := h.journal.Load(uploadID); err != nil {
+		return h.wsClient.SendResponse(sharedModels.ResponseStatusError, cmd.MessageID, cmd.Action, reply,
+			fmt.Sprintf("upload %s is not running", uploadID))
+	}
 
-	// TODO: Implement upload cancellation logic
+	// An interrupted upload must not be resumed later either
+	h.forget(uploadID)
 
 	return h.wsClient.SendResponse(
 		sharedModels.ResponseStatusCancelled,
 		cmd.MessageID,
 		cmd.Action,
-		map[string]interface{}{
-			"status": "cancelled",
-		},
+		reply,
 		"",
 	)
 }
 
+// sendFileError sends the error for a file that can't be read, classified
+// so the server can tell the sandbox refused it
+func (h *CommandHandler) sendFileError(messageID string, action sharedModels.CommandAction, err error) error {
+	var code sharedModels.ErrorCode
+	switch {
+	case errors.Is(err, sandbox.ErrForbiddenPath):
+		code = sharedModels.ErrorCodeForbiddenPath
+		log.Printf("🚫 Refused %s: %v", action, err)
+	case errors.Is(err, sandbox.ErrFileTooLarge):
+		code = sharedModels.ErrorCodeFileTooLarge
+		log.Printf("🚫 Refused %s: %v", action, err)
+	default:
+		log.Printf("❌ %v", err)
+	}
+	return h.wsClient.SendError(messageID, action, code, err.Error())
+}
+
 // sendErrorResponse sends an error response
 func (h *CommandHandler) sendErrorResponse(messageID string, action sharedModels.CommandAction, errMsg string) error {
 	return h.wsClient.SendResponse(
@@ -190,49 +573,3 @@ func (h *CommandHandler) sendErrorResponse(messageID string, action sharedModels
 		errMsg,
 	)
 }
-
-// parseUploadConfig parses the upload config from a map
-func parseUploadConfig(m map[string]interface{}) (sharedModels.UploadConfig, error) {
-	config := sharedModels.UploadConfig{}
-
-	if uploadID, ok := m["upload_id"].(string); ok {
-		config.UploadID = uploadID
-	}
-
-	if bucket, ok := m["bucket"].(string); ok {
-		config.Bucket = bucket
-	}
-
-	if key, ok := m["key"].(string); ok {
-		config.Key = key
-	}
-
-	if region, ok := m["region"].(string); ok {
-		config.Region = region
-	}
-
-	if chunkSize, ok := m["chunk_size"].(float64); ok {
-		config.ChunkSize = int64(chunkSize)
-	}
-
-	// Parse presigned URLs
-	if presignedURLs, ok := m["presigned_urls"].([]interface{}); ok {
-		config.PresignedURLs = make([]sharedModels.PresignedURL, len(presignedURLs))
-		for i, urlInterface := range presignedURLs {
-			if urlMap, ok := urlInterface.(map[string]interface{}); ok {
-				if partNumber, ok := urlMap["part_number"].(float64); ok {
-					config.PresignedURLs[i].PartNumber = int(partNumber)
-				}
-				if url, ok := urlMap["url"].(string); ok {
-					config.PresignedURLs[i].URL = url
-				}
-			}
-		}
-	}
-
-	if config.UploadID == "" || len(config.PresignedURLs) == 0 {
-		return config, fmt.Errorf("invalid upload config: missing required fields")
-	}
-
-	return config, nil
-}
@@ -0,0 +1,160 @@
+package journal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is the persisted state of an upload that has not finished yet
+type Entry struct {
+	UploadID   string         `json:"upload_id"`
+	S3UploadID string         `json:"s3_upload_id"`
+	FilePath   string         `json:"file_path"`
+	Bucket     string         `json:"bucket"`
+	Key        string         `json:"key"`
+	ChunkSize  int64          `json:"chunk_size"`
+	FileSize   int64          `json:"file_size"`
+	ModTime    time.Time      `json:"mod_time"`
+	TotalParts int            `json:"total_parts"`
+	Parts      map[int]string `json:"parts"` // part number -> ETag
+	StartTime  time.Time      `json:"start_time"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
+// errCorruptEntry is wrapped by read when an entry file can't be decoded
+var errCorruptEntry = errors.New("corrupt journal entry")
+
+// Journal stores upload entries as one JSON file per upload in a directory
+type Journal struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// Open opens the journal directory, creating it if needed
+func Open(dir string) (*Journal, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create journal directory: %w", err)
+	}
+	return &Journal{dir: dir}, nil
+}
+
+// Save writes an entry, replacing any previous version atomically
+func (j *Journal) Save(entry *Entry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.write(entry)
+}
+
+// RecordPart adds a completed part to an existing entry
+func (j *Journal) RecordPart(uploadID string, partNumber int, etag string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry, err := j.read(uploadID)
+	if err != nil {
+		return err
+	}
+	if entry.Parts == nil {
+		entry.Parts = make(map[int]string)
+	}
+	entry.Parts[partNumber] = etag
+	return j.write(entry)
+}
+
+// Load reads the entry for an upload
+func (j *Journal) Load(uploadID string) (*Entry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.read(uploadID)
+}
+
+// List returns every entry in the journal. Entries that can't be read are
+// skipped; corrupt ones are renamed to .corrupt so they are kept for
+// inspection but not loaded again.
+func (j *Journal) List() ([]*Entry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	files, err := os.ReadDir(j.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal directory: %w", err)
+	}
+
+	entries := make([]*Entry, 0, len(files))
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		entry, err := j.read(strings.TrimSuffix(file.Name(), ".json"))
+		if errors.Is(err, errCorruptEntry) {
+			path := filepath.Join(j.dir, file.Name())
+			log.Printf("⚠️ Skipping %v, moving it aside", err)
+			if err := os.Rename(path, path+".corrupt"); err != nil {
+				log.Printf("Failed to quarantine journal entry %s: %v", path, err)
+			}
+			continue
+		}
+		if err != nil {
+			log.Printf("⚠️ Skipping journal entry %s: %v", file.Name(), err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Remove deletes the entry for an upload
+func (j *Journal) Remove(uploadID string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := os.Remove(j.path(uploadID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove journal entry: %w", err)
+	}
+	return nil
+}
+
+// read loads an entry; the caller must hold the lock
+func (j *Journal) read(uploadID string) (*Entry, error) {
+	data, err := os.ReadFile(j.path(uploadID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal entry: %w", err)
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("%w %s: %v", errCorruptEntry, uploadID, err)
+	}
+	return &entry, nil
+}
+
+// write persists an entry through a temporary file so a crash never leaves a
+// truncated entry behind; the caller must hold the lock
+func (j *Journal) write(entry *Entry) error {
+	entry.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode journal entry: %w", err)
+	}
+
+	tmpPath := j.path(entry.UploadID) + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write journal entry: %w", err)
+	}
+	if err := os.Rename(tmpPath, j.path(entry.UploadID)); err != nil {
+		return fmt.Errorf("failed to commit journal entry: %w", err)
+	}
+	return nil
+}
+
+// path returns the file holding an upload's entry
+func (j *Journal) path(uploadID string) string {
+	return filepath.Join(j.dir, filepath.Base(uploadID)+".json")
+}
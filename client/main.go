@@ -1,19 +1,29 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"github.com/iriyanto1027/file-download-system/client/config"
 	"github.com/iriyanto1027/file-download-system/client/handler"
+	"github.com/iriyanto1027/file-download-system/client/journal"
+	"github.com/iriyanto1027/file-download-system/client/sandbox"
+	"github.com/iriyanto1027/file-download-system/client/uploader"
 	"github.com/iriyanto1027/file-download-system/client/websocket"
+	"github.com/iriyanto1027/file-download-system/shared/auth"
 	"github.com/joho/godotenv"
 )
 
+// build identifies the client build in the hello sent to the server; set it
+// with -ldflags "-X main.build=<version>"
+var build = "dev"
+
 func main() {
 	// Load environment variables
 	if err := godotenv.Load(); err != nil {
@@ -27,23 +37,85 @@ func main() {
 	cfg := config.Load()
 
 	fmt.Printf("🆔 Client ID: %s\n", cfg.ClientID)
+	fmt.Printf("🏷️  Build: %s\n", build)
 	fmt.Printf("📡 Server URL: %s\n", cfg.ServerWSURL)
 	fmt.Printf("📁 File Path: %s\n", cfg.FilePath)
+	fmt.Printf("🛡️  Allowed Paths: %s\n", strings.Join(cfg.AllowedPaths, ", "))
+	if cfg.MaxFileSize > 0 {
+		fmt.Printf("📏 Max File Size: %d bytes\n", cfg.MaxFileSize)
+	}
+	fmt.Printf("⚡ Upload Concurrency: %d\n", cfg.UploadConcurrency)
+	fmt.Printf("🔁 Upload Attempts: %d per part\n", cfg.UploadMaxAttempts)
+	if cfg.UploadJournalDir != "" {
+		fmt.Printf("📓 Upload Journal: %s\n", cfg.UploadJournalDir)
+	}
 	fmt.Println("================================")
 
+	// Only files inside the allowlist may be uploaded, whatever the server asks for
+	fileSandbox, err := sandbox.New(cfg.AllowedPaths, cfg.MaxFileSize)
+	if err != nil {
+		log.Fatalf("❌ Invalid CLIENT_ALLOWED_PATHS or CLIENT_MAX_FILE_SIZE: %v", err)
+	}
+
 	// Create context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Prefer the token refreshed during an earlier run
+	token := cfg.ClientToken
+	var onTokenRefresh func(string)
+	if cfg.ClientTokenFile != "" {
+		if data, err := os.ReadFile(cfg.ClientTokenFile); err == nil && len(bytes.TrimSpace(data)) > 0 {
+			token = string(bytes.TrimSpace(data))
+			fmt.Printf("🔑 Token loaded from %s\n", cfg.ClientTokenFile)
+		}
+		onTokenRefresh = func(refreshed string) {
+			if err := saveToken(cfg.ClientTokenFile, refreshed); err != nil {
+				log.Printf("⚠️ Failed to save refreshed token: %v", err)
+			}
+		}
+	}
+
+	// Authenticate with a client certificate if one is configured
+	tlsConfig, err := auth.ClientTLSConfig(cfg.ClientCert, cfg.ClientKey, cfg.CABundle)
+	if err != nil {
+		log.Fatalf("❌ Failed to load TLS configuration: %v", err)
+	}
+	if cfg.ClientCert != "" {
+		fmt.Printf("🔐 Client certificate: %s\n", cfg.ClientCert)
+	}
+
 	// Create WebSocket client without handler first
 	wsClient := websocket.NewClient(websocket.Config{
-		ClientID:  cfg.ClientID,
-		ServerURL: cfg.ServerWSURL,
-		Token:     cfg.ClientToken,
+		ClientID:       cfg.ClientID,
+		ServerURL:      cfg.ServerWSURL,
+		Token:          token,
+		OnTokenRefresh: onTokenRefresh,
+		ClientBuild:    build,
+		Labels:         cfg.Labels,
+		TLSConfig:      tlsConfig,
 	}, nil)
 
+	// Open the upload journal used to resume interrupted uploads
+	var uploadJournal *journal.Journal
+	if cfg.UploadJournalDir != "" {
+		uploadJournal, err = journal.Open(cfg.UploadJournalDir)
+		if err != nil {
+			log.Fatalf("❌ Failed to open upload journal: %v", err)
+		}
+	}
+
 	// Create command handler with the client
-	commandHandler := handler.NewCommandHandler(wsClient, cfg.FilePath)
+	commandHandler := handler.NewCommandHandler(wsClient, cfg.FilePath, fileSandbox, uploader.Options{
+		Concurrency: cfg.UploadConcurrency,
+		Retry: uploader.RetryPolicy{
+			MaxAttempts:       cfg.UploadMaxAttempts,
+			BaseDelay:         cfg.UploadRetryBaseDelay,
+			MaxDelay:          cfg.UploadRetryMaxDelay,
+			Jitter:            cfg.UploadRetryJitter,
+			RetryableStatuses: cfg.UploadRetryableStatuses,
+		},
+	}, uploadJournal)
 
 	// Update the client to use the handler
 	wsClient.SetMessageHandler(commandHandler)
@@ -78,3 +150,16 @@ func main() {
 
 	fmt.Println("✅ Client stopped")
 }
+
+// saveToken writes the token through a temporary file so a crash never
+// leaves a truncated token behind
+func saveToken(path, token string) error {
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, []byte(token+"\n"), 0o600); err != nil {
+		return fmt.Errorf("failed to write token: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to commit token: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrForbiddenPath is returned for a file outside the allowed paths
+	ErrForbiddenPath = errors.New("forbidden path")
+
+	// ErrFileTooLarge is returned for a file over the size limit
+	ErrFileTooLarge = errors.New("file too large")
+)
+
+// Sandbox limits which files the server may have the client read. A path is
+// checked after resolving "..", symlinks and relative components, so a link
+// inside an allowed directory can't point the upload elsewhere.
+type Sandbox struct {
+	dirs     []string // Files anywhere below these directories, kept with a trailing separator, are allowed
+	patterns []string // Globs (or exact paths) matched against the resolved path
+	maxSize  int64    // Zero means no limit
+}
+
+// New creates a sandbox allowing the given absolute directories and glob
+// patterns, e.g. /data or /var/exports/*.csv. An entry that is an existing
+// directory allows everything below it; any other entry is a pattern
+// matched against the whole resolved path, where * does not cross "/".
+// maxSize limits the size of a file in bytes; zero means no limit.
+func New(allowed []string, maxSize int64) (*Sandbox, error) {
+	if len(allowed) == 0 {
+		return nil, errors.New("at least one allowed path is required")
+	}
+	if maxSize < 0 {
+		return nil, fmt.Errorf("invalid maximum file size %d", maxSize)
+	}
+
+	s := &Sandbox{maxSize: maxSize}
+	for _, entry := range allowed {
+		if !filepath.IsAbs(entry) {
+			return nil, fmt.Errorf("allowed path %q must be absolute", entry)
+		}
+		entry = filepath.Clean(entry)
+
+		if hasMeta(entry) {
+			if _, err := filepath.Match(entry, ""); err != nil {
+				return nil, fmt.Errorf("invalid allowed pattern %q: %w", entry, err)
+			}
+			s.patterns = append(s.patterns, resolvePattern(entry))
+			continue
+		}
+
+		// Paths are compared after resolving symlinks, so resolve the entry too
+		resolved, err := filepath.EvalSymlinks(entry)
+		if err != nil {
+			// Not there yet; it can only match exactly
+			s.patterns = append(s.patterns, entry)
+			continue
+		}
+		if info, err := os.Stat(resolved); err == nil && info.IsDir() {
+			if !strings.HasSuffix(resolved, string(filepath.Separator)) {
+				resolved += string(filepath.Separator)
+			}
+			s.dirs = append(s.dirs, resolved)
+		} else {
+			s.patterns = append(s.patterns, resolved)
+		}
+	}
+	return s, nil
+}
+
+// Allowed lists the directories and patterns the sandbox allows, resolved
+func (s *Sandbox) Allowed() []string {
+	allowed := append([]string(nil), s.dirs...)
+	return append(allowed, s.patterns...)
+}
+
+// MaxSize returns the size limit in bytes, zero if there is none
+func (s *Sandbox) MaxSize() int64 {
+	return s.maxSize
+}
+
+// Resolve checks that path names an allowed regular file within the size
+// limit and returns the resolved path to open along with its details. Paths
+// the sandbox refuses fail with ErrForbiddenPath or ErrFileTooLarge; whether
+// a forbidden file exists is not revealed.
+func (s *Sandbox) Resolve(path string) (string, os.FileInfo, error) {
+	if !filepath.IsAbs(path) {
+		return "", nil, fmt.Errorf("%w: %s is not an absolute path", ErrForbiddenPath, path)
+	}
+	cleaned := filepath.Clean(path)
+
+	resolved, err := filepath.EvalSymlinks(cleaned)
+	if err != nil {
+		if !s.allows(cleaned) {
+			return "", nil, fmt.Errorf("%w: %s is outside the allowed paths", ErrForbiddenPath, path)
+		}
+		return "", nil, err
+	}
+	if !s.allows(resolved) {
+		return "", nil, fmt.Errorf("%w: %s is outside the allowed paths", ErrForbiddenPath, path)
+	}
+
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", nil, err
+	}
+	if info.IsDir() {
+		return "", nil, fmt.Errorf("%s is a directory", path)
+	}
+	if !info.Mode().IsRegular() {
+		return "", nil, fmt.Errorf("%w: %s is not a regular file", ErrForbiddenPath, path)
+	}
+	if s.maxSize > 0 && info.Size() > s.maxSize {
+		return "", nil, fmt.Errorf("%w: %s is %d bytes, the limit is %d", ErrFileTooLarge, path, info.Size(), s.maxSize)
+	}
+	return resolved, info, nil
+}
+
+// allows reports whether a resolved path is below an allowed directory or
+// matches an allowed pattern
+func (s *Sandbox) allows(path string) bool {
+	for _, dir := range s.dirs {
+		if strings.HasPrefix(path, dir) {
+			return true
+		}
+	}
+	for _, pattern := range s.patterns {
+		if matched, _ := filepath.Match(pattern, path); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// hasMeta reports whether the path contains glob metacharacters
+func hasMeta(path string) bool {
+	return strings.ContainsAny(path, `*?[\`)
+}
+
+// resolvePattern resolves symlinks in the part of a pattern before its
+// first metacharacter, so it matches resolved paths
+func resolvePattern(pattern string) string {
+	dir := filepath.Dir(pattern)
+	rest := filepath.Base(pattern)
+	for hasMeta(dir) {
+		rest = filepath.Join(filepath.Base(dir), rest)
+		dir = filepath.Dir(dir)
+	}
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return pattern
+	}
+	return filepath.Join(resolved, rest)
+}
@@ -0,0 +1,71 @@
+package uploader
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	sharedModels "github.com/iriyanto1027/file-download-system/shared/models"
+)
+
+// ErrChecksumMismatch is returned when a part no longer matches the
+// checksum it was stat'ed with, i.e. the file changed in the meantime
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// PartChecksum returns the base64 checksum S3 expects for a part
+func PartChecksum(algorithm sharedModels.ChecksumAlgorithm, data []byte) (string, error) {
+	h, err := algorithm.NewHash()
+	if err != nil {
+		return "", err
+	}
+	h.Write(data)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// ChecksumHeader returns the header a part's checksum is sent in
+func ChecksumHeader(algorithm sharedModels.ChecksumAlgorithm) string {
+	switch algorithm {
+	case sharedModels.ChecksumAlgorithmCRC32C:
+		return "x-amz-checksum-crc32c"
+	}
+	return "x-amz-checksum-sha256"
+}
+
+// ChecksumFile reads the file once, returning its hex-encoded SHA-256 and
+// the base64 checksum of each chunkSize part, first part first
+func ChecksumFile(filePath string, chunkSize int64, algorithm sharedModels.ChecksumAlgorithm) (string, []string, error) {
+	if chunkSize <= 0 {
+		return "", nil, fmt.Errorf("invalid chunk size %d", chunkSize)
+	}
+	partHash, err := algorithm.NewHash()
+	if err != nil {
+		return "", nil, err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", nil, err
+	}
+	defer file.Close()
+
+	fileHash := sha256.New()
+	var parts []string
+	for {
+		partHash.Reset()
+		n, err := io.CopyN(io.MultiWriter(fileHash, partHash), file, chunkSize)
+		if n > 0 {
+			parts = append(parts, base64.StdEncoding.EncodeToString(partHash.Sum(nil)))
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	return hex.EncodeToString(fileHash.Sum(nil)), parts, nil
+}
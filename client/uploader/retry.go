@@ -0,0 +1,111 @@
+package uploader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how failed part uploads are retried
+type RetryPolicy struct {
+	MaxAttempts       int           // Total attempts per part, including the first
+	BaseDelay         time.Duration // Delay before the first retry, doubled on each attempt
+	MaxDelay          time.Duration // Upper bound for the delay between attempts
+	Jitter            float64       // Fraction of the delay randomized away, 0 to 1
+	RetryableStatuses []int         // S3 HTTP status codes considered transient
+}
+
+// DefaultRetryableStatuses are the S3 responses worth retrying
+var DefaultRetryableStatuses = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable, // SlowDown
+	http.StatusGatewayTimeout,
+}
+
+// StatusError is returned when S3 rejects a part upload
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("upload failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+// withDefaults fills in unset fields
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 5
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = 500 * time.Millisecond
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = 30 * time.Second
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		p.Jitter = 0.5
+	}
+	if p.RetryableStatuses == nil {
+		p.RetryableStatuses = DefaultRetryableStatuses
+	}
+	return p
+}
+
+// Backoff returns the delay before the retry following the given attempt
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	// Spread retries from many clients so they don't hit S3 in lockstep
+	if p.Jitter > 0 {
+		delay -= time.Duration(rand.Float64() * p.Jitter * float64(delay))
+	}
+	return delay
+}
+
+// IsRetryable reports whether a part upload error is transient: a
+// retryable S3 status, an expired URL, a URL request the server didn't
+// answer, a timeout or a dropped connection. Refusals by the server and
+// other network failures, such as a bad certificate, fail fast.
+func (p RetryPolicy) IsRetryable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		for _, status := range p.RetryableStatuses {
+			if statusErr.StatusCode == status {
+				return true
+			}
+		}
+		return false
+	}
+
+	if errors.Is(err, ErrPartURLsRefused) {
+		return false
+	}
+	if errors.Is(err, errURLExpired) || errors.Is(err, errURLRequest) {
+		return true
+	}
+
+	// Timeouts, connection resets and truncated responses
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
@@ -2,6 +2,10 @@ package uploader
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -17,8 +21,37 @@ import (
 type Uploader struct {
 	filePath     string
 	uploadConfig sharedModels.UploadConfig
+	urlProvider  URLProvider
+	urls         map[int]sharedModels.PresignedURL // part number -> presigned URL
+	urlWindow    int
+	concurrency  int
+	retryPolicy  RetryPolicy
+	onRetry      RetryCallback
+	onPart       PartCallback
+	completed    map[int]string // Parts uploaded by an earlier run, part number -> ETag
+	retries      int
 	client       *http.Client
 	mu           sync.RWMutex
+	fetchMu      sync.Mutex // Serializes presigned URL requests
+}
+
+// Options tunes how an upload is performed
+type Options struct {
+	Concurrency int // Number of parts uploaded in parallel
+	Retry       RetryPolicy
+}
+
+// RetryCallback is called before a failed part upload is retried
+type RetryCallback func(partNumber, attempt int, delay time.Duration, err error)
+
+// PartCallback is called as soon as a part has been uploaded, in completion order
+type PartCallback func(partNumber int, etag string)
+
+// URLProvider fetches fresh presigned URLs for parts of an upload. Errors
+// wrapping ErrPartURLsRefused mean the server refused the request, e.g. the
+// upload was cancelled, and fail the upload without retrying.
+type URLProvider interface {
+	RequestPartURLs(uploadID string, partNumbers []int) ([]sharedModels.PresignedURL, error)
 }
 
 // UploadResult contains the result of an upload
@@ -29,26 +62,84 @@ type UploadResult struct {
 	TotalParts     int
 	CompletedParts int
 	ETags          map[int]string
+	Checksums      map[int]string // Part checksums of the parts uploaded by this run
 	Error          error
 	Duration       time.Duration
+	Retries        int
 }
 
 // ProgressCallback is called during upload progress
 type ProgressCallback func(partNumber, totalParts int, bytesUploaded, totalBytes int64)
 
+// ErrPartURLsRefused is wrapped by URLProvider errors the server answered
+// with a refusal, as opposed to failing to answer
+var ErrPartURLsRefused = errors.New("presigned URLs refused")
+
+var (
+	// errURLExpired is returned by uploadPart when S3 rejects an expired presigned URL
+	errURLExpired = errors.New("presigned URL expired")
+	// errURLRequest is returned when fresh presigned URLs could not be obtained
+	errURLRequest = errors.New("failed to request presigned URLs")
+)
+
+// urlExpiryMargin is how long before its expiry a presigned URL is refreshed
+const urlExpiryMargin = 30 * time.Second
+
+// defaultURLWindow is the number of URLs requested at once when the server
+// did not send an initial window
+const defaultURLWindow = 10
+
 // NewUploader creates a new uploader
-func NewUploader(filePath string, uploadConfig sharedModels.UploadConfig) *Uploader {
+func NewUploader(filePath string, uploadConfig sharedModels.UploadConfig, urlProvider URLProvider, opts Options) *Uploader {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+
+	urls := make(map[int]sharedModels.PresignedURL, len(uploadConfig.PresignedURLs))
+	for _, presignedURL := range uploadConfig.PresignedURLs {
+		urls[presignedURL.PartNumber] = presignedURL
+	}
+
+	urlWindow := len(uploadConfig.PresignedURLs)
+	if urlWindow == 0 {
+		urlWindow = defaultURLWindow
+	}
+
 	return &Uploader{
 		filePath:     filePath,
 		uploadConfig: uploadConfig,
+		urlProvider:  urlProvider,
+		urls:         urls,
+		urlWindow:    urlWindow,
+		concurrency:  opts.Concurrency,
+		retryPolicy:  opts.Retry.withDefaults(),
 		client: &http.Client{
 			Timeout: 10 * time.Minute, // Long timeout for large files
 		},
 	}
 }
 
+// SetRetryCallback sets the function notified whenever a part is retried
+func (u *Uploader) SetRetryCallback(callback RetryCallback) {
+	u.onRetry = callback
+}
+
+// SetPartCallback sets the function notified whenever a part is uploaded
+func (u *Uploader) SetPartCallback(callback PartCallback) {
+	u.onPart = callback
+}
+
+// SetCompletedParts marks parts that were already uploaded so Upload skips them
+func (u *Uploader) SetCompletedParts(etags map[int]string) {
+	u.completed = make(map[int]string, len(etags))
+	for partNumber, etag := range etags {
+		u.completed[partNumber] = etag
+	}
+}
+
 // Upload uploads the file using multipart upload with presigned URLs
-func (u *Uploader) Upload(progressCallback ProgressCallback) (*UploadResult, error) {
+// and stops as soon as ctx is cancelled, aborting part PUTs in flight
+func (u *Uploader) Upload(ctx context.Context, progressCallback ProgressCallback) (*UploadResult, error) {
 	startTime := time.Now()
 
 	// Open file
@@ -67,63 +158,133 @@ func (u *Uploader) Upload(progressCallback ProgressCallback) (*UploadResult, err
 
 	log.Printf("Uploading file: %s (%.2f MB)", u.filePath, float64(fileSize)/(1024*1024))
 	log.Printf("Upload ID: %s", u.uploadConfig.UploadID)
-	
+
 	// Calculate actual number of parts needed based on file size
-	actualPartsNeeded := int((fileSize + u.uploadConfig.ChunkSize - 1) / u.uploadConfig.ChunkSize)
-	if actualPartsNeeded > len(u.uploadConfig.PresignedURLs) {
-		return nil, fmt.Errorf("not enough presigned URLs: need %d, have %d", actualPartsNeeded, len(u.uploadConfig.PresignedURLs))
+	totalParts := CalculateParts(fileSize, u.uploadConfig.ChunkSize)
+	if u.uploadConfig.TotalParts > 0 && totalParts != u.uploadConfig.TotalParts {
+		return nil, fmt.Errorf("part count mismatch: file needs %d parts, upload was initiated with %d", totalParts, u.uploadConfig.TotalParts)
 	}
-	
-	log.Printf("Total parts: %d (using %d out of %d presigned URLs)", actualPartsNeeded, actualPartsNeeded, len(u.uploadConfig.PresignedURLs))
 
-	// Upload each part
+	log.Printf("Total parts: %d (%d presigned URLs received up front)", totalParts, len(u.uploadConfig.PresignedURLs))
+
+	// Parts uploaded by an earlier run are counted as done up front
 	etags := make(map[int]string)
-	var bytesUploaded int64
-	var mu sync.Mutex
+	checksums := make(map[int]string)
+	finished := make(map[int]int64) // part number -> size, not yet reported
+	pending := make([]int, 0, totalParts)
+	for partNumber := 1; partNumber <= totalParts; partNumber++ {
+		if etag, ok := u.completed[partNumber]; ok {
+			etags[partNumber] = etag
+			finished[partNumber] = partSizeOf(partNumber, u.uploadConfig.ChunkSize, fileSize)
+			continue
+		}
+		pending = append(pending, partNumber)
+	}
+	if len(etags) > 0 {
+		log.Printf("Resuming: %d/%d parts already uploaded", len(etags), totalParts)
+	}
 
-	// Only use the number of presigned URLs we actually need
-	presignedURLsToUse := u.uploadConfig.PresignedURLs[:actualPartsNeeded]
+	// Feed part numbers to a bounded pool of workers; each worker holds at
+	// most one chunk in memory at a time
+	concurrency := u.concurrency
+	if concurrency > len(pending) {
+		concurrency = len(pending)
+	}
+	log.Printf("Uploading with %d concurrent workers", concurrency)
 
-	for _, presignedURL := range presignedURLsToUse {
-		partNumber := presignedURL.PartNumber
+	// The first failure stops the remaining parts, like a cancellation does
+	uploadCtx, stop := context.WithCancel(ctx)
+	defer stop()
 
-		// Calculate part size
-		partSize := u.uploadConfig.ChunkSize
-		offset := int64(partNumber-1) * u.uploadConfig.ChunkSize
+	jobs := make(chan int)
+	results := make(chan partResult)
+	var workersWg sync.WaitGroup
 
-		// Adjust for last part
-		if offset+partSize > fileSize {
-			partSize = fileSize - offset
+	go func() {
+		defer close(jobs)
+		for _, partNumber := range pending {
+			select {
+			case jobs <- partNumber:
+			case <-uploadCtx.Done():
+				return
+			}
 		}
+	}()
 
-		// Read part data
-		partData := make([]byte, partSize)
-		if _, err := file.ReadAt(partData, offset); err != nil && err != io.EOF {
-			return nil, fmt.Errorf("failed to read part %d: %w", partNumber, err)
-		}
+	for i := 0; i < concurrency; i++ {
+		workersWg.Add(1)
+		go func() {
+			defer workersWg.Done()
+			buf := make([]byte, u.uploadConfig.ChunkSize)
+			for partNumber := range jobs {
+				results <- u.uploadFilePart(uploadCtx, file, buf, partNumber, totalParts, fileSize)
+			}
+		}()
+	}
+
+	go func() {
+		workersWg.Wait()
+		close(results)
+	}()
+
+	// Collect results, reporting progress in part order even though parts
+	// may finish out of order
+	nextPart := 1
+	var bytesUploaded int64
+	var uploadErr error
 
-		// Upload part
-		log.Printf("Uploading part %d/%d (%.2f MB)", partNumber, actualPartsNeeded, float64(partSize)/(1024*1024))
+	// Skip over the leading run of previously uploaded parts without reporting
+	for size, ok := finished[nextPart]; ok; size, ok = finished[nextPart] {
+		delete(finished, nextPart)
+		bytesUploaded += size
+		nextPart++
+	}
 
-		etag, err := u.uploadPart(presignedURL.URL, partData)
-		if err != nil {
-			return nil, fmt.Errorf("failed to upload part %d: %w", partNumber, err)
+	for result := range results {
+		if result.err != nil {
+			if uploadErr == nil {
+				uploadErr = fmt.Errorf("failed to upload part %d: %w", result.partNumber, result.err)
+				stop()
+			}
+			continue
 		}
 
-		// Store ETag
-		mu.Lock()
-		etags[partNumber] = etag
-		bytesUploaded += partSize
-		mu.Unlock()
+		etags[result.partNumber] = result.etag
+		if result.checksum != "" {
+			checksums[result.partNumber] = result.checksum
+		}
+		finished[result.partNumber] = result.size
 
-		log.Printf("✅ Part %d/%d uploaded (ETag: %s)", partNumber, actualPartsNeeded, etag)
+		log.Printf("✅ Part %d/%d uploaded (ETag: %s)", result.partNumber, totalParts, result.etag)
 
-		// Call progress callback
-		if progressCallback != nil {
-			progressCallback(partNumber, actualPartsNeeded, bytesUploaded, fileSize)
+		if u.onPart != nil {
+			u.onPart(result.partNumber, result.etag)
+		}
+
+		for size, ok := finished[nextPart]; ok; size, ok = finished[nextPart] {
+			delete(finished, nextPart)
+			bytesUploaded += size
+
+			// Call progress callback
+			if progressCallback != nil && uploadErr == nil {
+				progressCallback(nextPart, totalParts, bytesUploaded, fileSize)
+			}
+			nextPart++
 		}
 	}
 
+	if ctx.Err() != nil {
+		log.Printf("🛑 Upload %s cancelled", u.uploadConfig.UploadID)
+		return nil, ctx.Err()
+	}
+	if uploadErr != nil {
+		return nil, uploadErr
+	}
+
+	u.mu.RLock()
+	retries := u.retries
+	u.mu.RUnlock()
+
 	duration := time.Since(startTime)
 	log.Printf("✅ Upload completed in %v (%.2f MB/s)", duration, float64(fileSize)/(1024*1024)/duration.Seconds())
 
@@ -131,21 +292,203 @@ func (u *Uploader) Upload(progressCallback ProgressCallback) (*UploadResult, err
 		Success:        true,
 		UploadID:       u.uploadConfig.UploadID,
 		FileSize:       fileSize,
-		TotalParts:     actualPartsNeeded,
+		TotalParts:     totalParts,
 		CompletedParts: len(etags),
 		ETags:          etags,
+		Checksums:      checksums,
 		Duration:       duration,
+		Retries:        retries,
 	}, nil
 }
 
-// uploadPart uploads a single part using a presigned URL
-func (u *Uploader) uploadPart(presignedURL string, data []byte) (string, error) {
-	req, err := http.NewRequest(http.MethodPut, presignedURL, bytes.NewReader(data))
+// partResult is the outcome of uploading a single part
+type partResult struct {
+	partNumber int
+	size       int64
+	etag       string
+	checksum   string // Base64, when the upload uses checksums
+	err        error
+}
+
+// uploadFilePart reads a part from the file into buf and uploads it
+func (u *Uploader) uploadFilePart(ctx context.Context, file *os.File, buf []byte, partNumber, totalParts int, fileSize int64) partResult {
+	partSize := partSizeOf(partNumber, u.uploadConfig.ChunkSize, fileSize)
+	offset := int64(partNumber-1) * u.uploadConfig.ChunkSize
+
+	// Read part data; a short read means the file shrank, and the rest of
+	// the pooled buffer still holds another part's bytes
+	partData := buf[:partSize]
+	n, err := file.ReadAt(partData, offset)
+	if err != nil && err != io.EOF {
+		return partResult{partNumber: partNumber, err: fmt.Errorf("failed to read part: %w", err)}
+	}
+	if int64(n) != partSize {
+		return partResult{partNumber: partNumber, err: fmt.Errorf("short read of part %d: got %d of %d bytes, file changed during upload", partNumber, n, partSize)}
+	}
+
+	// Checksum the data as read, for S3 to verify on arrival
+	var checksum string
+	if u.uploadConfig.ChecksumAlgorithm != "" {
+		if checksum, err = PartChecksum(u.uploadConfig.ChecksumAlgorithm, partData); err != nil {
+			return partResult{partNumber: partNumber, err: err}
+		}
+	}
+
+	// Upload part
+	log.Printf("Uploading part %d/%d (%.2f MB)", partNumber, totalParts, float64(partSize)/(1024*1024))
+
+	etag, err := u.uploadPartWithRetry(ctx, partNumber, totalParts, partData, checksum)
+	return partResult{partNumber: partNumber, size: partSize, etag: etag, checksum: checksum, err: err}
+}
+
+// uploadPartWithRetry uploads a part, retrying transient failures according
+// to the retry policy
+func (u *Uploader) uploadPartWithRetry(ctx context.Context, partNumber, totalParts int, data []byte, checksum string) (string, error) {
+	for attempt := 1; ; attempt++ {
+		etag, err := u.uploadPartWithFreshURL(ctx, partNumber, totalParts, data, checksum)
+		if err == nil {
+			return etag, nil
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+
+		if attempt >= u.retryPolicy.MaxAttempts || !u.retryPolicy.IsRetryable(err) {
+			if attempt > 1 {
+				return "", fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+			}
+			return "", err
+		}
+
+		delay := u.retryPolicy.Backoff(attempt)
+		log.Printf("⚠️ Part %d attempt %d/%d failed: %v (retrying in %v)", partNumber, attempt, u.retryPolicy.MaxAttempts, err, delay)
+
+		u.mu.Lock()
+		u.retries++
+		u.mu.Unlock()
+
+		if u.onRetry != nil {
+			u.onRetry(partNumber, attempt, delay, err)
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// uploadPartWithFreshURL uploads a part, requesting a new presigned URL and
+// retrying once if S3 reports that the URL has expired
+func (u *Uploader) uploadPartWithFreshURL(ctx context.Context, partNumber, totalParts int, data []byte, checksum string) (string, error) {
+	presignedURL, err := u.partURL(partNumber, totalParts)
+	if err != nil {
+		return "", err
+	}
+
+	etag, err := u.uploadPart(ctx, presignedURL, data, checksum)
+	if !errors.Is(err, errURLExpired) {
+		return etag, err
+	}
+
+	log.Printf("🔄 Presigned URL for part %d expired, requesting a new one", partNumber)
+	u.invalidateURL(partNumber)
+
+	presignedURL, err = u.partURL(partNumber, totalParts)
+	if err != nil {
+		return "", err
+	}
+	return u.uploadPart(ctx, presignedURL, data, checksum)
+}
+
+// partURL returns a usable presigned URL for the part, fetching a window of
+// URLs from the server when it is missing or about to expire
+func (u *Uploader) partURL(partNumber, totalParts int) (sharedModels.PresignedURL, error) {
+	u.mu.RLock()
+	presignedURL, ok := u.urls[partNumber]
+	u.mu.RUnlock()
+
+	if ok && !urlExpiring(presignedURL) {
+		return presignedURL, nil
+	}
+
+	if u.urlProvider == nil {
+		return sharedModels.PresignedURL{}, fmt.Errorf("no presigned URL for part %d", partNumber)
+	}
+
+	// Only one worker fetches at a time; another may already have fetched this part
+	u.fetchMu.Lock()
+	defer u.fetchMu.Unlock()
+
+	u.mu.RLock()
+	presignedURL, ok = u.urls[partNumber]
+	u.mu.RUnlock()
+	if ok && !urlExpiring(presignedURL) {
+		return presignedURL, nil
+	}
+
+	// Request this part and the following ones that are not yet usable
+	u.mu.RLock()
+	partNumbers := make([]int, 0, u.urlWindow)
+	for p := partNumber; p <= totalParts && len(partNumbers) < u.urlWindow; p++ {
+		if existing, ok := u.urls[p]; !ok || urlExpiring(existing) {
+			partNumbers = append(partNumbers, p)
+		}
+	}
+	u.mu.RUnlock()
+
+	log.Printf("📨 Requesting presigned URLs for parts %v", partNumbers)
+	presignedURLs, err := u.urlProvider.RequestPartURLs(u.uploadConfig.UploadID, partNumbers)
+	if err != nil {
+		return sharedModels.PresignedURL{}, fmt.Errorf("%w: %w", errURLRequest, err)
+	}
+
+	u.mu.Lock()
+	for _, url := range presignedURLs {
+		u.urls[url.PartNumber] = url
+	}
+	presignedURL, ok = u.urls[partNumber]
+	u.mu.Unlock()
+
+	if !ok {
+		return sharedModels.PresignedURL{}, fmt.Errorf("server did not return a presigned URL for part %d", partNumber)
+	}
+	return presignedURL, nil
+}
+
+// invalidateURL forgets the presigned URL for a part
+func (u *Uploader) invalidateURL(partNumber int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	delete(u.urls, partNumber)
+}
+
+// urlExpiring reports whether a presigned URL is expired or about to expire
+func urlExpiring(presignedURL sharedModels.PresignedURL) bool {
+	if presignedURL.ExpiresAt.IsZero() {
+		return false
+	}
+	return time.Now().Add(urlExpiryMargin).After(presignedURL.ExpiresAt)
+}
+
+// uploadPart uploads a single part using a presigned URL; with a checksum,
+// S3 rejects the part unless the data matches it
+func (u *Uploader) uploadPart(ctx context.Context, presignedURL sharedModels.PresignedURL, data []byte, checksum string) (string, error) {
+	// The URL is signed for the checksum taken at stat time
+	if presignedURL.Checksum != "" && presignedURL.Checksum != checksum {
+		return "", fmt.Errorf("%w: part %d changed since the file was stat'ed", ErrChecksumMismatch, presignedURL.PartNumber)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, presignedURL.URL, bytes.NewReader(data))
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/octet-stream")
+	if checksum != "" {
+		req.Header.Set(ChecksumHeader(u.uploadConfig.ChecksumAlgorithm), checksum)
+	}
 	req.ContentLength = int64(len(data))
 
 	resp, err := u.client.Do(req)
@@ -154,9 +497,14 @@ func (u *Uploader) uploadPart(presignedURL string, data []byte) (string, error)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusForbidden {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("%w: status %d: %s", errURLExpired, resp.StatusCode, string(body))
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("upload failed with status %d: %s", resp.StatusCode, string(body))
+		return "", &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	// Get ETag from response header
@@ -165,6 +513,11 @@ func (u *Uploader) uploadPart(presignedURL string, data []byte) (string, error)
 		return "", fmt.Errorf("no ETag in response")
 	}
 
+	// S3 echoes the checksum it verified
+	if stored := resp.Header.Get(ChecksumHeader(u.uploadConfig.ChecksumAlgorithm)); checksum != "" && stored != "" && stored != checksum {
+		return "", fmt.Errorf("%w: S3 stored part %d with checksum %s, expected %s", ErrChecksumMismatch, presignedURL.PartNumber, stored, checksum)
+	}
+
 	return etag, nil
 }
 
@@ -177,6 +530,30 @@ func GetFileSize(filePath string) (int64, error) {
 	return info.Size(), nil
 }
 
+// HashFile returns the hex-encoded SHA-256 of the file
+func HashFile(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// partSizeOf returns the size of a part, the last one being shorter
+func partSizeOf(partNumber int, chunkSize, fileSize int64) int64 {
+	offset := int64(partNumber-1) * chunkSize
+	if offset+chunkSize > fileSize {
+		return fileSize - offset
+	}
+	return chunkSize
+}
+
 // CalculateParts calculates the number of parts needed for a file
 func CalculateParts(fileSize, chunkSize int64) int {
 	parts := int(fileSize / chunkSize)
@@ -2,6 +2,9 @@ package websocket
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -10,18 +13,33 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/iriyanto1027/file-download-system/shared/auth"
 	sharedModels "github.com/iriyanto1027/file-download-system/shared/models"
 )
 
+const (
+	// tokenRefreshTimeout is how long to wait for the server to refresh the token
+	tokenRefreshTimeout = 30 * time.Second
+
+	// tokenRefreshRetry is how long to wait before retrying a failed refresh
+	tokenRefreshRetry = time.Minute
+)
+
 // Client represents a WebSocket client
 type Client struct {
 	clientID       string
 	serverURL      string
 	token          string
+	onTokenRefresh func(token string)
+	clientBuild    string
+	labels         map[string]string
+	tlsConfig      *tls.Config
 	conn           *websocket.Conn
 	mu             sync.RWMutex
-	writeMu        sync.Mutex // Protects concurrent writes
-	handlersWg     sync.WaitGroup // Tracks active message handlers
+	writeMu        sync.Mutex                                    // Protects concurrent writes
+	handlersWg     sync.WaitGroup                                // Tracks active message handlers
+	pending        map[string]chan *sharedModels.ResponseMessage // Requests awaiting a server reply
+	pendingMu      sync.Mutex
 	reconnectDelay time.Duration
 	maxReconnect   time.Duration
 	messageHandler MessageHandler
@@ -35,11 +53,27 @@ type MessageHandler interface {
 	HandleCommand(cmd *sharedModels.CommandMessage) error
 }
 
+// ConnectHandler is optionally implemented by a MessageHandler that needs to
+// act every time a connection is established
+type ConnectHandler interface {
+	HandleConnected()
+}
+
+// CapabilityProvider is optionally implemented by a MessageHandler to
+// announce the actions it handles in the hello sent on every connect
+type CapabilityProvider interface {
+	Capabilities() sharedModels.ClientCapabilities
+}
+
 // Config contains the client configuration
 type Config struct {
 	ClientID       string
 	ServerURL      string
 	Token          string
+	OnTokenRefresh func(token string) // Called with each refreshed token, e.g. to persist it
+	ClientBuild    string
+	Labels         map[string]string // Advertised in the hello so operators can select this client
+	TLSConfig      *tls.Config       // Client certificate and server CAs for wss:// URLs; defaults if nil
 	ReconnectDelay time.Duration
 	MaxReconnect   time.Duration
 }
@@ -56,11 +90,16 @@ func NewClient(cfg Config, handler MessageHandler) *Client {
 	return &Client{
 		clientID:       cfg.ClientID,
 		serverURL:      cfg.ServerURL,
+		labels:         cfg.Labels,
+		tlsConfig:      cfg.TLSConfig,
 		token:          cfg.Token,
+		onTokenRefresh: cfg.OnTokenRefresh,
+		clientBuild:    cfg.ClientBuild,
 		reconnectDelay: cfg.ReconnectDelay,
 		maxReconnect:   cfg.MaxReconnect,
 		messageHandler: handler,
 		connected:      false,
+		pending:        make(map[string]chan *sharedModels.ResponseMessage),
 		stopChan:       make(chan struct{}),
 		doneChan:       make(chan struct{}),
 	}
@@ -81,16 +120,23 @@ func (c *Client) Connect(ctx context.Context) error {
 		return fmt.Errorf("invalid server URL: %w", err)
 	}
 
+	c.mu.RLock()
+	token := c.token
+	c.mu.RUnlock()
+
 	q := u.Query()
 	q.Set("client_id", c.clientID)
-	if c.token != "" {
-		q.Set("token", c.token)
+	if token != "" {
+		q.Set("token", token)
 	}
 	u.RawQuery = q.Encode()
 
 	// Connect to WebSocket
 	log.Printf("Connecting to %s", u.String())
-	conn, _, err := websocket.DefaultDialer.DialContext(ctx, u.String(), nil)
+	dialer := *websocket.DefaultDialer
+	dialer.EnableCompression = true
+	dialer.TLSClientConfig = c.tlsConfig
+	conn, _, err := dialer.DialContext(ctx, u.String(), nil)
 	if err != nil {
 		return fmt.Errorf("failed to connect: %w", err)
 	}
@@ -103,9 +149,29 @@ func (c *Client) Connect(ctx context.Context) error {
 
 	log.Printf("✅ Connected to server as client: %s", c.clientID)
 
+	// Announce our capabilities before anything else is sent
+	if err := c.sendHello(conn); err != nil {
+		c.disconnect()
+		return fmt.Errorf("failed to send hello: %w", err)
+	}
+
 	// Start read and write routines
+	done := c.doneChan
 	go c.readPump(ctx)
 	go c.writePump(ctx)
+	go c.refreshTokenLoop(ctx, done)
+
+	// Notify the handler, e.g. to resume interrupted uploads
+	c.mu.RLock()
+	connectHandler, ok := c.messageHandler.(ConnectHandler)
+	c.mu.RUnlock()
+	if ok {
+		c.handlersWg.Add(1)
+		go func() {
+			defer c.handlersWg.Done()
+			connectHandler.HandleConnected()
+		}()
+	}
 
 	return nil
 }
@@ -175,13 +241,112 @@ func (c *Client) SetMessageHandler(handler MessageHandler) {
 	c.messageHandler = handler
 }
 
+// sendHello tells the server which protocol version and actions this build supports
+func (c *Client) sendHello(conn *websocket.Conn) error {
+	c.mu.RLock()
+	provider, ok := c.messageHandler.(CapabilityProvider)
+	c.mu.RUnlock()
+
+	var capabilities sharedModels.ClientCapabilities
+	if ok {
+		capabilities = provider.Capabilities()
+	}
+	capabilities.ProtocolVersion = sharedModels.ProtocolVersion
+	capabilities.ClientBuild = c.clientBuild
+	capabilities.Labels = c.labels
+	capabilities.Compression = true
+
+	hello := &sharedModels.HelloMessage{
+		WebSocketMessage: sharedModels.WebSocketMessage{
+			Type:      sharedModels.MessageTypeHello,
+			Timestamp: time.Now(),
+		},
+		ClientCapabilities: capabilities,
+	}
+
+	log.Printf("👋 Sending hello: protocol v%d, build %q, actions %v",
+		capabilities.ProtocolVersion, capabilities.ClientBuild, capabilities.SupportedActions)
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return conn.WriteJSON(hello)
+}
+
+// refreshTokenLoop replaces the token once 80% of its lifetime has passed,
+// so it never expires between reconnects; it stops when done is closed
+func (c *Client) refreshTokenLoop(ctx context.Context, done chan struct{}) {
+	var retry bool
+	for {
+		c.mu.RLock()
+		token := c.token
+		c.mu.RUnlock()
+		if token == "" {
+			return
+		}
+
+		issuedAt, expiresAt, err := auth.TokenLifetime(token)
+		if err != nil {
+			log.Printf("⚠️ Token can't be refreshed: %v", err)
+			return
+		}
+		if !time.Now().Before(expiresAt) {
+			log.Printf("❌ Token expired at %s without being refreshed", expiresAt.Format(time.RFC3339))
+			return
+		}
+
+		refreshAt := expiresAt.Add(-5 * time.Minute)
+		if !issuedAt.IsZero() {
+			refreshAt = expiresAt.Add(-expiresAt.Sub(issuedAt) / 5)
+		}
+		wait := time.Until(refreshAt)
+		if retry {
+			wait = max(wait, tokenRefreshRetry)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-done:
+			timer.Stop()
+			return
+		case <-c.stopChan:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		retry = true
+		resp, err := c.Request(sharedModels.CommandActionRefreshToken, sharedModels.RefreshTokenPayload{Token: token}, tokenRefreshTimeout)
+		if err != nil {
+			log.Printf("⚠️ Failed to refresh token, retrying in %v: %v", tokenRefreshRetry, err)
+			continue
+		}
+		var refreshed sharedModels.RefreshTokenResponse
+		if err := resp.DecodePayload(&refreshed); err != nil {
+			log.Printf("⚠️ Invalid token refresh reply, retrying in %v: %v", tokenRefreshRetry, err)
+			continue
+		}
+		retry = false
+
+		c.mu.Lock()
+		c.token = refreshed.Token
+		c.mu.Unlock()
+		log.Printf("🔑 Token refreshed, valid until %s", refreshed.ExpiresAt.Format(time.RFC3339))
+
+		if c.onTokenRefresh != nil {
+			c.onTokenRefresh(refreshed.Token)
+		}
+	}
+}
+
 // disconnect closes the connection
 func (c *Client) disconnect() {
 	log.Printf("disconnect: called, waiting for active handlers...")
 	// Wait for all active message handlers to complete
 	c.handlersWg.Wait()
 	log.Printf("disconnect: all handlers done, proceeding with disconnect")
-	
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -295,7 +460,7 @@ func (c *Client) handleMessage(message []byte) error {
 	connectedStatus := c.connected
 	c.mu.RUnlock()
 	log.Printf("handleMessage: start, conn=%v, connected=%v, client=%p", connStatus, connectedStatus, c)
-	
+
 	// Parse base message to determine type
 	var baseMsg sharedModels.WebSocketMessage
 	if err := json.Unmarshal(message, &baseMsg); err != nil {
@@ -312,6 +477,32 @@ func (c *Client) handleMessage(message []byte) error {
 			return c.messageHandler.HandleCommand(&cmdMsg)
 		}
 
+	case sharedModels.MessageTypeResponse:
+		var respMsg sharedModels.ResponseMessage
+		if err := json.Unmarshal(message, &respMsg); err != nil {
+			return fmt.Errorf("failed to parse response message: %w", err)
+		}
+		c.pendingMu.Lock()
+		respChan, ok := c.pending[respMsg.CommandID]
+		delete(c.pending, respMsg.CommandID)
+		c.pendingMu.Unlock()
+		if !ok {
+			log.Printf("Received response for unknown request: %s", respMsg.CommandID)
+			return nil
+		}
+		respChan <- &respMsg
+
+	case sharedModels.MessageTypeHelloAck:
+		var ackMsg sharedModels.HelloAckMessage
+		if err := json.Unmarshal(message, &ackMsg); err != nil {
+			return fmt.Errorf("failed to parse hello ack: %w", err)
+		}
+		if !ackMsg.Accepted {
+			log.Printf("❌ Server rejected hello: %s", ackMsg.Error)
+			return nil
+		}
+		log.Printf("🤝 Server accepted hello, using protocol v%d", ackMsg.ProtocolVersion)
+
 	case sharedModels.MessageTypePing:
 		// Respond with pong
 		return c.SendPong()
@@ -325,32 +516,41 @@ func (c *Client) handleMessage(message []byte) error {
 
 // SendResponse sends a response message to the server
 func (c *Client) SendResponse(status sharedModels.ResponseStatus, commandID string, action sharedModels.CommandAction, payload interface{}, errMsg string) error {
+	response, err := sharedModels.NewResponseMessage(status, commandID, action, payload, errMsg)
+	if err != nil {
+		log.Printf("❌ Failed to build response: %v", err)
+		return err
+	}
+	return c.sendResponse(response)
+}
+
+// SendError sends an error response classified by code, which the server
+// records on the upload
+func (c *Client) SendError(commandID string, action sharedModels.CommandAction, code sharedModels.ErrorCode, errMsg string) error {
+	response, err := sharedModels.NewResponseMessage(sharedModels.ResponseStatusError, commandID, action, nil, errMsg)
+	if err != nil {
+		log.Printf("❌ Failed to build response: %v", err)
+		return err
+	}
+	response.ErrorCode = code
+	return c.sendResponse(response)
+}
+
+// sendResponse writes a built response to the connection
+func (c *Client) sendResponse(response *sharedModels.ResponseMessage) error {
 	c.mu.RLock()
 	conn := c.conn
 	connected := c.connected
 	c.mu.RUnlock()
 
 	log.Printf("📤 SendResponse: conn=%v, connected=%v, client=%p", conn != nil, connected, c)
-	
+
 	if conn == nil {
 		log.Printf("❌ SendResponse failed: connection is nil")
 		return fmt.Errorf("not connected")
 	}
 
-	response := &sharedModels.ResponseMessage{
-		WebSocketMessage: sharedModels.WebSocketMessage{
-			Type:      sharedModels.MessageTypeResponse,
-			Timestamp: time.Now(),
-			MessageID: commandID,
-		},
-		Status:    status,
-		CommandID: commandID,
-		Action:    action,
-		Payload:   payload,
-		Error:     errMsg,
-	}
-
-	log.Printf("📤 Sending response: status=%s, action=%s", status, action)
+	log.Printf("📤 Sending response: status=%s, action=%s", response.Status, response.Action)
 	c.writeMu.Lock()
 	defer c.writeMu.Unlock()
 	err := conn.WriteJSON(response)
@@ -362,6 +562,56 @@ func (c *Client) SendResponse(status sharedModels.ResponseStatus, commandID stri
 	return err
 }
 
+// Request sends a request to the server and waits for its reply
+func (c *Client) Request(action sharedModels.CommandAction, payload interface{}, timeout time.Duration) (*sharedModels.ResponseMessage, error) {
+	c.mu.RLock()
+	conn := c.conn
+	c.mu.RUnlock()
+
+	if conn == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+
+	requestID, err := generateRequestID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate request ID: %w", err)
+	}
+
+	respChan := make(chan *sharedModels.ResponseMessage, 1)
+	c.pendingMu.Lock()
+	c.pending[requestID] = respChan
+	c.pendingMu.Unlock()
+
+	defer func() {
+		c.pendingMu.Lock()
+		delete(c.pending, requestID)
+		c.pendingMu.Unlock()
+	}()
+
+	request, err := sharedModels.NewRequestMessage(action, requestID, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("📤 Sending request: action=%s, id=%s", action, requestID)
+	c.writeMu.Lock()
+	err = conn.WriteJSON(request)
+	c.writeMu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	select {
+	case resp := <-respChan:
+		if resp.Status == sharedModels.ResponseStatusError {
+			return resp, fmt.Errorf("server rejected %s: %s", action, resp.Error)
+		}
+		return resp, nil
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("timed out waiting for %s reply", action)
+	}
+}
+
 // SendStatus sends a status message to the server
 func (c *Client) SendStatus(status string, currentUpload *sharedModels.UploadStatus, systemInfo *sharedModels.SystemInfo) error {
 	c.mu.RLock()
@@ -416,6 +666,15 @@ func (c *Client) SendPong() error {
 	return conn.WriteJSON(pong)
 }
 
+// generateRequestID generates a random request ID
+func generateRequestID() (string, error) {
+	bytes := make([]byte, 8)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
 // IsConnected returns whether the client is connected
 func (c *Client) IsConnected() bool {
 	c.mu.RLock()
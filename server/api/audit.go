@@ -0,0 +1,195 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/iriyanto1027/file-download-system/server/audit"
+	"github.com/iriyanto1027/file-download-system/server/models"
+)
+
+// ListAuditResponse is the response for listing audit entries
+type ListAuditResponse struct {
+	Entries    []*models.AuditEntry `json:"entries"`
+	Count      int                  `json:"count"`
+	NextCursor string               `json:"next_cursor,omitempty"` // Pass as cursor to get the next page
+}
+
+// auditEntryKey is the request context key for the entry Require records
+type auditEntryKey struct{}
+
+// statusRecorder remembers the status an endpoint responded with
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader implements http.ResponseWriter
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// HandleAudit handles GET /audit?action=&client_id=&actor=&since=&until=&limit=&cursor=&format=
+// Entries are listed oldest first; format=jsonl exports every matching
+// entry as JSON lines instead of a page.
+func (h *Handler) HandleAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if h.auditLog == nil {
+		h.sendError(w, http.StatusServiceUnavailable, "Audit log is not configured")
+		return
+	}
+
+	query := r.URL.Query()
+	filter := audit.Filter{
+		Action:   models.AuditAction(query.Get("action")),
+		ClientID: query.Get("client_id"),
+		Actor:    query.Get("actor"),
+	}
+	if filter.Action != "" && !filter.Action.IsValid() {
+		h.sendError(w, http.StatusBadRequest, fmt.Sprintf("unknown action %q", filter.Action))
+		return
+	}
+
+	var err error
+	if since := query.Get("since"); since != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			h.sendError(w, http.StatusBadRequest, fmt.Sprintf("since must be an RFC 3339 time: %v", err))
+			return
+		}
+	}
+	if until := query.Get("until"); until != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			h.sendError(w, http.StatusBadRequest, fmt.Sprintf("until must be an RFC 3339 time: %v", err))
+			return
+		}
+	}
+	if cursor := query.Get("cursor"); cursor != "" {
+		if filter.AfterID, err = strconv.ParseUint(cursor, 10, 64); err != nil {
+			h.sendError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+	}
+
+	switch format := query.Get("format"); format {
+	case "", "json":
+	case "jsonl":
+		h.exportAudit(w, filter)
+		return
+	default:
+		h.sendError(w, http.StatusBadRequest, fmt.Sprintf("format must be json or jsonl, not %q", format))
+		return
+	}
+
+	limit := defaultListLimit
+	if value := query.Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			h.sendError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxListLimit))
+			return
+		}
+	}
+
+	// Read one entry past the page to know whether there is another
+	entries := make([]*models.AuditEntry, 0, limit+1)
+	err = h.auditLog.Query(filter, func(entry *models.AuditEntry) bool {
+		entries = append(entries, entry)
+		return len(entries) <= limit
+	})
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to read audit log: %v", err))
+		return
+	}
+
+	response := ListAuditResponse{Entries: entries}
+	if len(entries) > limit {
+		response.Entries = entries[:limit]
+		response.NextCursor = strconv.FormatUint(entries[limit-1].ID, 10)
+	}
+	response.Count = len(response.Entries)
+
+	h.sendJSON(w, http.StatusOK, response)
+}
+
+// auditExportPageSize is how many entries an export reads per store scan
+const auditExportPageSize = 500
+
+// exportAudit streams every matching entry as one JSON object per line. It
+// reads a page at a time and writes it after the scan ends, so a slow HTTP
+// client never holds the store's read transaction open.
+func (h *Handler) exportAudit(w http.ResponseWriter, filter audit.Filter) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit.jsonl"`)
+	w.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(w)
+	page := make([]*models.AuditEntry, 0, auditExportPageSize)
+	for {
+		page = page[:0]
+		err := h.auditLog.Query(filter, func(entry *models.AuditEntry) bool {
+			page = append(page, entry)
+			return len(page) < auditExportPageSize
+		})
+		if err != nil {
+			// The status is already sent; the export just ends early
+			log.Printf("❌ Failed to export audit log: %v", err)
+			return
+		}
+
+		for _, entry := range page {
+			if err := encoder.Encode(entry); err != nil {
+				return
+			}
+		}
+		if len(page) < auditExportPageSize {
+			return
+		}
+		filter.AfterID = page[len(page)-1].ID
+	}
+}
+
+// auditEntry returns the entry Require records for the request, so an
+// endpoint can add what it acted on. Requests that aren't audited get an
+// entry that is discarded.
+func auditEntry(r *http.Request) *models.AuditEntry {
+	if entry, ok := r.Context().Value(auditEntryKey{}).(*models.AuditEntry); ok {
+		return entry
+	}
+	return &models.AuditEntry{}
+}
+
+// newAuditEntry describes an API call by the principal, or an anonymous one
+func newAuditEntry(r *http.Request, principal *Principal, status int) models.AuditEntry {
+	entry := models.AuditEntry{
+		Action:     models.AuditActionAPICall,
+		Actor:      "anonymous",
+		RemoteAddr: r.RemoteAddr,
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		StatusCode: status,
+		Result:     auditResult(status),
+	}
+	if principal != nil {
+		entry.Actor = principal.Name
+		entry.Role = string(principal.Role)
+	}
+	return entry
+}
+
+// auditResult maps a response status to the outcome of the call
+func auditResult(status int) models.AuditResult {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return models.AuditResultDenied
+	case status >= http.StatusBadRequest:
+		return models.AuditResultFailed
+	}
+	return models.AuditResultSuccess
+}
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/iriyanto1027/file-download-system/shared/auth"
+)
+
+var (
+	// ErrNoCredentials is returned when a request carries no API key or token
+	ErrNoCredentials = errors.New("no credentials")
+
+	// ErrInvalidCredentials is returned for an unknown API key or an invalid
+	// operator token
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// AuthMode controls whether operators must authenticate to use the HTTP API
+type AuthMode string
+
+const (
+	// AuthModeOptional lets requests without credentials through; credentials
+	// that are sent are still checked
+	AuthModeOptional AuthMode = "optional"
+
+	// AuthModeEnforce rejects requests without an API key or operator token
+	AuthModeEnforce AuthMode = "enforce"
+)
+
+// IsValid reports whether the mode is one of the known API auth modes
+func (m AuthMode) IsValid() bool {
+	return m == AuthModeOptional || m == AuthModeEnforce
+}
+
+// APIKey grants an operator a role on the HTTP API
+type APIKey struct {
+	Name string    // Who uses the key, e.g. "dashboard"
+	Role auth.Role // What the key may do
+	Key  string    // The secret sent as a bearer token or X-API-Key
+}
+
+// Principal is the authenticated operator behind a request
+type Principal struct {
+	Name string    `json:"name"`
+	Role auth.Role `json:"role"`
+	Via  string    `json:"via"` // "api_key" or "token"
+}
+
+// Authenticator checks operator credentials on the HTTP API: static API
+// keys and JWTs with a role claim
+type Authenticator struct {
+	keys    []apiKeyDigest
+	tokens  *auth.TokenManager
+	enforce bool // Reject requests without credentials instead of letting them through
+}
+
+// apiKeyDigest keeps only a hash of an API key, compared in constant time
+type apiKeyDigest struct {
+	name   string
+	role   auth.Role
+	digest [sha256.Size]byte
+}
+
+// principalKey is the request context key for the Principal
+type principalKey struct{}
+
+// minAPIKeyLength keeps API keys hard to guess
+const minAPIKeyLength = 16
+
+// NewAuthenticator creates an authenticator for the API keys and, if tokens
+// isn't nil, operator tokens. Unless enforce is set, requests without
+// credentials keep full access; credentials that are sent are still checked.
+func NewAuthenticator(keys []APIKey, tokens *auth.TokenManager, enforce bool) (*Authenticator, error) {
+	a := &Authenticator{tokens: tokens, enforce: enforce}
+	for _, key := range keys {
+		if key.Name == "" {
+			return nil, errors.New("API key name is required")
+		}
+		if !key.Role.IsValid() {
+			return nil, fmt.Errorf("API key %s: %w %q, expected viewer, operator or admin", key.Name, auth.ErrInvalidRole, key.Role)
+		}
+		if len(key.Key) < minAPIKeyLength {
+			return nil, fmt.Errorf("API key %s must be at least %d characters", key.Name, minAPIKeyLength)
+		}
+		a.keys = append(a.keys, apiKeyDigest{
+			name:   key.Name,
+			role:   key.Role,
+			digest: sha256.Sum256([]byte(key.Key)),
+		})
+	}
+	if len(a.keys) == 0 && tokens == nil {
+		return nil, errors.New("at least one API key or a token manager is required")
+	}
+	return a, nil
+}
+
+// Authenticate identifies the operator from the Authorization bearer token
+// or the X-API-Key header
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	credential := r.Header.Get("X-API-Key")
+	if credential == "" {
+		if authHeader := r.Header.Get("Authorization"); strings.HasPrefix(authHeader, "Bearer ") {
+			credential = strings.TrimPrefix(authHeader, "Bearer ")
+		}
+	}
+	if credential == "" {
+		return nil, ErrNoCredentials
+	}
+
+	// Check every key so the time taken doesn't reveal which one matched
+	digest := sha256.Sum256([]byte(credential))
+	var matched *apiKeyDigest
+	for i := range a.keys {
+		if subtle.ConstantTimeCompare(digest[:], a.keys[i].digest[:]) == 1 {
+			matched = &a.keys[i]
+		}
+	}
+	if matched != nil {
+		return &Principal{Name: matched.name, Role: matched.role, Via: "api_key"}, nil
+	}
+
+	if a.tokens == nil {
+		return nil, ErrInvalidCredentials
+	}
+	claims, err := a.tokens.ValidateToken(credential)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	// Client tokens carry no role and never grant API access
+	role := auth.Role(claims.Role)
+	if !role.IsValid() || claims.Subject == "" {
+		return nil, fmt.Errorf("%w: not an operator token", ErrInvalidCredentials)
+	}
+	return &Principal{Name: claims.Subject, Role: role, Via: "token"}, nil
+}
+
+// PrincipalFromContext returns the operator authenticated for a request, or
+// nil if the request was let through without credentials
+func PrincipalFromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
+
+// Require wraps an API endpoint so GET and HEAD requests need the read role
+// and every other method the write role. Without an authenticator every
+// request passes, as before API authentication existed. Refused requests
+// and every request of another method than GET or HEAD are audited.
+func (h *Handler) Require(read, write auth.Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		readOnly := r.Method == http.MethodGet || r.Method == http.MethodHead
+		required := write
+		if readOnly {
+			required = read
+		}
+
+		var principal *Principal
+		if h.auth != nil {
+			var err error
+			principal, err = h.auth.Authenticate(r)
+			if errors.Is(err, ErrNoCredentials) && !h.auth.enforce {
+				err = nil
+			}
+			if err != nil {
+				if !errors.Is(err, ErrNoCredentials) {
+					log.Printf("🔒 Rejected %s %s from %s: %v", r.Method, r.URL.Path, r.RemoteAddr, err)
+				}
+				h.auditLog.Record(newAuditEntry(r, nil, http.StatusUnauthorized))
+				w.Header().Set("WWW-Authenticate", `Bearer realm="file-download-system"`)
+				h.sendError(w, http.StatusUnauthorized, "Valid API key or operator token required")
+				return
+			}
+		}
+
+		if principal != nil {
+			if !principal.Role.Allows(required) {
+				h.deny(w, r, principal, required)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), principalKey{}, principal))
+		}
+
+		if readOnly {
+			next(w, r)
+			return
+		}
+
+		// The endpoint fills in what it acted on, see auditEntry
+		entry := newAuditEntry(r, principal, 0)
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(recorder, r.WithContext(context.WithValue(r.Context(), auditEntryKey{}, &entry)))
+		entry.StatusCode = recorder.status
+		entry.Result = auditResult(recorder.status)
+		h.auditLog.Record(entry)
+	}
+}
+
+// requireRole refuses a request whose operator lacks the role, for
+// endpoints where what a request costs depends on more than its method.
+// Requests let through without credentials pass, as in Require.
+func (h *Handler) requireRole(w http.ResponseWriter, r *http.Request, required auth.Role) bool {
+	principal := PrincipalFromContext(r.Context())
+	if principal == nil || principal.Role.Allows(required) {
+		return true
+	}
+	h.deny(w, r, principal, required)
+	return false
+}
+
+// deny answers 403 to an operator whose role doesn't allow the request
+func (h *Handler) deny(w http.ResponseWriter, r *http.Request, principal *Principal, required auth.Role) {
+	log.Printf("🔒 Denied %s %s to %s (%s), needs %s", r.Method, r.URL.Path, principal.Name, principal.Role, required)
+	h.auditLog.Record(newAuditEntry(r, principal, http.StatusForbidden))
+	h.sendError(w, http.StatusForbidden, fmt.Sprintf("Role %s may not do this, %s required", principal.Role, required))
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/iriyanto1027/file-download-system/server/batch"
+	"github.com/iriyanto1027/file-download-system/server/models"
+)
+
+// BulkTriggerDownloadRequest is the request body for triggering a download
+// on many clients
+type BulkTriggerDownloadRequest struct {
+	Selector models.ClientSelector `json:"selector"`
+	TriggerDownloadRequest
+}
+
+// ListBatchesResponse is the response for listing batches
+type ListBatchesResponse struct {
+	Batches []batch.Info `json:"batches"`
+	Count   int          `json:"count"`
+}
+
+// BulkTriggerDownload handles POST /trigger-download, starting the download
+// on every client matching the selector as a batch
+func (h *Handler) BulkTriggerDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req BulkTriggerDownloadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	clientIDs, err := h.ResolveClients(req.Selector)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(clientIDs) == 0 {
+		h.sendError(w, http.StatusUnprocessableEntity, "No clients match the selector")
+		return
+	}
+
+	if req.FilePath == "" {
+		req.FilePath = "/data/test-file.bin"
+	}
+	trigger := req.TriggerDownloadRequest
+
+	info := h.batches.Start(h.ctx, req.Selector, req.FilePath, clientIDs, batch.Options{}, func(clientID string) (*models.UploadStatus, error) {
+		return h.startDownload(clientID, trigger)
+	})
+	entry := auditEntry(r)
+	entry.FilePath = req.FilePath
+	entry.Details = map[string]string{"batch_id": info.BatchID, "clients": strconv.Itoa(len(clientIDs))}
+	h.sendJSON(w, http.StatusAccepted, info)
+}
+
+// HandleBatches handles GET /batches and GET /batches/{batch_id}
+func (h *Handler) HandleBatches(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	batchID := strings.Trim(strings.TrimPrefix(r.URL.Path, "/batches"), "/")
+	if batchID == "" {
+		batches := h.batches.List()
+		h.sendJSON(w, http.StatusOK, ListBatchesResponse{Batches: batches, Count: len(batches)})
+		return
+	}
+
+	info, err := h.batches.Get(batchID)
+	if errors.Is(err, batch.ErrNotFound) {
+		h.sendError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	h.sendJSON(w, http.StatusOK, info)
+}
+
+// ResolveClients returns the clients a selector matches; an explicit list
+// is returned as is, so it may include offline clients
+func (h *Handler) ResolveClients(selector models.ClientSelector) ([]string, error) {
+	if err := selector.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case selector.All:
+		clientIDs := h.wsManager.GetAllClients()
+		sort.Strings(clientIDs)
+		return clientIDs, nil
+
+	case len(selector.Labels) > 0:
+		return h.wsManager.FindClientsByLabels(selector.Labels), nil
+
+	default:
+		// Clients that are offline stay in the batch and fail individually
+		seen := make(map[string]bool, len(selector.ClientIDs))
+		clientIDs := make([]string, 0, len(selector.ClientIDs))
+		for _, clientID := range selector.ClientIDs {
+			if clientID == "" || seen[clientID] {
+				continue
+			}
+			seen[clientID] = true
+			clientIDs = append(clientIDs, clientID)
+		}
+		return clientIDs, nil
+	}
+}
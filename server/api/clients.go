@@ -0,0 +1,149 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/iriyanto1027/file-download-system/shared/auth"
+	sharedModels "github.com/iriyanto1027/file-download-system/shared/models"
+)
+
+// ClientHealthResponse is the response for a client health check
+type ClientHealthResponse struct {
+	ClientID  string    `json:"client_id"`
+	Status    string    `json:"status"`
+	Timestamp time.Time `json:"timestamp"`
+	LatencyMs int64     `json:"latency_ms"`
+}
+
+// ClientStatResponse is the response for a file stat on a client
+type ClientStatResponse struct {
+	ClientID string `json:"client_id"`
+	sharedModels.StatFileResponse
+}
+
+// HandleClient dispatches requests to /clients/{client_id}/{operation}
+func (h *Handler) HandleClient(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/clients/"), "/"), "/")
+	if len(parts) != 2 || parts[0] == "" {
+		h.sendError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	clientID, operation := parts[0], parts[1]
+
+	switch operation {
+	case "health":
+		h.ClientHealth(w, r, clientID)
+	case "stat":
+		h.ClientStat(w, r, clientID)
+	default:
+		h.sendError(w, http.StatusNotFound, fmt.Sprintf("Unknown operation %s", operation))
+	}
+}
+
+// ClientHealth handles GET /clients/{client_id}/health by asking the client
+// itself and waiting for its answer
+func (h *Handler) ClientHealth(w http.ResponseWriter, r *http.Request, clientID string) {
+	command, err := sharedModels.NewCommandMessage(sharedModels.CommandActionHealthCheck, "", nil)
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, "Failed to build command")
+		return
+	}
+
+	start := time.Now()
+	resp, ok := h.call(w, r, clientID, command)
+	if !ok {
+		return
+	}
+
+	var health sharedModels.HealthCheckResponse
+	if err := resp.DecodePayload(&health); err != nil {
+		h.sendError(w, http.StatusBadGateway, fmt.Sprintf("Invalid health response: %v", err))
+		return
+	}
+
+	h.sendJSON(w, http.StatusOK, ClientHealthResponse{
+		ClientID:  clientID,
+		Status:    health.Status,
+		Timestamp: health.Timestamp,
+		LatencyMs: time.Since(start).Milliseconds(),
+	})
+}
+
+// ClientStat handles GET /clients/{client_id}/stat?path=...&hash=true
+func (h *Handler) ClientStat(w http.ResponseWriter, r *http.Request, clientID string) {
+	filePath := r.URL.Query().Get("path")
+	if filePath == "" {
+		h.sendError(w, http.StatusBadRequest, "Query parameter path is required")
+		return
+	}
+
+	// Hashing makes the device read the whole file, so it takes the role
+	// that triggers downloads
+	computeHash := r.URL.Query().Get("hash") == "true"
+	if computeHash && !h.requireRole(w, r, auth.RoleOperator) {
+		return
+	}
+
+	command, err := sharedModels.NewCommandMessage(sharedModels.CommandActionStatFile, "", sharedModels.StatFilePayload{
+		FilePath:    filePath,
+		ComputeHash: computeHash,
+	})
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, "Failed to build command")
+		return
+	}
+
+	resp, ok := h.call(w, r, clientID, command)
+	if !ok {
+		return
+	}
+
+	var stat sharedModels.StatFileResponse
+	if err := resp.DecodePayload(&stat); err != nil {
+		h.sendError(w, http.StatusBadGateway, fmt.Sprintf("Invalid stat response: %v", err))
+		return
+	}
+
+	h.sendJSON(w, http.StatusOK, ClientStatResponse{
+		ClientID:         clientID,
+		StatFileResponse: stat,
+	})
+}
+
+// call sends a command and waits for the client's answer; on failure it
+// writes the HTTP error and returns false
+func (h *Handler) call(w http.ResponseWriter, r *http.Request, clientID string, command *sharedModels.CommandMessage) (*sharedModels.ResponseMessage, bool) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.callTimeout)
+	defer cancel()
+
+	resp, err := h.wsManager.Call(ctx, clientID, command)
+	if errors.Is(err, context.DeadlineExceeded) {
+		h.sendError(w, http.StatusGatewayTimeout, fmt.Sprintf("Client %s did not answer within %v", clientID, h.callTimeout))
+		return nil, false
+	}
+	if err != nil {
+		h.sendCommandError(w, err)
+		return nil, false
+	}
+
+	switch {
+	case resp.ErrorCode == sharedModels.ErrorCodeForbiddenPath || resp.ErrorCode == sharedModels.ErrorCodeFileTooLarge:
+		// The client's sandbox refused; retrying won't help
+		h.sendError(w, http.StatusForbidden, fmt.Sprintf("Client %s refused %s: %s", clientID, command.Action, resp.Error))
+		return nil, false
+	case resp.Status != sharedModels.ResponseStatusSuccess:
+		h.sendError(w, http.StatusBadGateway, fmt.Sprintf("Client %s failed %s: %s", clientID, command.Action, resp.Error))
+		return nil, false
+	}
+	return resp, true
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iriyanto1027/file-download-system/server/events"
+	"github.com/iriyanto1027/file-download-system/server/models"
+	sharedModels "github.com/iriyanto1027/file-download-system/shared/models"
+)
+
+// sseKeepAliveInterval is how often an idle stream gets a comment line so
+// proxies don't close it
+const sseKeepAliveInterval = 15 * time.Second
+
+// StreamEvents handles GET /events?client_id= and streams the events of
+// every upload, optionally limited to one client
+func (h *Handler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	filter := events.Filter{ClientID: r.URL.Query().Get("client_id")}
+	h.streamEvents(w, r, filter, nil)
+}
+
+// StreamUploadEvents handles GET /uploads/{upload_id}/events. The stream
+// opens with a snapshot of the upload and ends once it reaches a final state.
+func (h *Handler) StreamUploadEvents(w http.ResponseWriter, r *http.Request) {
+	uploadID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/uploads/"), "/events")
+	if uploadID == "" || strings.Contains(uploadID, "/") {
+		h.sendError(w, http.StatusBadRequest, "Upload ID is required")
+		return
+	}
+
+	upload, exists := h.wsManager.GetUpload(uploadID)
+	if !exists {
+		h.sendError(w, http.StatusNotFound, fmt.Sprintf("Upload %s not found", uploadID))
+		return
+	}
+
+	h.streamEvents(w, r, events.Filter{UploadID: uploadID}, upload)
+}
+
+// streamEvents writes matching events as Server-Sent Events until the
+// request ends. With an upload, a snapshot is sent first and the stream
+// closes after the upload's final state.
+func (h *Handler) streamEvents(w http.ResponseWriter, r *http.Request, filter events.Filter, upload *models.UploadStatus) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		h.sendError(w, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+
+	// EventSource sends the last ID it saw when it reconnects
+	var lastEventID uint64
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		lastEventID, _ = strconv.ParseUint(id, 10, 64)
+	}
+
+	// Subscribe before taking the snapshot so no change falls in between
+	sub := h.wsManager.Events().Subscribe(filter, lastEventID)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if upload != nil {
+		info := upload.Info()
+		if err := writeSSE(w, 0, "snapshot", info); err != nil {
+			return
+		}
+		flusher.Flush()
+		if info.Status.IsFinal() {
+			return
+		}
+	}
+
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case event, ok := <-sub.Events():
+			if !ok {
+				// Dropped for falling behind; the client resumes from its last ID
+				return
+			}
+			if err := writeSSE(w, event.ID, string(event.Type), event); err != nil {
+				return
+			}
+			flusher.Flush()
+			if upload != nil && event.IsFinal() {
+				return
+			}
+
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeSSE writes one Server-Sent Event; an id of 0 is left out
+func writeSSE(w http.ResponseWriter, id uint64, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if id > 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+	return err
+}
+
+// publishProgress publishes the progress a client reported, with a part
+// event for every part completed since its previous report
+func (h *Handler) publishProgress(upload *models.UploadStatus, previousParts int, status *sharedModels.UploadStatus) {
+	broker := h.wsManager.Events()
+
+	// The client reports parts in order, so the count is the last part number
+	for part := previousParts + 1; part <= status.CompletedParts; part++ {
+		broker.Publish(events.Event{
+			Type:     events.EventTypePart,
+			UploadID: upload.UploadID,
+			ClientID: upload.ClientID,
+			Part: &events.PartCompleted{
+				PartNumber:     part,
+				CompletedParts: status.CompletedParts,
+				TotalParts:     status.TotalParts,
+			},
+		})
+	}
+
+	progress := &events.ProgressUpdate{
+		BytesUploaded:  status.BytesUploaded,
+		FileSize:       status.FileSize,
+		CompletedParts: status.CompletedParts,
+		TotalParts:     status.TotalParts,
+		Progress:       status.Progress,
+		Retries:        status.Retries,
+		LastError:      status.LastError,
+	}
+	if elapsed := status.LastUpdate.Sub(status.StartTime).Seconds(); elapsed > 0 {
+		progress.ThroughputBps = float64(status.BytesUploaded) / elapsed
+		if progress.ThroughputBps > 0 && status.FileSize > status.BytesUploaded {
+			progress.ETASeconds = float64(status.FileSize-status.BytesUploaded) / progress.ThroughputBps
+		}
+	}
+
+	broker.Publish(events.Event{
+		Type:     events.EventTypeProgress,
+		UploadID: upload.UploadID,
+		ClientID: upload.ClientID,
+		Progress: progress,
+	})
+}
@@ -5,62 +5,149 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"path"
 	"sort"
+	"strings"
 	"time"
 
+	"github.com/iriyanto1027/file-download-system/server/audit"
+	"github.com/iriyanto1027/file-download-system/server/batch"
 	"github.com/iriyanto1027/file-download-system/server/models"
+	"github.com/iriyanto1027/file-download-system/server/revocation"
 	"github.com/iriyanto1027/file-download-system/server/s3"
+	"github.com/iriyanto1027/file-download-system/server/scheduler"
+	"github.com/iriyanto1027/file-download-system/server/webhooks"
 	"github.com/iriyanto1027/file-download-system/server/websocket"
+	"github.com/iriyanto1027/file-download-system/shared/auth"
 	sharedModels "github.com/iriyanto1027/file-download-system/shared/models"
 )
 
 // Handler handles API requests
 type Handler struct {
-	wsManager  *websocket.Manager
-	s3Client   *s3.Client
-	chunkSize  int64
-	baseS3Path string
+	ctx          context.Context // Server lifecycle; batches stop when it's done
+	wsManager    *websocket.Manager
+	s3Client     *s3.Client
+	chunkSize    int64
+	baseS3Path   string
+	urlBatchSize int
+	callTimeout  time.Duration
+	webhooks     *webhooks.Dispatcher
+	batches      *batch.Manager
+	scheduler    *scheduler.Scheduler
+	queueTTL     time.Duration
+	maxQueueTTL  time.Duration
+	tokens       *auth.TokenManager
+	tokenTTL     time.Duration
+	revocations  *revocation.List
+	auth         *Authenticator
+	auditLog     *audit.Log
+	checksum     sharedModels.ChecksumAlgorithm // Part checksum algorithm for new uploads; empty for none
 }
 
 // Config contains the API handler configuration
 type Config struct {
-	ChunkSize  int64
-	BaseS3Path string
+	ChunkSize    int64
+	BaseS3Path   string
+	URLBatchSize int                  // Number of presigned URLs sent with the download command
+	CallTimeout  time.Duration        // How long blocking endpoints wait for the client
+	Webhooks     *webhooks.Dispatcher // Serves the /webhooks endpoints; disabled if nil
+	BatchLimit   int                  // Batch uploads running at once across all batches
+	QueueTTL     time.Duration        // How long a download queued for an offline client waits by default
+	MaxQueueTTL  time.Duration        // Longest wait a request may ask for
+	Tokens       *auth.TokenManager   // Issues and refreshes client tokens; disabled if nil
+	TokenTTL     time.Duration        // Lifetime of issued and refreshed client tokens
+	Revocations  *revocation.List     // Serves /admin/revocations; disabled if nil
+	Auth         *Authenticator       // Checks operator credentials in Require; every request passes if nil
+	Audit        *audit.Log           // Records operator actions and serves /audit; disabled if nil
+
+	// ChecksumAlgorithm has clients checksum every part so S3 verifies the
+	// data end to end; empty disables checksums
+	ChecksumAlgorithm sharedModels.ChecksumAlgorithm
 }
 
-// NewHandler creates a new API handler
-func NewHandler(wsManager *websocket.Manager, s3Client *s3.Client, cfg Config) *Handler {
+// maxPartURLsPerRequest caps how many URLs a client may request at once
+const maxPartURLsPerRequest = 100
+
+// NewHandler creates a new API handler; ctx bounds the batches it starts
+func NewHandler(ctx context.Context, wsManager *websocket.Manager, s3Client *s3.Client, cfg Config) *Handler {
 	if cfg.ChunkSize == 0 {
 		cfg.ChunkSize = 5 * 1024 * 1024 // 5MB default
 	}
 	if cfg.BaseS3Path == "" {
 		cfg.BaseS3Path = "uploads"
 	}
+	if cfg.URLBatchSize <= 0 {
+		cfg.URLBatchSize = 10
+	}
+	if cfg.CallTimeout <= 0 {
+		cfg.CallTimeout = 30 * time.Second
+	}
+	if cfg.QueueTTL <= 0 {
+		cfg.QueueTTL = 24 * time.Hour
+	}
+	if cfg.MaxQueueTTL <= 0 {
+		cfg.MaxQueueTTL = 7 * 24 * time.Hour
+	}
+	cfg.MaxQueueTTL = max(cfg.MaxQueueTTL, cfg.QueueTTL)
+	if cfg.TokenTTL <= 0 {
+		cfg.TokenTTL = 24 * time.Hour
+	}
 
 	return &Handler{
-		wsManager:  wsManager,
-		s3Client:   s3Client,
-		chunkSize:  cfg.ChunkSize,
-		baseS3Path: cfg.BaseS3Path,
+		ctx:          ctx,
+		wsManager:    wsManager,
+		s3Client:     s3Client,
+		chunkSize:    cfg.ChunkSize,
+		baseS3Path:   cfg.BaseS3Path,
+		urlBatchSize: cfg.URLBatchSize,
+		callTimeout:  cfg.CallTimeout,
+		webhooks:     cfg.Webhooks,
+		queueTTL:     cfg.QueueTTL,
+		maxQueueTTL:  cfg.MaxQueueTTL,
+		tokens:       cfg.Tokens,
+		tokenTTL:     cfg.TokenTTL,
+		revocations:  cfg.Revocations,
+		auth:         cfg.Auth,
+		auditLog:     cfg.Audit,
+		checksum:     cfg.ChecksumAlgorithm,
+		batches: batch.NewManager(batch.Config{
+			MaxConcurrent: cfg.BatchLimit,
+		}, wsManager.Events(), wsManager.GetUpload),
 	}
 }
 
 // TriggerDownloadRequest is the request body for triggering a download
 type TriggerDownloadRequest struct {
-	FilePath string            `json:"file_path,omitempty"`
-	Metadata map[string]string `json:"metadata,omitempty"`
+	FilePath    string            `json:"file_path,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	ComputeHash bool              `json:"compute_hash,omitempty"`
+
+	// QueueIfOffline queues the request until the client connects instead
+	// of failing, for QueueTTL (a duration such as "6h"; server default if
+	// empty). Only used when triggering a single client.
+	QueueIfOffline bool   `json:"queue_if_offline,omitempty"`
+	QueueTTL       string `json:"queue_ttl,omitempty"`
 }
 
 // TriggerDownloadResponse is the response for triggering a download
 type TriggerDownloadResponse struct {
-	Success  bool   `json:"success"`
-	Message  string `json:"message"`
-	UploadID string `json:"upload_id,omitempty"`
-	S3Key    string `json:"s3_key,omitempty"`
+	Success  bool               `json:"success"`
+	Message  string             `json:"message"`
+	UploadID string             `json:"upload_id,omitempty"`
+	S3Key    string             `json:"s3_key,omitempty"`
+	Status   models.UploadState `json:"status,omitempty"`
+}
+
+// CancelUploadResponse is the response for cancelling an upload
+type CancelUploadResponse struct {
+	Success  bool               `json:"success"`
+	Message  string             `json:"message"`
+	UploadID string             `json:"upload_id"`
+	Status   models.UploadState `json:"status"`
 }
 
 // ErrorResponse is the standard error response
@@ -83,12 +170,6 @@ func (h *Handler) TriggerDownload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if client is connected
-	if !h.wsManager.IsClientConnected(clientID) {
-		h.sendError(w, http.StatusNotFound, fmt.Sprintf("Client %s is not connected", clientID))
-		return
-	}
-
 	// Parse request body (optional)
 	var req TriggerDownloadRequest
 	if r.Body != nil {
@@ -98,6 +179,63 @@ func (h *Handler) TriggerDownload(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	entry := auditEntry(r)
+	entry.ClientID = clientID
+	entry.FilePath = req.FilePath
+
+	// Check if client is connected
+	if !h.wsManager.IsClientConnected(clientID) {
+		if req.QueueIfOffline {
+			h.queueDownload(w, r, clientID, req)
+			return
+		}
+		h.sendError(w, http.StatusNotFound, fmt.Sprintf("Client %s is not connected", clientID))
+		return
+	}
+
+	upload, err := h.startDownload(clientID, req)
+	if err != nil {
+		h.sendCommandError(w, err)
+		return
+	}
+	entry.UploadID = upload.UploadID
+	entry.FilePath = upload.FilePath
+	entry.S3Key = upload.S3Key
+
+	// Send success response
+	h.sendJSON(w, http.StatusOK, TriggerDownloadResponse{
+		Success:  true,
+		Message:  fmt.Sprintf("Download triggered for client %s", clientID),
+		UploadID: upload.UploadID,
+		S3Key:    upload.S3Key,
+		Status:   upload.GetStatus(),
+	})
+}
+
+// startDownload registers an upload and sends the stat_file command that
+// starts it on the client
+func (h *Handler) startDownload(clientID string, req TriggerDownloadRequest) (*models.UploadStatus, error) {
+	// Refuse clients whose build predates the stat_file handshake
+	if err := h.wsManager.CheckSupport(clientID, sharedModels.CommandActionStatFile, sharedModels.CommandActionDownloadFile); err != nil {
+		return nil, err
+	}
+
+	uploadStatus, err := h.newUpload(clientID, req)
+	if err != nil {
+		return nil, err
+	}
+	h.wsManager.RegisterUpload(uploadStatus)
+	defer h.wsManager.SaveUpload(uploadStatus)
+
+	if err := h.sendStatFile(uploadStatus); err != nil {
+		return nil, err
+	}
+	return uploadStatus, nil
+}
+
+// newUpload builds the status of a new upload; the file size is only known
+// once the client has answered the stat_file command
+func (h *Handler) newUpload(clientID string, req TriggerDownloadRequest) (*models.UploadStatus, error) {
 	// Set default file path if not provided
 	if req.FilePath == "" {
 		req.FilePath = "/data/test-file.bin"
@@ -106,86 +244,51 @@ func (h *Handler) TriggerDownload(w http.ResponseWriter, r *http.Request) {
 	// Generate upload ID
 	uploadID, err := generateUploadID()
 	if err != nil {
-		h.sendError(w, http.StatusInternalServerError, "Failed to generate upload ID")
-		return
+		return nil, fmt.Errorf("failed to generate upload ID: %w", err)
 	}
 
 	// Generate S3 key
 	timestamp := time.Now().Format("20060102-150405")
 	s3Key := fmt.Sprintf("%s/%s/%s-%s", h.baseS3Path, clientID, timestamp, path.Base(req.FilePath))
 
-	// Assume file size for multipart upload (will be adjusted by client)
-	// We'll create presigned URLs for a 100MB file by default
-	estimatedFileSize := int64(100 * 1024 * 1024) // 100MB
-
-	// Initiate multipart upload and generate presigned URLs
-	multipartUpload, err := h.s3Client.InitiateMultipartUpload(r.Context(), s3.MultipartUploadConfig{
-		Key:       s3Key,
-		FileSize:  estimatedFileSize,
-		ChunkSize: h.chunkSize,
-		Metadata:  req.Metadata,
-	})
-
-	if err != nil {
-		log.Printf("Failed to initiate multipart upload: %v", err)
-		h.sendError(w, http.StatusInternalServerError, "Failed to initiate upload")
-		return
-	}
-
-	// Create upload status
 	uploadStatus := models.NewUploadStatus(
 		uploadID,
 		clientID,
 		req.FilePath,
-		multipartUpload.Bucket,
-		multipartUpload.Key,
-		estimatedFileSize,
+		h.s3Client.Bucket(),
+		s3Key,
+		0,
 		h.chunkSize,
-		multipartUpload.TotalParts,
+		0,
 	)
-	// Set the S3 multipart upload ID
-	uploadStatus.SetS3UploadID(multipartUpload.UploadID)
-	h.wsManager.RegisterUpload(uploadStatus)
-
-	// Prepare download command
-	presignedURLs := make([]sharedModels.PresignedURL, len(multipartUpload.PresignedURLs))
-	for i, url := range multipartUpload.PresignedURLs {
-		presignedURLs[i] = sharedModels.PresignedURL{
-			PartNumber: url.PartNumber,
-			URL:        url.URL,
-		}
-	}
+	uploadStatus.Metadata = req.Metadata
+	uploadStatus.ComputeHash = req.ComputeHash
+	uploadStatus.ChecksumAlgorithm = h.checksum
+	return uploadStatus, nil
+}
 
-	command := &sharedModels.CommandMessage{
-		Action: sharedModels.CommandActionDownloadFile,
-		Payload: sharedModels.DownloadFilePayload{
-			FilePath: req.FilePath,
-			UploadConfig: sharedModels.UploadConfig{
-				UploadID:      uploadID,
-				Bucket:        multipartUpload.Bucket,
-				Key:           multipartUpload.Key,
-				ChunkSize:     h.chunkSize,
-				PresignedURLs: presignedURLs,
-			},
-			Metadata: req.Metadata,
-		},
+// sendStatFile asks the client for the real file size; the multipart upload
+// is only initiated once the client has answered. The upload is failed if
+// the command can't be sent.
+func (h *Handler) sendStatFile(uploadStatus *models.UploadStatus) error {
+	command, err := sharedModels.NewCommandMessage(sharedModels.CommandActionStatFile, uploadStatus.UploadID, sharedModels.StatFilePayload{
+		FilePath:          uploadStatus.FilePath,
+		ComputeHash:       uploadStatus.ComputeHash,
+		ChunkSize:         uploadStatus.ChunkSize,
+		ChecksumAlgorithm: uploadStatus.ChecksumAlgorithm,
+	})
+	if err != nil {
+		uploadStatus.MarkFailed(err.Error())
+		return fmt.Errorf("failed to build command: %w", err)
 	}
-	command.MessageID = uploadID
 
 	// Send command to client
-	if err := h.wsManager.SendCommand(clientID, command); err != nil {
-		log.Printf("Failed to send command to client %s: %v", clientID, err)
-		h.sendError(w, http.StatusInternalServerError, "Failed to send command to client")
-		return
+	if err := h.wsManager.SendCommand(uploadStatus.ClientID, command); err != nil {
+		log.Printf("Failed to send command to client %s: %v", uploadStatus.ClientID, err)
+		uploadStatus.MarkFailed(err.Error())
+		return err
 	}
-
-	// Send success response
-	h.sendJSON(w, http.StatusOK, TriggerDownloadResponse{
-		Success:  true,
-		Message:  fmt.Sprintf("Download triggered for client %s", clientID),
-		UploadID: uploadID,
-		S3Key:    s3Key,
-	})
+	return nil
 }
 
 // GetStatus handles GET /status/{client_id}
@@ -208,13 +311,105 @@ func (h *Handler) GetStatus(w http.ResponseWriter, r *http.Request) {
 	h.sendJSON(w, http.StatusOK, status)
 }
 
-// GetUploadStatus handles GET /uploads/{upload_id}
-func (h *Handler) GetUploadStatus(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+// HandleUpload dispatches requests to /uploads/{upload_id} by method
+func (h *Handler) HandleUpload(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if strings.HasSuffix(r.URL.Path, "/events") {
+			h.StreamUploadEvents(w, r)
+			return
+		}
+		h.GetUploadStatus(w, r)
+	case http.MethodDelete:
+		h.CancelUpload(w, r)
+	default:
 		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// CancelUpload handles DELETE /uploads/{upload_id}
+func (h *Handler) CancelUpload(w http.ResponseWriter, r *http.Request) {
+	uploadID := path.Base(r.URL.Path)
+	if uploadID == "" || uploadID == "uploads" {
+		h.sendError(w, http.StatusBadRequest, "Upload ID is required")
 		return
 	}
 
+	entry := auditEntry(r)
+	entry.UploadID = uploadID
+
+	upload, exists := h.wsManager.GetUpload(uploadID)
+	if !exists {
+		h.sendError(w, http.StatusNotFound, fmt.Sprintf("Upload %s not found", uploadID))
+		return
+	}
+	defer h.wsManager.SaveUpload(upload)
+	entry.ClientID = upload.ClientID
+	entry.FilePath = upload.FilePath
+
+	// Nothing runs on the client before the multipart upload is initiated
+	if upload.GetS3UploadID() == "" {
+		if !upload.MarkCancelled() {
+			h.sendError(w, http.StatusConflict, fmt.Sprintf("Upload %s already %s", uploadID, upload.GetStatus()))
+			return
+		}
+		h.sendJSON(w, http.StatusOK, CancelUploadResponse{
+			Success:  true,
+			Message:  fmt.Sprintf("Upload %s cancelled", uploadID),
+			UploadID: uploadID,
+			Status:   models.UploadStateCancelled,
+		})
+		return
+	}
+
+	if !h.wsManager.IsClientConnected(upload.ClientID) {
+		h.sendError(w, http.StatusConflict, fmt.Sprintf("Client %s is not connected to confirm the cancellation", upload.ClientID))
+		return
+	}
+
+	if err := h.wsManager.CheckSupport(upload.ClientID, sharedModels.CommandActionCancelUpload); err != nil {
+		h.sendCommandError(w, err)
+		return
+	}
+
+	if !upload.RequestCancel() {
+		h.sendError(w, http.StatusConflict, fmt.Sprintf("Upload %s already %s", uploadID, upload.GetStatus()))
+		return
+	}
+
+	commandID, err := generateUploadID()
+	if err != nil {
+		upload.CancelRejected()
+		h.sendError(w, http.StatusInternalServerError, "Failed to generate command ID")
+		return
+	}
+
+	command, err := sharedModels.NewCommandMessage(sharedModels.CommandActionCancelUpload, commandID, sharedModels.CancelUploadPayload{UploadID: uploadID})
+	if err != nil {
+		upload.CancelRejected()
+		h.sendError(w, http.StatusInternalServerError, "Failed to build command")
+		return
+	}
+
+	if err := h.wsManager.SendCommand(upload.ClientID, command); err != nil {
+		log.Printf("Failed to send cancel command to client %s: %v", upload.ClientID, err)
+		upload.CancelRejected()
+		h.sendCommandError(w, err)
+		return
+	}
+
+	// The upload is marked cancelled once the client confirms
+	h.sendJSON(w, http.StatusAccepted, CancelUploadResponse{
+		Success:  true,
+		Message:  fmt.Sprintf("Cancellation requested for upload %s", uploadID),
+		UploadID: uploadID,
+		Status:   models.UploadStateCancelling,
+	})
+}
+
+// GetUploadStatus handles GET /uploads/{upload_id}
+func (h *Handler) GetUploadStatus(w http.ResponseWriter, r *http.Request) {
+
 	// Extract upload ID from URL path
 	uploadID := path.Base(r.URL.Path)
 	if uploadID == "" || uploadID == "uploads" {
@@ -230,20 +425,7 @@ func (h *Handler) GetUploadStatus(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Convert to response format
-	response := models.UploadInfo{
-		UploadID:       upload.UploadID,
-		FilePath:       upload.FilePath,
-		S3Key:          upload.S3Key,
-		FileSize:       upload.FileSize,
-		Status:         upload.Status,
-		Progress:       upload.GetProgress(),
-		CompletedParts: upload.CompletedParts,
-		TotalParts:     upload.TotalParts,
-		BytesUploaded:  upload.BytesUploaded,
-		StartTime:      upload.StartTime,
-		EndTime:        upload.EndTime,
-		Error:          upload.Error,
-	}
+	response := upload.Info()
 
 	h.sendJSON(w, http.StatusOK, response)
 }
@@ -292,86 +474,361 @@ func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) HandleResponse(clientID string, msg *sharedModels.ResponseMessage) error {
 	log.Printf("Received response from client %s: status=%s, action=%s", clientID, msg.Status, msg.Action)
 
-	// Handle download file response
-	if msg.Action == sharedModels.CommandActionDownloadFile {
-		if payload, ok := msg.Payload.(map[string]interface{}); ok {
-			uploadID, _ := payload["upload_id"].(string)
-
-			if upload, exists := h.wsManager.GetUpload(uploadID); exists {
-				switch msg.Status {
-				case sharedModels.ResponseStatusSuccess:
-					upload.MarkCompleted()
-					log.Printf("Upload %s completed successfully", uploadID)
-
-					// Extract ETags from payload
-					var etags map[int]string
-					if etagsRaw, ok := payload["etags"].(map[string]interface{}); ok {
-						etags = make(map[int]string)
-						for k, v := range etagsRaw {
-							// Convert string key to int
-							var partNum int
-							if _, err := fmt.Sscanf(k, "%d", &partNum); err == nil {
-								if etag, ok := v.(string); ok {
-									etags[partNum] = etag
-								}
-							}
-						}
-					}
-
-					// Complete the multipart upload on S3
-					if len(etags) > 0 {
-						parts := make([]s3.CompletedPart, 0, len(etags))
-						for partNum, etag := range etags {
-							parts = append(parts, s3.CompletedPart{
-								PartNumber: partNum,
-								ETag:       etag,
-							})
-						}
-
-						// CRITICAL: Sort parts by PartNumber to ensure correct order for S3
-						sort.Slice(parts, func(i, j int) bool {
-							return parts[i].PartNumber < parts[j].PartNumber
-						})
-
-						log.Printf("Completing multipart upload %s with %d parts", uploadID, len(parts))
-
-						ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-						defer cancel()
-
-						// Use S3UploadID from upload status, not internal uploadID
-						s3UploadID := upload.GetS3UploadID()
-						if err := h.s3Client.CompleteMultipartUpload(ctx, upload.S3Key, s3UploadID, parts); err != nil {
-							log.Printf("❌ Failed to complete multipart upload: %v", err)
-							upload.MarkFailed(err.Error())
-						} else {
-							log.Printf("✅ Multipart upload %s completed on S3", s3UploadID)
-						}
-					} else {
-						log.Printf("⚠️ No ETags found in payload, cannot complete multipart upload")
-					}
-
-				case sharedModels.ResponseStatusError:
-					upload.MarkFailed(msg.Error)
-					log.Printf("Upload %s failed: %s", uploadID, msg.Error)
-
-					// Abort the multipart upload
-					ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-					defer cancel()
-					h.s3Client.AbortMultipartUpload(ctx, upload.S3Key, upload.GetS3UploadID())
-
-				case sharedModels.ResponseStatusCancelled:
-					upload.MarkCancelled()
-					log.Printf("Upload %s cancelled", uploadID)
-
-					// Abort the multipart upload
-					ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-					defer cancel()
-					h.s3Client.AbortMultipartUpload(ctx, upload.S3Key, upload.GetS3UploadID())
-				}
+	switch msg.Action {
+	case sharedModels.CommandActionStatFile:
+		return h.handleStatFileResponse(clientID, msg)
+	case sharedModels.CommandActionDownloadFile:
+		return h.handleDownloadFileResponse(clientID, msg)
+	case sharedModels.CommandActionCancelUpload:
+		return h.handleCancelUploadResponse(clientID, msg)
+	}
+
+	return nil
+}
+
+// handleCancelUploadResponse finishes a cancellation once the client has
+// confirmed the upload stopped
+func (h *Handler) handleCancelUploadResponse(clientID string, msg *sharedModels.ResponseMessage) error {
+	var payload sharedModels.CancelUploadPayload
+	if err := msg.DecodePayload(&payload); err != nil {
+		return err
+	}
+
+	upload, exists := h.wsManager.GetUpload(payload.UploadID)
+	if !exists || upload.ClientID != clientID {
+		return fmt.Errorf("unknown upload %s for cancel response", payload.UploadID)
+	}
+	defer h.wsManager.SaveUpload(upload)
+
+	switch msg.Status {
+	case sharedModels.ResponseStatusCancelled, sharedModels.ResponseStatusSuccess:
+		h.finishCancel(upload)
+	case sharedModels.ResponseStatusError:
+		log.Printf("Client %s could not cancel upload %s: %s", clientID, upload.UploadID, msg.Error)
+		upload.CancelRejected()
+	}
+
+	return nil
+}
+
+// finishCancel marks the upload cancelled and aborts its S3 multipart upload
+func (h *Handler) finishCancel(upload *models.UploadStatus) {
+	if !upload.MarkCancelled() {
+		return
+	}
+	log.Printf("Upload %s cancelled", upload.UploadID)
+
+	s3UploadID := upload.GetS3UploadID()
+	if s3UploadID == "" {
+		return
+	}
+
+	// Abort the multipart upload
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := h.s3Client.AbortMultipartUpload(ctx, upload.S3Key, s3UploadID); err != nil {
+		log.Printf("Failed to abort multipart upload %s: %v", s3UploadID, err)
+	}
+}
+
+// handleStatFileResponse initiates the multipart upload once the client has
+// reported the real file size, then sends the download command
+func (h *Handler) handleStatFileResponse(clientID string, msg *sharedModels.ResponseMessage) error {
+	upload, exists := h.wsManager.GetUpload(msg.CommandID)
+	if !exists || upload.ClientID != clientID {
+		return fmt.Errorf("unknown upload %s for stat response", msg.CommandID)
+	}
+	defer h.wsManager.SaveUpload(upload)
+
+	if upload.GetStatus() != models.UploadStatePending {
+		log.Printf("Ignoring stat response for upload %s in state %s", upload.UploadID, upload.GetStatus())
+		return nil
+	}
+
+	if msg.Status != sharedModels.ResponseStatusSuccess {
+		upload.MarkFailedWithCode(msg.Error, msg.ErrorCode)
+		log.Printf("Stat for upload %s failed: %s", upload.UploadID, msg.Error)
+		return nil
+	}
+
+	var stat sharedModels.StatFileResponse
+	if err := msg.DecodePayload(&stat); err != nil {
+		upload.MarkFailed(fmt.Sprintf("invalid stat response: %v", err))
+		return err
+	}
+
+	if stat.FileSize <= 0 {
+		upload.MarkFailed(fmt.Sprintf("file %s is empty", stat.FilePath))
+		log.Printf("Upload %s failed: file %s is empty", upload.UploadID, stat.FilePath)
+		return nil
+	}
+
+	log.Printf("📏 Client %s reported %s: %d bytes", clientID, stat.FilePath, stat.FileSize)
+
+	// Clients that predate checksums report none; upload without them
+	algorithm, _ := upload.GetChecksums()
+	expectedParts := int((stat.FileSize + upload.ChunkSize - 1) / upload.ChunkSize)
+	if algorithm != "" && len(stat.PartChecksums) != expectedParts {
+		log.Printf("⚠️ Client %s reported %d part checksums for %d parts, uploading %s without checksums", clientID, len(stat.PartChecksums), expectedParts, upload.UploadID)
+		algorithm = ""
+	}
+	if algorithm == "" {
+		stat.PartChecksums = nil
+	}
+	upload.SetChecksums(algorithm, stat.PartChecksums)
+	checksums := uploadChecksums(upload)
+
+	// The whole-file hash travels with the object so consumers can verify it
+	metadata := make(map[string]string, len(upload.Metadata)+1)
+	for k, v := range upload.Metadata {
+		metadata[k] = v
+	}
+	if stat.SHA256 != "" {
+		metadata["sha256"] = stat.SHA256
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Initiate multipart upload for the real size
+	multipartUpload, err := h.s3Client.InitiateMultipartUpload(ctx, s3.MultipartUploadConfig{
+		Key:               upload.S3Key,
+		FileSize:          stat.FileSize,
+		ChunkSize:         upload.ChunkSize,
+		Metadata:          metadata,
+		ChecksumAlgorithm: checksums.Algorithm,
+	})
+	if err != nil {
+		log.Printf("Failed to initiate multipart upload: %v", err)
+		upload.MarkFailed(fmt.Sprintf("failed to initiate upload: %v", err))
+		return err
+	}
+
+	upload.SetFileInfo(stat.FileSize, stat.ModTime, stat.SHA256, multipartUpload.TotalParts)
+	// Set the S3 multipart upload ID
+	upload.SetS3UploadID(multipartUpload.UploadID)
+
+	// A cancel that arrived while S3 initiated the upload found no S3
+	// upload ID to abort, so abort it here instead
+	if h.abortIfFinal(ctx, upload, multipartUpload.UploadID) {
+		return nil
+	}
+
+	// Only presign the first window of parts; the client requests the rest as it goes
+	initialParts := make([]int, 0, h.urlBatchSize)
+	for partNumber := 1; partNumber <= multipartUpload.TotalParts && len(initialParts) < h.urlBatchSize; partNumber++ {
+		initialParts = append(initialParts, partNumber)
+	}
+
+	presignedURLs, err := h.s3Client.PresignUploadParts(ctx, multipartUpload.Key, multipartUpload.UploadID, initialParts, checksums)
+	if err != nil {
+		log.Printf("Failed to presign upload parts: %v", err)
+		upload.MarkFailed(err.Error())
+		h.s3Client.AbortMultipartUpload(ctx, upload.S3Key, multipartUpload.UploadID)
+		return err
+	}
+
+	// Prepare download command
+	command, err := sharedModels.NewCommandMessage(sharedModels.CommandActionDownloadFile, upload.UploadID, sharedModels.DownloadFilePayload{
+		FilePath: upload.FilePath,
+		UploadConfig: sharedModels.UploadConfig{
+			UploadID:      upload.UploadID,
+			S3UploadID:    multipartUpload.UploadID,
+			Bucket:        multipartUpload.Bucket,
+			Key:           multipartUpload.Key,
+			ChunkSize:     multipartUpload.ChunkSize,
+			FileSize:      stat.FileSize,
+			TotalParts:    multipartUpload.TotalParts,
+			PresignedURLs: toSharedPresignedURLs(presignedURLs),
+
+			ChecksumAlgorithm: algorithm,
+		},
+		Metadata: upload.Metadata,
+	})
+	if err == nil && h.abortIfFinal(ctx, upload, multipartUpload.UploadID) {
+		return nil
+	}
+	// Send command to client
+	if err == nil {
+		err = h.wsManager.SendCommand(clientID, command)
+	}
+	if err != nil {
+		log.Printf("Failed to send command to client %s: %v", clientID, err)
+		upload.MarkFailed(err.Error())
+		h.s3Client.AbortMultipartUpload(ctx, upload.S3Key, multipartUpload.UploadID)
+		return err
+	}
+
+	return nil
+}
+
+// abortIfFinal aborts the multipart upload if the upload reached a final
+// state, e.g. was cancelled, while it was being set up, and reports whether
+// it did
+func (h *Handler) abortIfFinal(ctx context.Context, upload *models.UploadStatus, s3UploadID string) bool {
+	state := upload.GetStatus()
+	if !state.IsFinal() {
+		return false
+	}
+	log.Printf("Upload %s became %s during setup, aborting multipart upload %s", upload.UploadID, state, s3UploadID)
+	if err := h.s3Client.AbortMultipartUpload(ctx, upload.S3Key, s3UploadID); err != nil {
+		log.Printf("Failed to abort multipart upload %s: %v", s3UploadID, err)
+	}
+	return true
+}
+
+// handleDownloadFileResponse completes or aborts the multipart upload based
+// on the outcome reported by the client
+func (h *Handler) handleDownloadFileResponse(clientID string, msg *sharedModels.ResponseMessage) error {
+	// Error responses carry no payload; the command ID is the upload ID
+	var payload sharedModels.DownloadFileResponse
+	uploadID := msg.CommandID
+	if msg.HasPayload() {
+		if err := msg.DecodePayload(&payload); err != nil {
+			return err
+		}
+		uploadID = payload.UploadID
+	}
+
+	upload, exists := h.wsManager.GetUpload(uploadID)
+	if !exists {
+		return nil
+	}
+	if upload.ClientID != clientID {
+		return fmt.Errorf("upload %s does not belong to client %s", uploadID, clientID)
+	}
+	defer h.wsManager.SaveUpload(upload)
+
+	// A late response must not revive an upload that was cancelled or failed,
+	// nor fail one that completed
+	if status := upload.GetStatus(); status.IsFinal() {
+		log.Printf("Ignoring %s response for upload %s in state %s", msg.Status, uploadID, status)
+		return nil
+	}
+
+	switch msg.Status {
+	case sharedModels.ResponseStatusSuccess:
+		log.Printf("Upload %s finished on the client", uploadID)
+
+		// The upload only counts as completed once the object exists on S3,
+		// since webhooks announce the completion to downstream consumers
+		etags := payload.ETags
+
+		// Complete the multipart upload on S3
+		if len(etags) > 0 {
+			h.completeUpload(upload, etags, payload.Checksums)
+		} else {
+			log.Printf("⚠️ No ETags found in payload, cannot complete multipart upload")
+			upload.MarkFailed("client reported no ETags")
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			h.s3Client.AbortMultipartUpload(ctx, upload.S3Key, upload.GetS3UploadID())
+		}
+
+	case sharedModels.ResponseStatusError:
+		if !upload.MarkFailedWithCode(msg.Error, msg.ErrorCode) {
+			return nil
+		}
+		log.Printf("Upload %s failed: %s", uploadID, msg.Error)
+
+		// Abort the multipart upload
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		h.s3Client.AbortMultipartUpload(ctx, upload.S3Key, upload.GetS3UploadID())
+
+	case sharedModels.ResponseStatusCancelled:
+		h.finishCancel(upload)
+	}
+
+	return nil
+}
+
+// completeUpload completes the multipart upload from the parts the client
+// reported. With checksums, the parts must carry the checksums the file was
+// stat'ed with and the object S3 assembles must match them as a whole;
+// otherwise the upload fails rather than leave a corrupt object behind.
+func (h *Handler) completeUpload(upload *models.UploadStatus, etags, reported map[int]string) {
+	checksums := uploadChecksums(upload)
+	parts := make([]s3.CompletedPart, 0, len(etags))
+	for partNum, etag := range etags {
+		parts = append(parts, s3.CompletedPart{
+			PartNumber: partNum,
+			ETag:       etag,
+			Checksum:   checksums.Part(partNum),
+		})
+	}
+
+	// CRITICAL: Sort parts by PartNumber to ensure correct order for S3
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].PartNumber < parts[j].PartNumber
+	})
+
+	log.Printf("Completing multipart upload %s with %d parts", upload.UploadID, len(parts))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Use S3UploadID from upload status, not internal uploadID
+	s3UploadID := upload.GetS3UploadID()
+	if err := verifyPartChecksums(checksums, parts, reported); err != nil {
+		log.Printf("❌ Upload %s failed verification: %v", upload.UploadID, err)
+		upload.MarkFailedWithCode(err.Error(), sharedModels.ErrorCodeChecksumMismatch)
+		h.s3Client.AbortMultipartUpload(ctx, upload.S3Key, s3UploadID)
+		return
+	}
+
+	checksum, err := h.s3Client.CompleteMultipartUpload(ctx, upload.S3Key, s3UploadID, checksums.Algorithm, parts)
+	if err != nil {
+		log.Printf("❌ Failed to complete multipart upload: %v", err)
+		upload.MarkFailed(err.Error())
+		return
+	}
+
+	if checksums.Algorithm != "" && checksum != "" {
+		expected, err := s3.CompositeChecksum(checksums.Algorithm, checksums.Parts)
+		if err == nil && checksum != expected {
+			err = fmt.Errorf("object checksum %s does not match expected %s", checksum, expected)
+		}
+		if err != nil {
+			log.Printf("❌ Upload %s failed verification: %v", upload.UploadID, err)
+			upload.MarkFailedWithCode(err.Error(), sharedModels.ErrorCodeChecksumMismatch)
+			if err := h.s3Client.DeleteObject(ctx, upload.S3Key); err != nil {
+				log.Printf("Failed to delete unverified object %s: %v", upload.S3Key, err)
 			}
+			return
+		}
+	}
+
+	if !upload.MarkCompleted(checksum) {
+		// Cancelled while S3 assembled the object; don't leave it behind
+		log.Printf("Upload %s finished as %s while completing, deleting %s", upload.UploadID, upload.GetStatus(), upload.S3Key)
+		if err := h.s3Client.DeleteObject(ctx, upload.S3Key); err != nil {
+			log.Printf("Failed to delete object %s: %v", upload.S3Key, err)
 		}
+		return
 	}
+	log.Printf("✅ Multipart upload %s completed on S3", s3UploadID)
+}
 
+// verifyPartChecksums checks that the client sent every part and that the
+// checksums it reported match the ones from when the file was stat'ed. Parts
+// uploaded before a resume aren't reported; S3 checks those on completion.
+func verifyPartChecksums(checksums s3.Checksums, parts []s3.CompletedPart, reported map[int]string) error {
+	if checksums.Algorithm == "" {
+		return nil
+	}
+	if len(parts) != len(checksums.Parts) {
+		return fmt.Errorf("client uploaded %d of %d parts", len(parts), len(checksums.Parts))
+	}
+	for _, part := range parts {
+		if part.Checksum == "" {
+			return fmt.Errorf("part %d is out of range 1-%d", part.PartNumber, len(checksums.Parts))
+		}
+		if sent, ok := reported[part.PartNumber]; ok && sent != part.Checksum {
+			return fmt.Errorf("part %d checksum mismatch: expected %s, client sent %s", part.PartNumber, part.Checksum, sent)
+		}
+	}
 	return nil
 }
 
@@ -381,17 +838,208 @@ func (h *Handler) HandleStatus(clientID string, msg *sharedModels.StatusMessage)
 
 	// Update upload progress if available
 	if msg.CurrentUpload != nil {
-		if upload, exists := h.wsManager.GetUpload(msg.CurrentUpload.UploadID); exists {
+		upload, exists := h.wsManager.GetUpload(msg.CurrentUpload.UploadID)
+		if exists && upload.ClientID != clientID {
+			log.Printf("⚠️ Ignoring status from client %s for upload %s of client %s", clientID, upload.UploadID, upload.ClientID)
+			exists = false
+		}
+		if exists {
 			// Update progress based on completed parts
 			// Note: The client will send ETags separately in response messages
-			upload.BytesUploaded = msg.CurrentUpload.BytesUploaded
-			upload.CompletedParts = msg.CurrentUpload.CompletedParts
+			previousParts := upload.UpdateClientProgress(
+				msg.CurrentUpload.BytesUploaded,
+				msg.CurrentUpload.CompletedParts,
+				msg.CurrentUpload.Retries,
+				msg.CurrentUpload.LastError,
+			)
+			h.wsManager.SaveUploadProgress(upload)
+			h.publishProgress(upload, previousParts, msg.CurrentUpload)
+			if msg.CurrentUpload.LastError != "" {
+				log.Printf("Upload %s retrying (%d retries so far): %s", upload.UploadID, msg.CurrentUpload.Retries, msg.CurrentUpload.LastError)
+			}
 		}
 	}
 
 	return nil
 }
 
+// HandleRequest implements websocket.MessageHandler
+func (h *Handler) HandleRequest(clientID string, msg *sharedModels.RequestMessage) error {
+	log.Printf("Received request from client %s: action=%s", clientID, msg.Action)
+
+	var payload interface{}
+	var err error
+
+	switch msg.Action {
+	case sharedModels.CommandActionRequestPartURLs:
+		var resp *sharedModels.PartURLsResponse
+		if resp, err = h.handleRequestPartURLs(clientID, msg); err == nil {
+			payload = resp
+		}
+	case sharedModels.CommandActionResumeUpload:
+		var resp *sharedModels.ResumeUploadResponse
+		if resp, err = h.handleResumeUpload(clientID, msg); err == nil {
+			payload = resp
+		}
+	case sharedModels.CommandActionRefreshToken:
+		var resp *sharedModels.RefreshTokenResponse
+		if resp, err = h.handleRefreshToken(clientID, msg); err == nil {
+			payload = resp
+		}
+	default:
+		err = fmt.Errorf("unknown request action: %s", msg.Action)
+	}
+
+	status := sharedModels.ResponseStatusSuccess
+	errMsg := ""
+	if err != nil {
+		log.Printf("Request %s from client %s failed: %v", msg.Action, clientID, err)
+		status = sharedModels.ResponseStatusError
+		errMsg = err.Error()
+	}
+
+	response, err := sharedModels.NewResponseMessage(status, msg.MessageID, msg.Action, payload, errMsg)
+	if err != nil {
+		// Unknown actions have no registered payload; still tell the client
+		response = &sharedModels.ResponseMessage{
+			Status:    sharedModels.ResponseStatusError,
+			CommandID: msg.MessageID,
+			Action:    msg.Action,
+			Error:     err.Error(),
+		}
+		response.MessageID = msg.MessageID
+	}
+
+	return h.wsManager.SendResponse(clientID, response)
+}
+
+// handleRequestPartURLs presigns a fresh batch of URLs for an upload in progress
+func (h *Handler) handleRequestPartURLs(clientID string, msg *sharedModels.RequestMessage) (*sharedModels.PartURLsResponse, error) {
+	var req sharedModels.RequestPartURLsPayload
+	if err := msg.DecodePayload(&req); err != nil {
+		return nil, err
+	}
+
+	upload, exists := h.wsManager.GetUpload(req.UploadID)
+	if !exists || upload.ClientID != clientID {
+		return nil, fmt.Errorf("upload %s not found", req.UploadID)
+	}
+
+	s3UploadID := upload.GetS3UploadID()
+	if s3UploadID == "" {
+		return nil, fmt.Errorf("upload %s has not been initiated", req.UploadID)
+	}
+	record := upload.Record()
+	if record.Status != models.UploadStatePending && record.Status != models.UploadStateInProgress {
+		return nil, fmt.Errorf("upload %s is %s", req.UploadID, record.Status)
+	}
+
+	if len(req.PartNumbers) == 0 || len(req.PartNumbers) > maxPartURLsPerRequest {
+		return nil, fmt.Errorf("between 1 and %d part numbers must be requested", maxPartURLsPerRequest)
+	}
+	for _, partNumber := range req.PartNumbers {
+		if partNumber < 1 || partNumber > record.TotalParts {
+			return nil, fmt.Errorf("part number %d out of range 1-%d", partNumber, record.TotalParts)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	presignedURLs, err := h.s3Client.PresignUploadParts(ctx, upload.S3Key, s3UploadID, req.PartNumbers, uploadChecksums(upload))
+	if err != nil {
+		return nil, err
+	}
+
+	return &sharedModels.PartURLsResponse{
+		UploadID:      upload.UploadID,
+		PresignedURLs: toSharedPresignedURLs(presignedURLs),
+	}, nil
+}
+
+// handleResumeUpload reconciles a journaled upload with the parts S3 already
+// holds and presigns URLs for the first missing parts
+func (h *Handler) handleResumeUpload(clientID string, msg *sharedModels.RequestMessage) (*sharedModels.ResumeUploadResponse, error) {
+	var req sharedModels.ResumeUploadPayload
+	if err := msg.DecodePayload(&req); err != nil {
+		return nil, err
+	}
+
+	upload, exists := h.wsManager.GetUpload(req.UploadID)
+	if !exists || upload.ClientID != clientID {
+		return nil, fmt.Errorf("upload %s not found", req.UploadID)
+	}
+	defer h.wsManager.SaveUpload(upload)
+
+	s3UploadID := upload.GetS3UploadID()
+	if s3UploadID == "" || s3UploadID != req.S3UploadID {
+		return nil, fmt.Errorf("upload %s does not match S3 upload %s", req.UploadID, req.S3UploadID)
+	}
+	// Snapshot the fields the stat response and other requests update
+	record := upload.Record()
+	if record.Status != models.UploadStatePending && record.Status != models.UploadStateInProgress {
+		return nil, fmt.Errorf("upload %s is %s", req.UploadID, record.Status)
+	}
+	if req.FileSize != record.FileSize {
+		return nil, fmt.Errorf("file size changed: upload was initiated for %d bytes, file has %d", record.FileSize, req.FileSize)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// S3 is the source of truth for which parts actually landed
+	parts, err := h.s3Client.ListParts(ctx, upload.S3Key, s3UploadID)
+	if err != nil {
+		if errors.Is(err, s3.ErrNoSuchUpload) {
+			upload.MarkFailed("multipart upload no longer exists on S3")
+		}
+		return nil, err
+	}
+
+	completed := make(map[int]string, len(parts))
+	var bytesUploaded int64
+	for _, part := range parts {
+		completed[part.PartNumber] = part.ETag
+		bytesUploaded += part.Size
+		if etag, ok := req.CompletedParts[part.PartNumber]; ok && etag != part.ETag {
+			log.Printf("⚠️ Upload %s part %d: client journaled ETag %s, S3 has %s", upload.UploadID, part.PartNumber, etag, part.ETag)
+		}
+	}
+	upload.ReconcileParts(completed, bytesUploaded)
+
+	missing := make([]int, 0, h.urlBatchSize)
+	for partNumber := 1; partNumber <= record.TotalParts && len(missing) < h.urlBatchSize; partNumber++ {
+		if _, ok := completed[partNumber]; !ok {
+			missing = append(missing, partNumber)
+		}
+	}
+
+	log.Printf("🔁 Resuming upload %s for client %s: %d/%d parts already on S3", upload.UploadID, clientID, len(completed), record.TotalParts)
+
+	checksums := uploadChecksums(upload)
+	presignedURLs, err := h.s3Client.PresignUploadParts(ctx, upload.S3Key, s3UploadID, missing, checksums)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sharedModels.ResumeUploadResponse{
+		FilePath: upload.FilePath,
+		UploadConfig: sharedModels.UploadConfig{
+			UploadID:      upload.UploadID,
+			S3UploadID:    s3UploadID,
+			Bucket:        upload.S3Bucket,
+			Key:           upload.S3Key,
+			ChunkSize:     upload.ChunkSize,
+			FileSize:      record.FileSize,
+			TotalParts:    record.TotalParts,
+			PresignedURLs: toSharedPresignedURLs(presignedURLs),
+
+			ChecksumAlgorithm: checksums.Algorithm,
+		},
+		CompletedParts: completed,
+	}, nil
+}
+
 // sendJSON sends a JSON response
 func (h *Handler) sendJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -407,6 +1055,43 @@ func (h *Handler) sendError(w http.ResponseWriter, status int, message string) {
 	})
 }
 
+// sendCommandError maps a failure to reach a client to an HTTP error
+func (h *Handler) sendCommandError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, websocket.ErrUnsupportedAction):
+		h.sendError(w, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, websocket.ErrClientNotConnected):
+		h.sendError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, models.ErrSendQueueFull):
+		h.sendError(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		h.sendError(w, http.StatusInternalServerError, "Failed to send command to client")
+	}
+}
+
+// toSharedPresignedURLs converts S3 presigned URLs to their protocol form
+func toSharedPresignedURLs(urls []s3.PresignedURL) []sharedModels.PresignedURL {
+	presignedURLs := make([]sharedModels.PresignedURL, len(urls))
+	for i, url := range urls {
+		presignedURLs[i] = sharedModels.PresignedURL{
+			PartNumber: url.PartNumber,
+			URL:        url.URL,
+			ExpiresAt:  url.ExpiresAt,
+			Checksum:   url.Checksum,
+		}
+	}
+	return presignedURLs
+}
+
+// uploadChecksums returns the part checksums S3 verifies an upload against
+func uploadChecksums(upload *models.UploadStatus) s3.Checksums {
+	algorithm, parts := upload.GetChecksums()
+	if algorithm == "" {
+		return s3.Checksums{}
+	}
+	return s3.Checksums{Algorithm: algorithm, Parts: parts}
+}
+
 // generateUploadID generates a random upload ID
 func generateUploadID() (string, error) {
 	bytes := make([]byte, 16)
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/iriyanto1027/file-download-system/server/models"
+	sharedModels "github.com/iriyanto1027/file-download-system/shared/models"
+)
+
+// queueExpiryInterval is how often queued downloads are checked for expiry
+const queueExpiryInterval = time.Minute
+
+// HandleClientReady starts the downloads queued for a client and the
+// scheduled downloads it missed while it was offline
+func (h *Handler) HandleClientReady(clientID string) {
+	h.dispatchQueued(clientID)
+	if h.scheduler != nil {
+		h.scheduler.ClientConnected(clientID)
+	}
+}
+
+// queueDownload registers a download for an offline client that starts once
+// the client connects, and answers 202 Accepted
+func (h *Handler) queueDownload(w http.ResponseWriter, r *http.Request, clientID string, req TriggerDownloadRequest) {
+	ttl := h.queueTTL
+	if req.QueueTTL != "" {
+		parsed, err := time.ParseDuration(req.QueueTTL)
+		if err != nil || parsed <= 0 {
+			h.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid queue_ttl %q", req.QueueTTL))
+			return
+		}
+		if parsed > h.maxQueueTTL {
+			h.sendError(w, http.StatusBadRequest, fmt.Sprintf("queue_ttl must not exceed %s", h.maxQueueTTL))
+			return
+		}
+		ttl = parsed
+	}
+
+	upload, err := h.newUpload(clientID, req)
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	upload.Queue(time.Now().Add(ttl))
+	h.wsManager.RegisterUpload(upload)
+	entry := auditEntry(r)
+	entry.UploadID = upload.UploadID
+	entry.FilePath = upload.FilePath
+	entry.S3Key = upload.S3Key
+	log.Printf("📥 Download %s queued until client %s connects (expires in %s)", upload.UploadID, clientID, ttl)
+
+	h.sendJSON(w, http.StatusAccepted, TriggerDownloadResponse{
+		Success:  true,
+		Message:  fmt.Sprintf("Client %s is not connected, download queued", clientID),
+		UploadID: upload.UploadID,
+		S3Key:    upload.S3Key,
+		Status:   models.UploadStateQueued,
+	})
+}
+
+// dispatchQueued starts the downloads queued for a client, oldest first
+func (h *Handler) dispatchQueued(clientID string) {
+	queued := h.wsManager.QueuedUploads(clientID)
+	if len(queued) == 0 {
+		return
+	}
+	log.Printf("📥 Client %s connected, dispatching %d queued downloads", clientID, len(queued))
+
+	supportErr := h.wsManager.CheckSupport(clientID, sharedModels.CommandActionStatFile, sharedModels.CommandActionDownloadFile)
+	for _, upload := range queued {
+		// Expired requests are not started, even if the sweep hasn't run yet
+		if upload.ExpireQueued(time.Now()) {
+			h.wsManager.SaveUpload(upload)
+			continue
+		}
+		// Lost to a concurrent cancel
+		if !upload.Dispatch() {
+			continue
+		}
+
+		if supportErr != nil {
+			upload.MarkFailed(supportErr.Error())
+		} else if err := h.sendStatFile(upload); err != nil {
+			log.Printf("Failed to dispatch queued download %s: %v", upload.UploadID, err)
+		}
+		h.wsManager.SaveUpload(upload)
+	}
+}
+
+// ExpireQueuedDownloads periodically fails queued downloads whose client
+// did not connect before they expired, until ctx is done
+func (h *Handler) ExpireQueuedDownloads(ctx context.Context) {
+	ticker := time.NewTicker(queueExpiryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, upload := range h.wsManager.GetAllUploads() {
+				if upload.ExpireQueued(now) {
+					log.Printf("⌛ Queued download %s for client %s expired", upload.UploadID, upload.ClientID)
+					h.wsManager.SaveUpload(upload)
+				}
+			}
+		}
+	}
+}
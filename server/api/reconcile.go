@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/iriyanto1027/file-download-system/server/models"
+	"github.com/iriyanto1027/file-download-system/server/s3"
+)
+
+// ReconcileUploads reloads persisted uploads at startup and brings the ones
+// that were in flight in line with S3: uploads whose multipart upload still
+// exists pick up the parts S3 holds and wait for the client to resume,
+// queued downloads keep waiting for their client, the others are failed, and
+// multipart uploads no record owns are aborted
+func (h *Handler) ReconcileUploads(ctx context.Context) error {
+	uploads, err := h.wsManager.LoadUploads()
+	if err != nil {
+		return err
+	}
+
+	// S3 upload IDs that must not be aborted as orphans
+	owned := make(map[string]bool)
+	resumable, queued := 0, 0
+
+	for _, upload := range uploads {
+		if upload.GetStatus().IsFinal() {
+			continue
+		}
+
+		s3UploadID := upload.GetS3UploadID()
+		switch {
+		case upload.GetStatus() == models.UploadStateCancelling:
+			// The client can't confirm any more; cancel on our side
+			h.finishCancel(upload)
+
+		case upload.GetStatus() == models.UploadStateQueued:
+			if !upload.ExpireQueued(time.Now()) {
+				queued++
+			}
+
+		case s3UploadID == "":
+			upload.MarkFailed("server restarted before the multipart upload was initiated")
+
+		default:
+			owned[s3UploadID] = true
+
+			parts, err := h.s3Client.ListParts(ctx, upload.S3Key, s3UploadID)
+			if errors.Is(err, s3.ErrNoSuchUpload) {
+				upload.MarkFailed("multipart upload no longer exists on S3")
+				break
+			}
+			if err != nil {
+				// Leave it for the client's resume request to sort out
+				log.Printf("Failed to list parts of upload %s: %v", upload.UploadID, err)
+				break
+			}
+
+			etags := make(map[int]string, len(parts))
+			var bytesUploaded int64
+			for _, part := range parts {
+				etags[part.PartNumber] = part.ETag
+				bytesUploaded += part.Size
+			}
+			upload.ReconcileParts(etags, bytesUploaded)
+			resumable++
+		}
+
+		h.wsManager.SaveUpload(upload)
+	}
+
+	log.Printf("Restored %d uploads, %d waiting for their client to resume, %d queued", len(uploads), resumable, queued)
+
+	return h.abortOrphanedUploads(ctx, owned)
+}
+
+// abortOrphanedUploads aborts multipart uploads under the base path that no
+// upload record owns, e.g. left behind by a crash before the record was saved
+func (h *Handler) abortOrphanedUploads(ctx context.Context, owned map[string]bool) error {
+	pending, err := h.s3Client.ListMultipartUploads(ctx, h.baseS3Path+"/")
+	if err != nil {
+		return fmt.Errorf("failed to find orphaned multipart uploads: %w", err)
+	}
+
+	for _, upload := range pending {
+		if owned[upload.UploadID] {
+			continue
+		}
+		log.Printf("Aborting orphaned multipart upload %s for %s", upload.UploadID, upload.Key)
+		if err := h.s3Client.AbortMultipartUpload(ctx, upload.Key, upload.UploadID); err != nil {
+			log.Printf("Failed to abort orphaned multipart upload %s: %v", upload.UploadID, err)
+		}
+	}
+	return nil
+}
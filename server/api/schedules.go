@@ -0,0 +1,162 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iriyanto1027/file-download-system/server/batch"
+	"github.com/iriyanto1027/file-download-system/server/models"
+	"github.com/iriyanto1027/file-download-system/server/scheduler"
+)
+
+// CreateScheduleRequest is the request body for creating a schedule
+type CreateScheduleRequest struct {
+	Name          string                `json:"name,omitempty"`
+	Cron          string                `json:"cron"`
+	Timezone      string                `json:"timezone,omitempty"`
+	Selector      models.ClientSelector `json:"selector"`
+	JitterSeconds int                   `json:"jitter_seconds,omitempty"`
+	SkipMissed    bool                  `json:"skip_missed,omitempty"`
+	TriggerDownloadRequest
+}
+
+// ListSchedulesResponse is the response for listing schedules
+type ListSchedulesResponse struct {
+	Schedules []*scheduler.Info `json:"schedules"`
+	Count     int               `json:"count"`
+}
+
+// SetScheduler sets the scheduler serving the /schedules endpoints
+func (h *Handler) SetScheduler(s *scheduler.Scheduler) {
+	h.scheduler = s
+}
+
+// HandleSchedules handles GET and POST /schedules, and GET and DELETE
+// /schedules/{schedule_id}
+func (h *Handler) HandleSchedules(w http.ResponseWriter, r *http.Request) {
+	if h.scheduler == nil {
+		h.sendError(w, http.StatusServiceUnavailable, "Scheduler is not configured")
+		return
+	}
+
+	scheduleID := strings.Trim(strings.TrimPrefix(r.URL.Path, "/schedules"), "/")
+	if scheduleID == "" {
+		h.handleScheduleCollection(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		info, err := h.scheduler.Get(scheduleID)
+		if errors.Is(err, scheduler.ErrNotFound) {
+			h.sendError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		h.sendJSON(w, http.StatusOK, info)
+
+	case http.MethodDelete:
+		err := h.scheduler.Delete(scheduleID)
+		if errors.Is(err, scheduler.ErrNotFound) {
+			h.sendError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			h.sendError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handleScheduleCollection handles GET and POST /schedules
+func (h *Handler) handleScheduleCollection(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		schedules := h.scheduler.List()
+		h.sendJSON(w, http.StatusOK, ListSchedulesResponse{Schedules: schedules, Count: len(schedules)})
+
+	case http.MethodPost:
+		var req CreateScheduleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.sendError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		if req.FilePath == "" {
+			req.FilePath = "/data/test-file.bin"
+		}
+
+		info, err := h.scheduler.Create(models.Schedule{
+			Name:          req.Name,
+			Cron:          req.Cron,
+			Timezone:      req.Timezone,
+			Selector:      req.Selector,
+			FilePath:      req.FilePath,
+			Metadata:      req.Metadata,
+			ComputeHash:   req.ComputeHash,
+			JitterSeconds: req.JitterSeconds,
+			SkipMissed:    req.SkipMissed,
+		})
+		if errors.Is(err, scheduler.ErrInvalidSchedule) {
+			h.sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			h.sendError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		entry := auditEntry(r)
+		entry.FilePath = req.FilePath
+		entry.Details = map[string]string{"schedule_id": info.ID}
+		h.sendJSON(w, http.StatusCreated, info)
+
+	default:
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// IsClientConnected reports whether a client is online
+func (h *Handler) IsClientConnected(clientID string) bool {
+	return h.wsManager.IsClientConnected(clientID)
+}
+
+// ClientLabels returns the labels of a connected client
+func (h *Handler) ClientLabels(clientID string) (map[string]string, bool) {
+	client, exists := h.wsManager.GetClient(clientID)
+	if !exists {
+		return nil, false
+	}
+	capabilities, _ := client.GetCapabilities()
+	return capabilities.Labels, true
+}
+
+// StartScheduledBatch starts a schedule's download on the clients as a batch
+func (h *Handler) StartScheduledBatch(schedule *models.Schedule, clientIDs []string) string {
+	trigger := TriggerDownloadRequest{
+		FilePath:    schedule.FilePath,
+		Metadata:    schedule.Metadata,
+		ComputeHash: schedule.ComputeHash,
+	}
+	opts := batch.Options{Jitter: time.Duration(schedule.JitterSeconds) * time.Second}
+
+	info := h.batches.Start(h.ctx, schedule.Selector, schedule.FilePath, clientIDs, opts, func(clientID string) (*models.UploadStatus, error) {
+		return h.startDownload(clientID, trigger)
+	})
+	h.auditLog.Record(models.AuditEntry{
+		Action:   models.AuditActionScheduleFired,
+		Actor:    "scheduler",
+		FilePath: schedule.FilePath,
+		Details: map[string]string{
+			"schedule_id": schedule.ID,
+			"batch_id":    info.BatchID,
+			"clients":     strconv.Itoa(len(clientIDs)),
+		},
+	})
+	return info.BatchID
+}
@@ -0,0 +1,291 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/iriyanto1027/file-download-system/server/models"
+	"github.com/iriyanto1027/file-download-system/server/revocation"
+	"github.com/iriyanto1027/file-download-system/shared/auth"
+	sharedModels "github.com/iriyanto1027/file-download-system/shared/models"
+)
+
+// IssueTokenRequest is the optional request body for issuing a client token
+type IssueTokenRequest struct {
+	ExpiresIn string `json:"expires_in,omitempty"` // Duration such as "720h"; server default if empty
+}
+
+// IssueTokenResponse is the response for issuing a client token
+type IssueTokenResponse struct {
+	ClientID  string    `json:"client_id"`
+	TokenID   string    `json:"token_id"` // The token's jti, for revoking it
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// IssueOperatorTokenRequest is the request body for issuing an operator token
+type IssueOperatorTokenRequest struct {
+	Role      auth.Role `json:"role"`
+	ExpiresIn string    `json:"expires_in,omitempty"` // Duration such as "8h"; server default if empty
+}
+
+// IssueOperatorTokenResponse is the response for issuing an operator token
+type IssueOperatorTokenResponse struct {
+	Operator  string    `json:"operator"`
+	Role      auth.Role `json:"role"`
+	TokenID   string    `json:"token_id"` // The token's jti, for revoking it
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// RevokeRequest is the request body for revoking a token, or every token
+// issued to a client if only client_id is set
+type RevokeRequest struct {
+	TokenID  string `json:"token_id,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// RevokeResponse is the response for a revocation
+type RevokeResponse struct {
+	*models.Revocation
+	Disconnected []string `json:"disconnected"` // Clients whose live session used a revoked token
+}
+
+// HandleAdminClient handles POST /admin/clients/{client_id}/token
+func (h *Handler) HandleAdminClient(w http.ResponseWriter, r *http.Request) {
+	clientID, action, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/clients"), "/"), "/")
+	if clientID == "" || action != "token" {
+		h.sendError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	if r.Method != http.MethodPost {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if h.tokens == nil {
+		h.sendError(w, http.StatusServiceUnavailable, "Token authentication is not configured")
+		return
+	}
+	if !auth.ValidateClientID(clientID) {
+		h.sendError(w, http.StatusBadRequest, "Invalid client ID")
+		return
+	}
+
+	var req IssueTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		h.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	ttl := h.tokenTTL
+	if req.ExpiresIn != "" {
+		parsed, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || parsed <= 0 {
+			h.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid expires_in %q", req.ExpiresIn))
+			return
+		}
+		ttl = parsed
+	}
+
+	token, err := h.tokens.GenerateToken(clientID, ttl)
+	if errors.Is(err, auth.ErrNoSigningKey) {
+		h.sendError(w, http.StatusServiceUnavailable, "This server only verifies tokens, it has no signing key")
+		return
+	}
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+	claims, err := h.tokens.ValidateToken(token)
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, "Failed to read generated token")
+		return
+	}
+	log.Printf("🔑 Issued token %s for client %s, valid for %s", claims.ID, clientID, ttl)
+	entry := auditEntry(r)
+	entry.ClientID = clientID
+	entry.TokenID = claims.ID
+
+	h.sendJSON(w, http.StatusCreated, IssueTokenResponse{
+		ClientID:  clientID,
+		TokenID:   claims.ID,
+		Token:     token,
+		ExpiresAt: claims.ExpiresAt.Time,
+	})
+}
+
+// HandleAdminOperator handles POST /admin/operators/{name}/token, issuing a
+// JWT for the HTTP API with the requested role
+func (h *Handler) HandleAdminOperator(w http.ResponseWriter, r *http.Request) {
+	name, action, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/operators"), "/"), "/")
+	if name == "" || action != "token" {
+		h.sendError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	if r.Method != http.MethodPost {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if h.tokens == nil {
+		h.sendError(w, http.StatusServiceUnavailable, "Token authentication is not configured")
+		return
+	}
+
+	var req IssueOperatorTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if !req.Role.IsValid() {
+		h.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid role %q, expected viewer, operator or admin", req.Role))
+		return
+	}
+
+	ttl := h.tokenTTL
+	if req.ExpiresIn != "" {
+		parsed, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || parsed <= 0 {
+			h.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid expires_in %q", req.ExpiresIn))
+			return
+		}
+		ttl = parsed
+	}
+
+	token, err := h.tokens.GenerateOperatorToken(name, req.Role, ttl)
+	if errors.Is(err, auth.ErrNoSigningKey) {
+		h.sendError(w, http.StatusServiceUnavailable, "This server only verifies tokens, it has no signing key")
+		return
+	}
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+	claims, err := h.tokens.ValidateToken(token)
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, "Failed to read generated token")
+		return
+	}
+	log.Printf("🔑 Issued %s token %s for operator %s, valid for %s", req.Role, claims.ID, name, ttl)
+	entry := auditEntry(r)
+	entry.TokenID = claims.ID
+	entry.Details = map[string]string{"operator": name, "role": string(req.Role)}
+
+	h.sendJSON(w, http.StatusCreated, IssueOperatorTokenResponse{
+		Operator:  name,
+		Role:      req.Role,
+		TokenID:   claims.ID,
+		Token:     token,
+		ExpiresAt: claims.ExpiresAt.Time,
+	})
+}
+
+// HandleRevocations handles GET and POST /admin/revocations
+func (h *Handler) HandleRevocations(w http.ResponseWriter, r *http.Request) {
+	if h.revocations == nil {
+		h.sendError(w, http.StatusServiceUnavailable, "Token authentication is not configured")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		revocations := h.revocations.List()
+		h.sendJSON(w, http.StatusOK, struct {
+			Revocations []*models.Revocation `json:"revocations"`
+			Count       int                  `json:"count"`
+		}{revocations, len(revocations)})
+
+	case http.MethodPost:
+		var req RevokeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.sendError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		entry := auditEntry(r)
+		entry.TokenID = req.TokenID
+		entry.ClientID = req.ClientID
+
+		revoked, err := h.revocations.Revoke(models.Revocation{
+			TokenID:  req.TokenID,
+			ClientID: req.ClientID,
+			Reason:   req.Reason,
+		})
+		if errors.Is(err, revocation.ErrInvalidRevocation) {
+			h.sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			h.sendError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		h.sendJSON(w, http.StatusCreated, RevokeResponse{
+			Revocation:   revoked,
+			Disconnected: h.wsManager.DisconnectRevoked(h.revocations),
+		})
+
+	default:
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// JWKS handles GET /.well-known/jwks.json, publishing the public keys that
+// verify client tokens so other services can check them
+func (h *Handler) JWKS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	jwks := auth.JWKS{Keys: []auth.JWK{}}
+	if h.tokens != nil {
+		jwks = h.tokens.JWKS()
+	}
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	h.sendJSON(w, http.StatusOK, jwks)
+}
+
+// handleRefreshToken replaces a connected client's token before it
+// expires; the token must be valid and belong to the client
+func (h *Handler) handleRefreshToken(clientID string, msg *sharedModels.RequestMessage) (*sharedModels.RefreshTokenResponse, error) {
+	if h.tokens == nil {
+		return nil, errors.New("token authentication is not configured")
+	}
+
+	var req sharedModels.RefreshTokenPayload
+	if err := msg.DecodePayload(&req); err != nil {
+		return nil, err
+	}
+
+	claims, err := h.tokens.ValidateToken(req.Token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.ClientID != clientID {
+		return nil, errors.New("token belongs to another client")
+	}
+
+	token, err := h.tokens.RefreshToken(req.Token, h.tokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	refreshed, err := h.tokens.ValidateToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	// The session now runs on the new token, so revoking it disconnects
+	if client, exists := h.wsManager.GetClient(clientID); exists {
+		client.SetToken(refreshed)
+	}
+	log.Printf("🔑 Refreshed token for client %s: %s replaces %s", clientID, refreshed.ID, claims.ID)
+
+	return &sharedModels.RefreshTokenResponse{Token: token, ExpiresAt: refreshed.ExpiresAt.Time}, nil
+}
@@ -0,0 +1,186 @@
+package api
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iriyanto1027/file-download-system/server/models"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+// ListUploadsResponse is the response for listing uploads
+type ListUploadsResponse struct {
+	Uploads    []models.UploadInfo `json:"uploads"`
+	Count      int                 `json:"count"`
+	NextCursor string              `json:"next_cursor,omitempty"` // Pass as cursor to get the next page
+}
+
+// uploadFilter holds the parsed query of GET /uploads
+type uploadFilter struct {
+	clientID string
+	states   map[models.UploadState]bool
+	since    time.Time
+	until    time.Time
+	limit    int
+	after    *uploadCursor
+}
+
+// uploadCursor is the position of the last upload on a page; uploads are
+// ordered newest first by start time, then by upload ID
+type uploadCursor struct {
+	startTime time.Time
+	uploadID  string
+}
+
+// ListUploads handles GET /uploads?client_id=&state=&since=&until=&limit=&cursor=
+func (h *Handler) ListUploads(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	filter, err := parseUploadFilter(r)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var uploads []*models.UploadStatus
+	if filter.clientID != "" {
+		uploads = h.wsManager.GetClientUploads(filter.clientID)
+	} else {
+		uploads = h.wsManager.GetAllUploads()
+	}
+
+	infos := make([]models.UploadInfo, 0, len(uploads))
+	for _, upload := range uploads {
+		info := upload.Info()
+		if filter.matches(info) {
+			// The history is only returned by GET /uploads/{upload_id}
+			info.Transitions = nil
+			infos = append(infos, info)
+		}
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return uploadBefore(infos[i].StartTime, infos[i].UploadID, infos[j].StartTime, infos[j].UploadID)
+	})
+
+	response := ListUploadsResponse{Uploads: infos}
+	if len(infos) > filter.limit {
+		response.Uploads = infos[:filter.limit]
+		last := response.Uploads[filter.limit-1]
+		response.NextCursor = encodeUploadCursor(uploadCursor{startTime: last.StartTime, uploadID: last.UploadID})
+	}
+	response.Count = len(response.Uploads)
+
+	h.sendJSON(w, http.StatusOK, response)
+}
+
+// matches reports whether an upload passes the filter and comes after the cursor
+func (f *uploadFilter) matches(info models.UploadInfo) bool {
+	if len(f.states) > 0 && !f.states[info.Status] {
+		return false
+	}
+	if !f.since.IsZero() && info.StartTime.Before(f.since) {
+		return false
+	}
+	if !f.until.IsZero() && !info.StartTime.Before(f.until) {
+		return false
+	}
+	if f.after != nil && !uploadBefore(f.after.startTime, f.after.uploadID, info.StartTime, info.UploadID) {
+		return false
+	}
+	return true
+}
+
+// uploadBefore reports whether upload a is listed before upload b
+func uploadBefore(aStart time.Time, aID string, bStart time.Time, bID string) bool {
+	if !aStart.Equal(bStart) {
+		return aStart.After(bStart)
+	}
+	return aID > bID
+}
+
+// parseUploadFilter parses and validates the query of GET /uploads
+func parseUploadFilter(r *http.Request) (*uploadFilter, error) {
+	query := r.URL.Query()
+	filter := &uploadFilter{
+		clientID: query.Get("client_id"),
+		limit:    defaultListLimit,
+	}
+
+	if state := query.Get("state"); state != "" {
+		filter.states = make(map[models.UploadState]bool)
+		for _, s := range strings.Split(state, ",") {
+			uploadState := models.UploadState(strings.TrimSpace(s))
+			if !uploadState.IsValid() {
+				return nil, fmt.Errorf("unknown state %q", s)
+			}
+			filter.states[uploadState] = true
+		}
+	}
+
+	var err error
+	if since := query.Get("since"); since != "" {
+		if filter.since, err = time.Parse(time.RFC3339, since); err != nil {
+			return nil, fmt.Errorf("since must be an RFC 3339 time: %v", err)
+		}
+	}
+	if until := query.Get("until"); until != "" {
+		if filter.until, err = time.Parse(time.RFC3339, until); err != nil {
+			return nil, fmt.Errorf("until must be an RFC 3339 time: %v", err)
+		}
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		filter.limit, err = strconv.Atoi(limit)
+		if err != nil || filter.limit < 1 || filter.limit > maxListLimit {
+			return nil, fmt.Errorf("limit must be between 1 and %d", maxListLimit)
+		}
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		after, err := decodeUploadCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		filter.after = after
+	}
+
+	return filter, nil
+}
+
+// encodeUploadCursor encodes a page position as an opaque string
+func encodeUploadCursor(cursor uploadCursor) string {
+	raw := cursor.startTime.UTC().Format(time.RFC3339Nano) + "|" + cursor.uploadID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeUploadCursor parses a cursor returned by a previous page
+func decodeUploadCursor(cursor string) (*uploadCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	startTime, uploadID, ok := strings.Cut(string(raw), "|")
+	if !ok || uploadID == "" {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, startTime)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	return &uploadCursor{startTime: parsed, uploadID: uploadID}, nil
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/iriyanto1027/file-download-system/server/models"
+	"github.com/iriyanto1027/file-download-system/server/webhooks"
+)
+
+// CreateWebhookRequest is the request body for creating a webhook
+type CreateWebhookRequest struct {
+	URL      string                `json:"url"`
+	Secret   string                `json:"secret,omitempty"` // Generated if empty
+	Events   []models.WebhookEvent `json:"events,omitempty"` // All lifecycle events if empty
+	ClientID string                `json:"client_id,omitempty"`
+}
+
+// WebhookResponse describes a webhook; the secret is only returned on creation
+type WebhookResponse struct {
+	ID        string                `json:"id"`
+	URL       string                `json:"url"`
+	Secret    string                `json:"secret,omitempty"`
+	Events    []models.WebhookEvent `json:"events,omitempty"`
+	ClientID  string                `json:"client_id,omitempty"`
+	CreatedAt time.Time             `json:"created_at"`
+}
+
+// ListWebhooksResponse is the response for listing webhooks
+type ListWebhooksResponse struct {
+	Webhooks []WebhookResponse `json:"webhooks"`
+	Count    int               `json:"count"`
+}
+
+// WebhookDeliveriesResponse is the response for a webhook's delivery log
+type WebhookDeliveriesResponse struct {
+	WebhookID  string              `json:"webhook_id"`
+	Deliveries []webhooks.Delivery `json:"deliveries"`
+	Count      int                 `json:"count"`
+}
+
+// HandleWebhooks handles GET and POST /webhooks
+func (h *Handler) HandleWebhooks(w http.ResponseWriter, r *http.Request) {
+	if h.webhooks == nil {
+		h.sendError(w, http.StatusServiceUnavailable, "Webhooks are not configured")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		subscriptions := h.webhooks.List()
+		response := ListWebhooksResponse{Webhooks: make([]WebhookResponse, 0, len(subscriptions))}
+		for _, subscription := range subscriptions {
+			response.Webhooks = append(response.Webhooks, webhookResponse(subscription, false))
+		}
+		response.Count = len(response.Webhooks)
+		h.sendJSON(w, http.StatusOK, response)
+
+	case http.MethodPost:
+		var req CreateWebhookRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.sendError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		subscription, err := h.webhooks.Create(models.WebhookSubscription{
+			URL:      req.URL,
+			Secret:   req.Secret,
+			Events:   req.Events,
+			ClientID: req.ClientID,
+		})
+		if errors.Is(err, webhooks.ErrInvalidSubscription) {
+			h.sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			h.sendError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		h.sendJSON(w, http.StatusCreated, webhookResponse(subscription, true))
+
+	default:
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// HandleWebhook dispatches requests to /webhooks/{id} and
+// /webhooks/{id}/deliveries
+func (h *Handler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
+	if h.webhooks == nil {
+		h.sendError(w, http.StatusServiceUnavailable, "Webhooks are not configured")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/webhooks/"), "/"), "/")
+	if parts[0] == "" || len(parts) > 2 || (len(parts) == 2 && parts[1] != "deliveries") {
+		h.sendError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	id := parts[0]
+
+	switch {
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		deliveries, err := h.webhooks.Deliveries(id)
+		if err != nil {
+			h.sendWebhookError(w, err)
+			return
+		}
+		h.sendJSON(w, http.StatusOK, WebhookDeliveriesResponse{
+			WebhookID:  id,
+			Deliveries: deliveries,
+			Count:      len(deliveries),
+		})
+
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		subscription, err := h.webhooks.Get(id)
+		if err != nil {
+			h.sendWebhookError(w, err)
+			return
+		}
+		h.sendJSON(w, http.StatusOK, webhookResponse(subscription, false))
+
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		if err := h.webhooks.Delete(id); err != nil {
+			h.sendWebhookError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		h.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// sendWebhookError maps a dispatcher error to an HTTP error
+func (h *Handler) sendWebhookError(w http.ResponseWriter, err error) {
+	if errors.Is(err, webhooks.ErrNotFound) {
+		h.sendError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	h.sendError(w, http.StatusInternalServerError, err.Error())
+}
+
+// webhookResponse converts a subscription, keeping the secret only if asked
+func webhookResponse(subscription *models.WebhookSubscription, withSecret bool) WebhookResponse {
+	response := WebhookResponse{
+		ID:        subscription.ID,
+		URL:       subscription.URL,
+		Events:    subscription.Events,
+		ClientID:  subscription.ClientID,
+		CreatedAt: subscription.CreatedAt,
+	}
+	if withSecret {
+		response.Secret = subscription.Secret
+	}
+	return response
+}
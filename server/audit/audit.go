@@ -0,0 +1,153 @@
+package audit
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/iriyanto1027/file-download-system/server/events"
+	"github.com/iriyanto1027/file-download-system/server/models"
+	"github.com/iriyanto1027/file-download-system/server/store"
+)
+
+// UploadLookup finds an upload by ID
+type UploadLookup func(uploadID string) (*models.UploadStatus, bool)
+
+// Filter selects audit entries; empty fields match all
+type Filter struct {
+	Action   models.AuditAction
+	ClientID string
+	Actor    string
+	Since    time.Time // Inclusive
+	Until    time.Time // Exclusive
+	AfterID  uint64    // Only entries written after this one
+}
+
+// Matches reports whether an entry passes the filter
+func (f Filter) Matches(entry *models.AuditEntry) bool {
+	if f.Action != "" && f.Action != entry.Action {
+		return false
+	}
+	if f.ClientID != "" && f.ClientID != entry.ClientID {
+		return false
+	}
+	if f.Actor != "" && f.Actor != entry.Actor {
+		return false
+	}
+	if !f.Since.IsZero() && entry.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !entry.Time.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Log writes the audit log: entries recorded by the API and WebSocket
+// handlers, and upload completions picked up from the event broker
+type Log struct {
+	store  store.AuditStore
+	broker *events.Broker
+	lookup UploadLookup
+	wg     sync.WaitGroup
+}
+
+// New creates an audit log writing to the store
+func New(auditStore store.AuditStore, broker *events.Broker, lookup UploadLookup) *Log {
+	return &Log{
+		store:  auditStore,
+		broker: broker,
+		lookup: lookup,
+	}
+}
+
+// Record appends an entry, stamping its time and defaulting its result to
+// success. A failed write is logged rather than failing the audited action.
+// Recording on a nil Log does nothing, so callers needn't check.
+func (l *Log) Record(entry models.AuditEntry) {
+	if l == nil {
+		return
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	if entry.Result == "" {
+		entry.Result = models.AuditResultSuccess
+	}
+
+	if err := l.store.AppendAudit(&entry); err != nil {
+		log.Printf("❌ Failed to write audit entry %s for %s: %v", entry.Action, entry.ClientID, err)
+	}
+}
+
+// Query calls fn with every entry matching the filter, oldest first, until
+// fn returns false
+func (l *Log) Query(filter Filter, fn func(entry *models.AuditEntry) bool) error {
+	return l.store.ScanAudit(filter.AfterID, func(entry *models.AuditEntry) bool {
+		if !filter.Matches(entry) {
+			return true
+		}
+		return fn(entry)
+	})
+}
+
+// Start records upload completions from the event broker until ctx is done
+func (l *Log) Start(ctx context.Context) {
+	sub := l.broker.Subscribe(events.Filter{}, 0)
+
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+
+		var lastEventID uint64
+		for {
+			select {
+			case <-ctx.Done():
+				sub.Close()
+				return
+
+			case event, ok := <-sub.Events():
+				if !ok {
+					// Dropped for falling behind; pick up where we left off
+					log.Printf("⚠️ Audit log fell behind, resubscribing from event %d", lastEventID)
+					sub = l.broker.Subscribe(events.Filter{}, lastEventID)
+					continue
+				}
+				lastEventID = event.ID
+				l.handleEvent(event)
+			}
+		}
+	}()
+}
+
+// Wait blocks until the event loop has stopped
+func (l *Log) Wait() {
+	l.wg.Wait()
+}
+
+// handleEvent records an upload that reached S3
+func (l *Log) handleEvent(event events.Event) {
+	if event.Type != events.EventTypeState || event.State == nil || event.State.To != models.UploadStateCompleted {
+		return
+	}
+	upload, exists := l.lookup(event.UploadID)
+	if !exists {
+		return
+	}
+
+	info := upload.Info()
+	entry := models.AuditEntry{
+		Time:     event.Time,
+		Action:   models.AuditActionUploadCompleted,
+		ClientID: info.ClientID,
+		FilePath: info.FilePath,
+		UploadID: info.UploadID,
+		S3Key:    info.S3Key,
+		FileSize: info.FileSize,
+	}
+	if info.FileHash != "" {
+		entry.Checksum = "sha256:" + info.FileHash
+	}
+	l.Record(entry)
+}
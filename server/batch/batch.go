@@ -0,0 +1,51 @@
+package batch
+
+import (
+	"time"
+
+	"github.com/iriyanto1027/file-download-system/server/models"
+)
+
+// ItemState is the state of one client's download within a batch; once
+// started it follows the upload's state
+type ItemState string
+
+const (
+	ItemStateQueued ItemState = "queued" // Waiting for a free slot
+	ItemStateFailed ItemState = "failed" // Could not be started or the upload failed
+)
+
+// Status is the overall state of a batch
+type Status string
+
+const (
+	StatusRunning  Status = "running"
+	StatusFinished Status = "finished"
+)
+
+// Item is one client's download within a batch
+type Item struct {
+	ClientID string    `json:"client_id"`
+	UploadID string    `json:"upload_id,omitempty"`
+	State    ItemState `json:"state"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Info is a snapshot of a batch and its aggregate progress
+type Info struct {
+	BatchID    string                `json:"batch_id"`
+	Selector   models.ClientSelector `json:"selector"`
+	FilePath   string                `json:"file_path"`
+	Status     Status                `json:"status"`
+	Total      int                   `json:"total"`
+	Counts     map[ItemState]int     `json:"counts"`   // Items per state
+	Progress   float64               `json:"progress"` // Average upload progress, 0-100
+	CreatedAt  time.Time             `json:"created_at"`
+	FinishedAt *time.Time            `json:"finished_at,omitempty"`
+	Items      []Item                `json:"items"`
+}
+
+// itemStateFor maps an upload state to the item state reported for it
+func itemStateFor(state models.UploadState) ItemState {
+	return ItemState(state)
+}
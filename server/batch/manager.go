@@ -0,0 +1,276 @@
+package batch
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	mathrand "math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/iriyanto1027/file-download-system/server/events"
+	"github.com/iriyanto1027/file-download-system/server/models"
+)
+
+// ErrNotFound is returned for an unknown batch ID
+var ErrNotFound = errors.New("batch not found")
+
+// StartFunc triggers the download on one client and returns its upload
+type StartFunc func(clientID string) (*models.UploadStatus, error)
+
+// UploadLookup finds an upload by ID
+type UploadLookup func(uploadID string) (*models.UploadStatus, bool)
+
+// Config holds the batch manager configuration
+type Config struct {
+	MaxConcurrent int           // Batch uploads running at once across all batches
+	SlotTimeout   time.Duration // A slot is freed after this even if its upload is still running
+	PollInterval  time.Duration // How often a running upload is re-checked between events
+	Retention     time.Duration // Finished batches are forgotten this long after they finish
+}
+
+// Manager runs bulk downloads, sharing one concurrency limit between all
+// batches
+type Manager struct {
+	config  Config
+	broker  *events.Broker
+	lookup  UploadLookup
+	slots   chan struct{}
+	mu      sync.RWMutex
+	batches map[string]*batch
+}
+
+// batch is the mutable state of a running batch
+type batch struct {
+	id        string
+	selector  models.ClientSelector
+	filePath  string
+	createdAt time.Time
+	items     []*Item
+}
+
+// NewManager creates a new batch manager
+func NewManager(cfg Config, broker *events.Broker, lookup UploadLookup) *Manager {
+	if cfg.MaxConcurrent <= 0 {
+		cfg.MaxConcurrent = 10
+	}
+	if cfg.SlotTimeout == 0 {
+		cfg.SlotTimeout = time.Hour
+	}
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = 5 * time.Second
+	}
+	if cfg.Retention == 0 {
+		cfg.Retention = 24 * time.Hour
+	}
+
+	return &Manager{
+		config:  cfg,
+		broker:  broker,
+		lookup:  lookup,
+		slots:   make(chan struct{}, cfg.MaxConcurrent),
+		batches: make(map[string]*batch),
+	}
+}
+
+// Options tune how a batch starts its downloads
+type Options struct {
+	Jitter time.Duration // Each client waits a random delay up to this before it queues for a slot
+}
+
+// Start creates a batch for the given clients and starts their downloads in
+// the background as slots free up
+func (m *Manager) Start(ctx context.Context, selector models.ClientSelector, filePath string, clientIDs []string, opts Options, start StartFunc) Info {
+	b := &batch{
+		id:        "batch_" + randomHex(8),
+		selector:  selector,
+		filePath:  filePath,
+		createdAt: time.Now(),
+	}
+	for _, clientID := range clientIDs {
+		b.items = append(b.items, &Item{ClientID: clientID, State: ItemStateQueued})
+	}
+
+	m.mu.Lock()
+	m.evictFinished(time.Now())
+	m.batches[b.id] = b
+	m.mu.Unlock()
+
+	log.Printf("📦 Batch %s created for %d clients", b.id, len(clientIDs))
+
+	for _, item := range b.items {
+		go m.run(ctx, b, item, opts, start)
+	}
+
+	info, _ := m.Get(b.id)
+	return info
+}
+
+// Get returns a snapshot of a batch
+func (m *Manager) Get(batchID string) (Info, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	b, exists := m.batches[batchID]
+	if !exists {
+		return Info{}, fmt.Errorf("%w: %s", ErrNotFound, batchID)
+	}
+	return m.info(b), nil
+}
+
+// List returns a snapshot of every batch, newest first
+func (m *Manager) List() []Info {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	infos := make([]Info, 0, len(m.batches))
+	for _, b := range m.batches {
+		infos = append(infos, m.info(b))
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].CreatedAt.After(infos[j].CreatedAt)
+	})
+	return infos
+}
+
+// evictFinished forgets batches that finished more than the retention
+// period ago. The caller must hold the lock.
+func (m *Manager) evictFinished(now time.Time) {
+	for id, b := range m.batches {
+		info := m.info(b)
+		if info.FinishedAt != nil && now.Sub(*info.FinishedAt) > m.config.Retention {
+			delete(m.batches, id)
+		}
+	}
+}
+
+// run starts one item once a slot is free and holds the slot until its
+// upload finishes
+func (m *Manager) run(ctx context.Context, b *batch, item *Item, opts Options, start StartFunc) {
+	// Spread the fleet out instead of every client uploading at once
+	if opts.Jitter > 0 {
+		select {
+		case <-time.After(time.Duration(mathrand.Int63n(int64(opts.Jitter)))):
+		case <-ctx.Done():
+			m.setItem(item, "", ItemStateFailed, ctx.Err().Error())
+			return
+		}
+	}
+
+	select {
+	case m.slots <- struct{}{}:
+	case <-ctx.Done():
+		m.setItem(item, "", ItemStateFailed, ctx.Err().Error())
+		return
+	}
+	defer func() { <-m.slots }()
+
+	upload, err := start(item.ClientID)
+	if err != nil {
+		log.Printf("❌ Batch %s: failed to start download on %s: %v", b.id, item.ClientID, err)
+		m.setItem(item, "", ItemStateFailed, err.Error())
+		return
+	}
+	m.setItem(item, upload.UploadID, itemStateFor(upload.GetStatus()), "")
+
+	m.waitForUpload(ctx, upload)
+}
+
+// waitForUpload blocks until the upload reaches a final state, the slot
+// times out or ctx is cancelled
+func (m *Manager) waitForUpload(ctx context.Context, upload *models.UploadStatus) {
+	sub := m.broker.Subscribe(events.Filter{UploadID: upload.UploadID}, 0)
+	defer sub.Close()
+
+	timeout := time.NewTimer(m.config.SlotTimeout)
+	defer timeout.Stop()
+	poll := time.NewTicker(m.config.PollInterval)
+	defer poll.Stop()
+
+	eventsCh := sub.Events()
+	for !upload.GetStatus().IsFinal() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timeout.C:
+			log.Printf("⚠️ Upload %s still %s after %v, freeing its batch slot", upload.UploadID, upload.GetStatus(), m.config.SlotTimeout)
+			return
+		case _, ok := <-eventsCh:
+			if !ok {
+				// Dropped by the broker; keep polling instead
+				eventsCh = nil
+			}
+		case <-poll.C:
+		}
+	}
+}
+
+// setItem updates an item under the lock
+func (m *Manager) setItem(item *Item, uploadID string, state ItemState, errMsg string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if uploadID != "" {
+		item.UploadID = uploadID
+	}
+	item.State = state
+	item.Error = errMsg
+}
+
+// info builds a snapshot of a batch, refreshing started items from their
+// uploads; the batch is finished once every item is final. The caller must
+// hold the lock.
+func (m *Manager) info(b *batch) Info {
+	info := Info{
+		BatchID:   b.id,
+		Selector:  b.selector,
+		FilePath:  b.filePath,
+		Status:    StatusFinished,
+		Total:     len(b.items),
+		Counts:    make(map[ItemState]int),
+		CreatedAt: b.createdAt,
+		Items:     make([]Item, 0, len(b.items)),
+	}
+
+	var progress float64
+	finishedAt := b.createdAt
+	for _, item := range b.items {
+		snapshot := *item
+		final := snapshot.State == ItemStateFailed
+		if snapshot.UploadID != "" {
+			if upload, exists := m.lookup(snapshot.UploadID); exists {
+				uploadInfo := upload.Info()
+				snapshot.State = itemStateFor(uploadInfo.Status)
+				snapshot.Error = uploadInfo.Error
+				progress += uploadInfo.Progress
+				final = uploadInfo.Status.IsFinal()
+				if uploadInfo.EndTime != nil && uploadInfo.EndTime.After(finishedAt) {
+					finishedAt = *uploadInfo.EndTime
+				}
+			}
+		}
+		if !final {
+			info.Status = StatusRunning
+		}
+		info.Counts[snapshot.State]++
+		info.Items = append(info.Items, snapshot)
+	}
+
+	if len(b.items) > 0 {
+		info.Progress = progress / float64(len(b.items))
+	}
+	if info.Status == StatusFinished {
+		info.FinishedAt = &finishedAt
+	}
+	return info
+}
+
+// randomHex returns n random bytes as hex
+func randomHex(n int) string {
+	bytes := make([]byte, n)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
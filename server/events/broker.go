@@ -0,0 +1,142 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Config holds the broker configuration
+type Config struct {
+	BufferSize  int // Events buffered per subscriber before it is dropped
+	HistorySize int // Recent events kept for subscribers resuming with a last event ID
+}
+
+// Filter selects the events a subscriber receives; empty fields match all
+type Filter struct {
+	UploadID string
+	ClientID string
+}
+
+// matches reports whether the event passes the filter
+func (f Filter) matches(e Event) bool {
+	if f.UploadID != "" && f.UploadID != e.UploadID {
+		return false
+	}
+	if f.ClientID != "" && f.ClientID != e.ClientID {
+		return false
+	}
+	return true
+}
+
+// Broker fans upload events out to subscribers
+type Broker struct {
+	config      Config
+	mu          sync.Mutex
+	nextID      uint64
+	history     []Event
+	subscribers map[*Subscription]struct{}
+}
+
+// Subscription receives the events matching its filter. Its channel is
+// closed when the subscription is closed or falls too far behind; a
+// subscriber that was dropped can resubscribe from the last event it saw.
+type Subscription struct {
+	broker *Broker
+	filter Filter
+	events chan Event
+	once   sync.Once
+}
+
+// NewBroker creates a new event broker
+func NewBroker(cfg Config) *Broker {
+	if cfg.BufferSize == 0 {
+		cfg.BufferSize = 64
+	}
+	if cfg.HistorySize == 0 {
+		cfg.HistorySize = 1024
+	}
+
+	return &Broker{
+		config:      cfg,
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish assigns the event an ID and delivers it to every matching
+// subscriber without blocking
+func (b *Broker) Publish(e Event) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	e.ID = b.nextID
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.history = append(b.history, e)
+	if len(b.history) > b.config.HistorySize {
+		b.history = b.history[len(b.history)-b.config.HistorySize:]
+	}
+
+	for sub := range b.subscribers {
+		if !sub.filter.matches(e) {
+			continue
+		}
+		select {
+		case sub.events <- e:
+		default:
+			// Never let a slow reader hold up the clients' message loops
+			b.drop(sub)
+		}
+	}
+
+	return e
+}
+
+// Subscribe registers a subscriber. Events published after lastEventID that
+// are still in the history are replayed first; pass 0 for live events only.
+func (b *Broker) Subscribe(filter Filter, lastEventID uint64) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []Event
+	if lastEventID > 0 {
+		for _, e := range b.history {
+			if e.ID > lastEventID && filter.matches(e) {
+				replay = append(replay, e)
+			}
+		}
+	}
+
+	sub := &Subscription{
+		broker: b,
+		filter: filter,
+		events: make(chan Event, b.config.BufferSize+len(replay)),
+	}
+	for _, e := range replay {
+		sub.events <- e
+	}
+	b.subscribers[sub] = struct{}{}
+
+	return sub
+}
+
+// drop removes a subscriber and closes its channel; the caller must hold
+// the lock
+func (b *Broker) drop(sub *Subscription) {
+	delete(b.subscribers, sub)
+	sub.once.Do(func() { close(sub.events) })
+}
+
+// Events returns the channel delivering the subscription's events
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close unsubscribes; it is safe to call more than once
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.drop(s)
+}
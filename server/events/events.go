@@ -0,0 +1,60 @@
+package events
+
+import (
+	"time"
+
+	"github.com/iriyanto1027/file-download-system/server/models"
+)
+
+// EventType identifies what an event reports
+type EventType string
+
+const (
+	EventTypeState    EventType = "state"    // The upload changed state
+	EventTypePart     EventType = "part"     // The client finished uploading a part
+	EventTypeProgress EventType = "progress" // The client reported its progress
+)
+
+// Event is a change to an upload, published as it happens
+type Event struct {
+	ID       uint64          `json:"id"`
+	Type     EventType       `json:"type"`
+	UploadID string          `json:"upload_id"`
+	ClientID string          `json:"client_id"`
+	Time     time.Time       `json:"time"`
+	State    *StateChange    `json:"state,omitempty"`
+	Part     *PartCompleted  `json:"part,omitempty"`
+	Progress *ProgressUpdate `json:"progress,omitempty"`
+}
+
+// StateChange is the payload of a state event
+type StateChange struct {
+	From   models.UploadState `json:"from,omitempty"`
+	To     models.UploadState `json:"to"`
+	Reason string             `json:"reason,omitempty"`
+}
+
+// PartCompleted is the payload of a part event
+type PartCompleted struct {
+	PartNumber     int `json:"part_number"`
+	CompletedParts int `json:"completed_parts"`
+	TotalParts     int `json:"total_parts"`
+}
+
+// ProgressUpdate is the payload of a progress event
+type ProgressUpdate struct {
+	BytesUploaded  int64   `json:"bytes_uploaded"`
+	FileSize       int64   `json:"file_size"`
+	CompletedParts int     `json:"completed_parts"`
+	TotalParts     int     `json:"total_parts"`
+	Progress       float64 `json:"progress"`
+	ThroughputBps  float64 `json:"throughput_bps"`        // Average bytes per second since the client started
+	ETASeconds     float64 `json:"eta_seconds,omitempty"` // Estimated time left, 0 if unknown
+	Retries        int     `json:"retries,omitempty"`
+	LastError      string  `json:"last_error,omitempty"`
+}
+
+// IsFinal reports whether the event moved the upload to a final state
+func (e Event) IsFinal() bool {
+	return e.Type == EventTypeState && e.State != nil && e.State.To.IsFinal()
+}
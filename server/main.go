@@ -2,17 +2,28 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/iriyanto1027/file-download-system/server/api"
+	"github.com/iriyanto1027/file-download-system/server/audit"
+	"github.com/iriyanto1027/file-download-system/server/revocation"
 	"github.com/iriyanto1027/file-download-system/server/s3"
+	"github.com/iriyanto1027/file-download-system/server/scheduler"
+	"github.com/iriyanto1027/file-download-system/server/store"
+	"github.com/iriyanto1027/file-download-system/server/webhooks"
 	"github.com/iriyanto1027/file-download-system/server/websocket"
 	"github.com/iriyanto1027/file-download-system/shared/auth"
+	sharedModels "github.com/iriyanto1027/file-download-system/shared/models"
 	"github.com/joho/godotenv"
 )
 
@@ -37,7 +48,9 @@ func main() {
 	}
 	fmt.Println("================================")
 
-	ctx := context.Background()
+	// Cancelled on SIGINT or SIGTERM, stopping background work and batches
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// Initialize S3 client
 	fmt.Println("� Initializing S3 client...")
@@ -54,35 +67,174 @@ func main() {
 	}
 	fmt.Println("✅ S3 client initialized")
 
-	// Initialize token manager (optional)
-	var tokenManager *auth.TokenManager
-	if cfg.JWTSecret != "" {
-		tokenManager = auth.NewTokenManager(cfg.JWTSecret, "file-download-system")
-		fmt.Println("✅ JWT token manager initialized")
+	// Initialize token manager (optional unless clients must authenticate)
+	tokenManager, err := newTokenManager(cfg)
+	if err != nil {
+		log.Fatalf("❌ Failed to initialize JWT token manager: %v", err)
+	}
+	switch cfg.AuthMode {
+	case websocket.AuthModeEnforce:
+		if tokenManager == nil {
+			log.Fatalf("❌ AUTH_MODE=enforce requires JWT_SECRET, JWT_SIGNING_KEY or JWT_VERIFY_KEYS")
+		}
+		fmt.Println("🔒 Client authentication enforced")
+	case websocket.AuthModeMTLS:
+		if cfg.TLSClientCA == "" {
+			log.Fatalf("❌ AUTH_MODE=mtls requires TLS_CERT, TLS_KEY and TLS_CLIENT_CA")
+		}
+		fmt.Println("🔒 Client certificates enforced")
+	default:
+		fmt.Println("⚠️  AUTH_MODE=optional, clients may connect without a token")
+	}
+
+	// Terminate TLS, verifying client certificates if a client CA is set
+	var tlsConfig *tls.Config
+	if cfg.TLSCert != "" {
+		tlsConfig, err = auth.ServerTLSConfig(cfg.TLSCert, cfg.TLSKey, cfg.TLSClientCA)
+		if err != nil {
+			log.Fatalf("❌ Failed to load TLS configuration: %v", err)
+		}
+		if cfg.TLSClientCA != "" {
+			fmt.Printf("✅ TLS enabled, client certificates verified against %s\n", cfg.TLSClientCA)
+		} else {
+			fmt.Println("✅ TLS enabled")
+		}
+	} else if cfg.TLSClientCA != "" {
+		log.Fatalf("❌ TLS_CLIENT_CA requires TLS_CERT and TLS_KEY")
+	}
+
+	// Open the upload store
+	var uploadStore store.UploadStore
+	var webhookStore store.WebhookStore
+	var scheduleStore store.ScheduleStore
+	var revocationStore store.RevocationStore
+	var auditStore store.AuditStore
+	if cfg.UploadStorePath != "" {
+		boltStore, err := store.OpenBoltStore(cfg.UploadStorePath)
+		if err != nil {
+			log.Fatalf("❌ Failed to open upload store: %v", err)
+		}
+		defer boltStore.Close()
+		uploadStore = boltStore
+		webhookStore = boltStore
+		scheduleStore = boltStore
+		revocationStore = boltStore
+		auditStore = boltStore
+		fmt.Printf("✅ Upload store opened at %s\n", cfg.UploadStorePath)
+	} else {
+		memoryStore := store.NewMemoryStore()
+		uploadStore = memoryStore
+		webhookStore = memoryStore
+		scheduleStore = memoryStore
+		revocationStore = memoryStore
+		auditStore = memoryStore
+		fmt.Println("⚠️  UPLOAD_STORE_PATH is empty, upload history, webhooks, schedules, token revocations and the audit log will not survive restarts")
+	}
+
+	// Reject revoked tokens when validating
+	var revocations *revocation.List
+	if tokenManager != nil {
+		revocations, err = revocation.New(revocationStore)
+		if err != nil {
+			log.Fatalf("❌ Failed to load token revocations: %v", err)
+		}
+		tokenManager.SetRevocationChecker(revocations)
 	}
 
 	// Initialize WebSocket manager
 	fmt.Println("🔧 Initializing WebSocket manager...")
 	wsManager := websocket.NewManager(websocket.Config{
-		PingInterval:  30 * time.Second,
-		ClientTimeout: 300 * time.Second, // 5 minutes for long-running uploads
-		ReadLimit:     1024 * 1024, // 1MB
+		PingInterval:   30 * time.Second,
+		ClientTimeout:  300 * time.Second, // 5 minutes for long-running uploads
+		ReadLimit:      1024 * 1024,       // 1MB
+		SendBufferSize: 64,                // Messages queued per client before sends fail
+		WriteTimeout:   10 * time.Second,
+		Store:          uploadStore,
 	}, nil) // Handler will be set later
 	fmt.Println("✅ WebSocket manager initialized")
 
+	// Record client sessions, operator actions and completed uploads
+	auditLog := audit.New(auditStore, wsManager.Events(), wsManager.GetUpload)
+	auditLog.Start(ctx)
+	wsManager.SetAuditLog(auditLog)
+
+	// Start delivering upload lifecycle events to webhooks
+	fmt.Println("🔧 Initializing webhook dispatcher...")
+	webhookDispatcher, err := webhooks.NewDispatcher(webhooks.Config{
+		MaxAttempts: cfg.WebhookMaxAttempts,
+		Timeout:     cfg.WebhookTimeout,
+	}, webhookStore, wsManager.Events(), wsManager.GetUpload)
+	if err != nil {
+		log.Fatalf("❌ Failed to initialize webhook dispatcher: %v", err)
+	}
+	webhookDispatcher.Start(ctx)
+	fmt.Printf("✅ Webhook dispatcher started (%d webhooks)\n", len(webhookDispatcher.List()))
+
+	// Check operator credentials on the HTTP API; leaving it open takes an
+	// explicit API_AUTH_MODE=optional
+	if cfg.APIAuthMode == api.AuthModeEnforce && len(cfg.APIKeys) == 0 && tokenManager == nil {
+		log.Fatalf("❌ API_AUTH_MODE=enforce (the default) requires API_KEYS, JWT_SECRET, JWT_SIGNING_KEY or JWT_VERIFY_KEYS; set API_AUTH_MODE=optional only for local development")
+	}
+	var apiAuth *api.Authenticator
+	if len(cfg.APIKeys) > 0 || cfg.APIAuthMode == api.AuthModeEnforce {
+		apiAuth, err = api.NewAuthenticator(cfg.APIKeys, tokenManager, cfg.APIAuthMode == api.AuthModeEnforce)
+		if err != nil {
+			log.Fatalf("❌ Failed to initialize API authentication: %v", err)
+		}
+	}
+	if cfg.APIAuthMode == api.AuthModeEnforce {
+		fmt.Printf("🔒 API authentication enforced (%d API keys)\n", len(cfg.APIKeys))
+		if len(cfg.APIKeys) == 0 {
+			log.Printf("⚠️  API_KEYS is empty, only operator tokens issued elsewhere are accepted and none can be issued here")
+		}
+	} else {
+		fmt.Println("⚠️  API_AUTH_MODE=optional, requests without credentials have full API access; use it only for local development")
+	}
+
 	// Initialize API handler (also acts as message handler for WebSocket)
 	fmt.Println("🔧 Initializing API handler...")
-	apiHandler := api.NewHandler(wsManager, s3Client, api.Config{
-		ChunkSize:  cfg.ChunkSize,
-		BaseS3Path: "uploads",
+	apiHandler := api.NewHandler(ctx, wsManager, s3Client, api.Config{
+		ChunkSize:    cfg.ChunkSize,
+		BaseS3Path:   "uploads",
+		URLBatchSize: cfg.URLBatchSize,
+		CallTimeout:  cfg.CallTimeout,
+		Webhooks:     webhookDispatcher,
+		BatchLimit:   cfg.BatchConcurrency,
+		QueueTTL:     cfg.QueueTTL,
+		MaxQueueTTL:  cfg.MaxQueueTTL,
+		Tokens:       tokenManager,
+		TokenTTL:     cfg.TokenTTL,
+		Revocations:  revocations,
+		Auth:         apiAuth,
+		Audit:        auditLog,
+
+		ChecksumAlgorithm: cfg.ChecksumAlgorithm,
 	})
 	fmt.Println("✅ API handler initialized")
 
 	// Set the message handler for WebSocket manager
 	wsManager.SetMessageHandler(apiHandler)
 
+	// Restore uploads from before the last restart and reconcile them with S3
+	fmt.Println("🔧 Reconciling persisted uploads...")
+	if err := apiHandler.ReconcileUploads(ctx); err != nil {
+		log.Printf("⚠️ Failed to reconcile uploads: %v", err)
+	}
+	go apiHandler.ExpireQueuedDownloads(ctx)
+
+	// Start firing scheduled downloads
+	fmt.Println("🔧 Initializing scheduler...")
+	downloadScheduler, err := scheduler.New(scheduleStore, apiHandler)
+	if err != nil {
+		log.Fatalf("❌ Failed to initialize scheduler: %v", err)
+	}
+	apiHandler.SetScheduler(downloadScheduler)
+	downloadScheduler.Start()
+	defer downloadScheduler.Stop()
+	fmt.Printf("✅ Scheduler started (%d schedules)\n", len(downloadScheduler.List()))
+
 	// Initialize WebSocket HTTP handler
-	wsHandler := websocket.NewHandler(wsManager, tokenManager)
+	wsHandler := websocket.NewHandler(wsManager, tokenManager, cfg.AuthMode)
 
 	// Setup routes
 	fmt.Println("🔧 Setting up routes...")
@@ -90,11 +242,27 @@ func main() {
 	// WebSocket endpoint
 	http.HandleFunc("/ws/connect", wsHandler.HandleConnect)
 
-	// API endpoints
-	http.HandleFunc("/trigger-download/", apiHandler.TriggerDownload)
-	http.HandleFunc("/status/", apiHandler.GetStatus)
-	http.HandleFunc("/uploads/", apiHandler.GetUploadStatus)
-	http.HandleFunc("/clients", apiHandler.ListClients)
+	// API endpoints; GET needs the first role, other methods the second
+	viewer, operator, admin := auth.RoleViewer, auth.RoleOperator, auth.RoleAdmin
+	http.HandleFunc("/trigger-download", apiHandler.Require(operator, operator, apiHandler.BulkTriggerDownload))
+	http.HandleFunc("/trigger-download/", apiHandler.Require(operator, operator, apiHandler.TriggerDownload))
+	http.HandleFunc("/batches", apiHandler.Require(viewer, operator, apiHandler.HandleBatches))
+	http.HandleFunc("/batches/", apiHandler.Require(viewer, operator, apiHandler.HandleBatches))
+	http.HandleFunc("/status/", apiHandler.Require(viewer, viewer, apiHandler.GetStatus))
+	http.HandleFunc("/uploads", apiHandler.Require(viewer, viewer, apiHandler.ListUploads))
+	http.HandleFunc("/uploads/", apiHandler.Require(viewer, operator, apiHandler.HandleUpload))
+	http.HandleFunc("/clients", apiHandler.Require(viewer, viewer, apiHandler.ListClients))
+	http.HandleFunc("/clients/", apiHandler.Require(viewer, viewer, apiHandler.HandleClient))
+	http.HandleFunc("/events", apiHandler.Require(viewer, viewer, apiHandler.StreamEvents))
+	http.HandleFunc("/webhooks", apiHandler.Require(admin, admin, apiHandler.HandleWebhooks))
+	http.HandleFunc("/webhooks/", apiHandler.Require(admin, admin, apiHandler.HandleWebhook))
+	http.HandleFunc("/schedules", apiHandler.Require(viewer, operator, apiHandler.HandleSchedules))
+	http.HandleFunc("/schedules/", apiHandler.Require(viewer, operator, apiHandler.HandleSchedules))
+	http.HandleFunc("/admin/clients/", apiHandler.Require(admin, admin, apiHandler.HandleAdminClient))
+	http.HandleFunc("/admin/operators/", apiHandler.Require(admin, admin, apiHandler.HandleAdminOperator))
+	http.HandleFunc("/admin/revocations", apiHandler.Require(admin, admin, apiHandler.HandleRevocations))
+	http.HandleFunc("/audit", apiHandler.Require(admin, admin, apiHandler.HandleAudit))
+	http.HandleFunc("/.well-known/jwks.json", apiHandler.JWKS)
 	http.HandleFunc("/health", apiHandler.HealthCheck)
 
 	// Root endpoint
@@ -112,16 +280,51 @@ func main() {
 	fmt.Println("\n📍 Available Endpoints:")
 	fmt.Println("   WebSocket:  /ws/connect")
 	fmt.Println("   API:        POST /trigger-download/{client_id}")
+	fmt.Println("   API:        POST /trigger-download (bulk, by selector)")
+	fmt.Println("   API:        GET  /batches[/{batch_id}]")
 	fmt.Println("   API:        GET  /status/{client_id}")
+	fmt.Println("   API:        GET  /uploads?client_id=&state=&since=&until=&limit=&cursor=")
 	fmt.Println("   API:        GET  /uploads/{upload_id}")
+	fmt.Println("   API:        GET  /uploads/{upload_id}/events (SSE)")
+	fmt.Println("   API:        DELETE /uploads/{upload_id}")
 	fmt.Println("   API:        GET  /clients")
+	fmt.Println("   API:        GET  /clients/{client_id}/health")
+	fmt.Println("   API:        GET  /clients/{client_id}/stat?path=")
+	fmt.Println("   API:        GET  /events?client_id= (SSE)")
+	fmt.Println("   API:        GET|POST /webhooks")
+	fmt.Println("   API:        GET|DELETE /webhooks/{id}")
+	fmt.Println("   API:        GET  /webhooks/{id}/deliveries")
+	fmt.Println("   API:        GET|POST /schedules")
+	fmt.Println("   API:        GET|DELETE /schedules/{id}")
+	fmt.Println("   Admin:      POST /admin/clients/{client_id}/token")
+	fmt.Println("   Admin:      POST /admin/operators/{name}/token")
+	fmt.Println("   Admin:      GET|POST /admin/revocations")
+	fmt.Println("   Admin:      GET  /audit?action=&client_id=&actor=&since=&until=&limit=&cursor=&format=")
+	fmt.Println("   API:        GET  /.well-known/jwks.json")
 	fmt.Println("   API:        GET  /health")
 
 	addr := cfg.ServerHost + ":" + cfg.ServerPort
-	fmt.Printf("\n✅ Server ready at http://%s\n", addr)
-	fmt.Println("Press Ctrl+C to stop")
+	server := &http.Server{Addr: addr, TLSConfig: tlsConfig}
+	go func() {
+		<-ctx.Done()
+		fmt.Println("\n🛑 Shutting down...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
 
-	log.Fatal(http.ListenAndServe(addr, nil))
+	if tlsConfig != nil {
+		fmt.Printf("\n✅ Server ready at https://%s\n", addr)
+		fmt.Println("Press Ctrl+C to stop")
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		fmt.Printf("\n✅ Server ready at http://%s\n", addr)
+		fmt.Println("Press Ctrl+C to stop")
+		err = server.ListenAndServe()
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
 }
 
 // Config holds the server configuration
@@ -135,7 +338,25 @@ type Config struct {
 	S3Bucket           string
 	PresignedURLExpiry time.Duration
 	ChunkSize          int64
+	URLBatchSize       int
+	CallTimeout        time.Duration
+	UploadStorePath    string
+	WebhookMaxAttempts int
+	WebhookTimeout     time.Duration
+	BatchConcurrency   int
+	QueueTTL           time.Duration
+	MaxQueueTTL        time.Duration
 	JWTSecret          string
+	JWTSigningKey      string
+	JWTVerifyKeys      []string
+	TokenTTL           time.Duration
+	AuthMode           websocket.AuthMode
+	TLSCert            string
+	TLSKey             string
+	TLSClientCA        string
+	APIAuthMode        api.AuthMode
+	APIKeys            []api.APIKey
+	ChecksumAlgorithm  sharedModels.ChecksumAlgorithm
 }
 
 // loadConfig loads configuration from environment variables
@@ -149,6 +370,13 @@ func loadConfig() Config {
 		AWSSecretAccessKey: getEnv("AWS_SECRET_ACCESS_KEY", ""),
 		S3Bucket:           getEnv("S3_BUCKET_NAME", "file-download-system-uploads"),
 		JWTSecret:          getEnv("JWT_SECRET", ""),
+		JWTSigningKey:      getEnv("JWT_SIGNING_KEY", ""),
+		AuthMode:           websocket.AuthMode(getEnv("AUTH_MODE", string(websocket.AuthModeOptional))),
+		TLSCert:            getEnv("TLS_CERT", ""),
+		TLSKey:             getEnv("TLS_KEY", ""),
+		TLSClientCA:        getEnv("TLS_CLIENT_CA", ""),
+		APIAuthMode:        api.AuthMode(getEnv("API_AUTH_MODE", string(api.AuthModeEnforce))),
+		UploadStorePath:    getEnv("UPLOAD_STORE_PATH", "uploads.db"),
 	}
 
 	// Parse presigned URL expiry
@@ -169,9 +397,165 @@ func loadConfig() Config {
 	}
 	cfg.ChunkSize = chunkSize
 
+	// Parse presigned URL batch size
+	batchSizeStr := getEnv("S3_PRESIGN_BATCH_SIZE", "10")
+	batchSize, err := strconv.Atoi(batchSizeStr)
+	if err != nil || batchSize <= 0 {
+		log.Printf("Warning: Invalid S3_PRESIGN_BATCH_SIZE '%s', using default 10", batchSizeStr)
+		batchSize = 10
+	}
+	cfg.URLBatchSize = batchSize
+
+	// Parse how long blocking client endpoints wait for an answer
+	callTimeoutStr := getEnv("CLIENT_CALL_TIMEOUT", "30s")
+	callTimeout, err := time.ParseDuration(callTimeoutStr)
+	if err != nil || callTimeout <= 0 {
+		log.Printf("Warning: Invalid CLIENT_CALL_TIMEOUT '%s', using default 30s", callTimeoutStr)
+		callTimeout = 30 * time.Second
+	}
+	cfg.CallTimeout = callTimeout
+
+	// Parse webhook delivery settings
+	maxAttemptsStr := getEnv("WEBHOOK_MAX_ATTEMPTS", "5")
+	maxAttempts, err := strconv.Atoi(maxAttemptsStr)
+	if err != nil || maxAttempts <= 0 {
+		log.Printf("Warning: Invalid WEBHOOK_MAX_ATTEMPTS '%s', using default 5", maxAttemptsStr)
+		maxAttempts = 5
+	}
+	cfg.WebhookMaxAttempts = maxAttempts
+
+	webhookTimeoutStr := getEnv("WEBHOOK_TIMEOUT", "10s")
+	webhookTimeout, err := time.ParseDuration(webhookTimeoutStr)
+	if err != nil || webhookTimeout <= 0 {
+		log.Printf("Warning: Invalid WEBHOOK_TIMEOUT '%s', using default 10s", webhookTimeoutStr)
+		webhookTimeout = 10 * time.Second
+	}
+	cfg.WebhookTimeout = webhookTimeout
+
+	// Parse how many bulk-triggered uploads may run at once
+	batchConcurrencyStr := getEnv("BATCH_CONCURRENCY", "10")
+	batchConcurrency, err := strconv.Atoi(batchConcurrencyStr)
+	if err != nil || batchConcurrency <= 0 {
+		log.Printf("Warning: Invalid BATCH_CONCURRENCY '%s', using default 10", batchConcurrencyStr)
+		batchConcurrency = 10
+	}
+	cfg.BatchConcurrency = batchConcurrency
+
+	// Parse how long downloads queued for offline clients wait
+	queueTTLStr := getEnv("QUEUE_TTL", "24h")
+	queueTTL, err := time.ParseDuration(queueTTLStr)
+	if err != nil || queueTTL <= 0 {
+		log.Printf("Warning: Invalid QUEUE_TTL '%s', using default 24h", queueTTLStr)
+		queueTTL = 24 * time.Hour
+	}
+	cfg.QueueTTL = queueTTL
+
+	maxQueueTTLStr := getEnv("QUEUE_MAX_TTL", "168h")
+	maxQueueTTL, err := time.ParseDuration(maxQueueTTLStr)
+	if err != nil || maxQueueTTL <= 0 {
+		log.Printf("Warning: Invalid QUEUE_MAX_TTL '%s', using default 168h", maxQueueTTLStr)
+		maxQueueTTL = 7 * 24 * time.Hour
+	}
+	cfg.MaxQueueTTL = maxQueueTTL
+
+	// A typo must not silently leave clients unauthenticated
+	if !cfg.AuthMode.IsValid() {
+		log.Fatalf("❌ Invalid AUTH_MODE '%s', expected optional, enforce or mtls", cfg.AuthMode)
+	}
+
+	if !cfg.APIAuthMode.IsValid() {
+		log.Fatalf("❌ Invalid API_AUTH_MODE '%s', expected optional or enforce", cfg.APIAuthMode)
+	}
+
+	// Parse operator API keys as name:role:key
+	for i, entry := range strings.Split(getEnv("API_KEYS", ""), ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 {
+			// Without its colons the entry may be just the key, so don't log it
+			log.Fatalf("❌ Invalid API_KEYS entry %d, expected name:role:key", i+1)
+		}
+		cfg.APIKeys = append(cfg.APIKeys, api.APIKey{Name: parts[0], Role: auth.Role(parts[1]), Key: parts[2]})
+	}
+
+	// Parse the lifetime of issued and refreshed client tokens
+	tokenTTLStr := getEnv("JWT_EXPIRY", "24h")
+	tokenTTL, err := time.ParseDuration(tokenTTLStr)
+	if err != nil || tokenTTL <= 0 {
+		log.Printf("Warning: Invalid JWT_EXPIRY '%s', using default 24h", tokenTTLStr)
+		tokenTTL = 24 * time.Hour
+	}
+	cfg.TokenTTL = tokenTTL
+
+	// Parse the part checksum S3 verifies uploads with; off unless set, as
+	// clients then read the whole file an extra time before uploading
+	checksumAlgorithm := strings.ToUpper(getEnv("UPLOAD_CHECKSUM_ALGORITHM", "none"))
+	if checksumAlgorithm != "NONE" {
+		cfg.ChecksumAlgorithm = sharedModels.ChecksumAlgorithm(checksumAlgorithm)
+		if !cfg.ChecksumAlgorithm.IsValid() {
+			log.Fatalf("❌ Invalid UPLOAD_CHECKSUM_ALGORITHM '%s', expected SHA256, CRC32C or none", checksumAlgorithm)
+		}
+	}
+
+	// Parse public keys that still verify tokens, e.g. a retired signing key
+	for _, path := range strings.Split(getEnv("JWT_VERIFY_KEYS", ""), ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			cfg.JWTVerifyKeys = append(cfg.JWTVerifyKeys, path)
+		}
+	}
+
 	return cfg
 }
 
+// newTokenManager builds the token manager from the configured keys; it
+// returns nil if no secret or key is configured
+func newTokenManager(cfg Config) (*auth.TokenManager, error) {
+	const issuer = "file-download-system"
+
+	if cfg.JWTSigningKey == "" && len(cfg.JWTVerifyKeys) == 0 {
+		if cfg.JWTSecret == "" {
+			return nil, nil
+		}
+		fmt.Println("✅ JWT token manager initialized (HS256)")
+		return auth.NewTokenManager(cfg.JWTSecret, issuer), nil
+	}
+
+	var signingKey *auth.Key
+	if cfg.JWTSigningKey != "" {
+		key, err := auth.LoadKeyFile(cfg.JWTSigningKey)
+		if err != nil {
+			return nil, err
+		}
+		signingKey = key
+	}
+
+	verificationKeys := make([]*auth.Key, 0, len(cfg.JWTVerifyKeys))
+	for _, path := range cfg.JWTVerifyKeys {
+		key, err := auth.LoadKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		verificationKeys = append(verificationKeys, key)
+	}
+
+	tokenManager, err := auth.NewKeyTokenManager(issuer, signingKey, verificationKeys, cfg.JWTSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	if signingKey != nil {
+		fmt.Printf("✅ JWT token manager initialized (%s, kid %s)\n", signingKey.Algorithm, signingKey.ID)
+	} else {
+		fmt.Println("✅ JWT token manager initialized (verify only, tokens cannot be issued)")
+	}
+	if cfg.JWTSecret != "" {
+		log.Printf("⚠️  JWT_SECRET is still accepted for HS256 tokens; remove it once clients have refreshed onto the new key")
+	}
+	return tokenManager, nil
+}
+
 // getEnv gets an environment variable with a default value
 func getEnv(key, defaultValue string) string {
 	value := os.Getenv(key)
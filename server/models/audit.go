@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// AuditAction is what an audit entry records
+type AuditAction string
+
+const (
+	AuditActionAPICall            AuditAction = "api.call"            // An operator changed something or was refused
+	AuditActionScheduleFired      AuditAction = "schedule.fired"      // A schedule started a batch
+	AuditActionClientConnected    AuditAction = "client.connected"    // A client opened a WebSocket session
+	AuditActionClientDisconnected AuditAction = "client.disconnected" // The session ended
+	AuditActionUploadCompleted    AuditAction = "upload.completed"    // The object is in S3
+)
+
+// IsValid reports whether the action is one the audit log records
+func (a AuditAction) IsValid() bool {
+	switch a {
+	case AuditActionAPICall, AuditActionScheduleFired, AuditActionClientConnected,
+		AuditActionClientDisconnected, AuditActionUploadCompleted:
+		return true
+	}
+	return false
+}
+
+// AuditResult is the outcome of an audited action
+type AuditResult string
+
+const (
+	AuditResultSuccess AuditResult = "success"
+	AuditResultDenied  AuditResult = "denied" // Missing credentials or role
+	AuditResultFailed  AuditResult = "failed"
+)
+
+// AuditEntry is one record in the append-only audit log; which fields are
+// set depends on the action
+type AuditEntry struct {
+	ID     uint64      `json:"id"` // Increases with every entry
+	Time   time.Time   `json:"time"`
+	Action AuditAction `json:"action"`
+	Result AuditResult `json:"result"`
+
+	// Who: the operator behind an API call, or the client's credentials
+	Actor        string `json:"actor,omitempty"`         // Operator name, "anonymous" or "scheduler"
+	Role         string `json:"role,omitempty"`          // Operator role
+	RemoteAddr   string `json:"remote_addr,omitempty"`   // Address the request or session came from
+	TokenSubject string `json:"token_subject,omitempty"` // Subject of the client's token
+	TokenID      string `json:"token_id,omitempty"`
+	Certificate  string `json:"certificate,omitempty"` // Subject of the client's verified certificate
+
+	// What
+	Method     string            `json:"method,omitempty"`
+	Path       string            `json:"path,omitempty"`
+	StatusCode int               `json:"status_code,omitempty"`
+	ClientID   string            `json:"client_id,omitempty"`
+	FilePath   string            `json:"file_path,omitempty"`
+	UploadID   string            `json:"upload_id,omitempty"`
+	S3Key      string            `json:"s3_key,omitempty"`
+	Checksum   string            `json:"checksum,omitempty"` // e.g. sha256:<hex>
+	FileSize   int64             `json:"file_size,omitempty"`
+	Details    map[string]string `json:"details,omitempty"` // e.g. batch_id, schedule_id, duration
+}
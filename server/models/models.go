@@ -2,9 +2,12 @@ package models
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/iriyanto1027/file-download-system/shared/auth"
+	sharedModels "github.com/iriyanto1027/file-download-system/shared/models"
 )
 
 // ClientConnection represents a connected client
@@ -15,20 +18,50 @@ type ClientConnection struct {
 	LastHeartbeat time.Time
 	LastActivity  time.Time
 	Metadata      map[string]string
+	Capabilities  sharedModels.ClientCapabilities // Legacy capabilities until the client says hello
+	HelloReceived bool
+	token         *auth.Claims // Claims of the token the client authenticated with; nil without one
 	mu            sync.RWMutex
+
+	// Outbound queue drained by a single writer goroutine; gorilla/websocket
+	// allows only one concurrent writer per connection
+	send         chan outboundMessage
+	closed       chan struct{}
+	closeOnce    sync.Once
+	writeTimeout time.Duration
+	compress     atomic.Bool
+}
+
+// ConnectionOptions configures a client connection's outbound queue
+type ConnectionOptions struct {
+	SendBufferSize int           // Messages queued before Send fails with ErrSendQueueFull
+	WriteTimeout   time.Duration // Deadline for writing a single message
 }
 
-// NewClientConnection creates a new client connection
-func NewClientConnection(clientID string, conn *websocket.Conn) *ClientConnection {
+// NewClientConnection creates a new client connection and starts its writer
+func NewClientConnection(clientID string, conn *websocket.Conn, opts ConnectionOptions) *ClientConnection {
+	if opts.SendBufferSize <= 0 {
+		opts.SendBufferSize = 64
+	}
+	if opts.WriteTimeout <= 0 {
+		opts.WriteTimeout = 10 * time.Second
+	}
+
 	now := time.Now()
-	return &ClientConnection{
+	c := &ClientConnection{
 		ClientID:      clientID,
 		Connection:    conn,
 		ConnectedAt:   now,
 		LastHeartbeat: now,
 		LastActivity:  now,
 		Metadata:      make(map[string]string),
+		Capabilities:  sharedModels.LegacyCapabilities(),
+		send:          make(chan outboundMessage, opts.SendBufferSize),
+		closed:        make(chan struct{}),
+		writeTimeout:  opts.WriteTimeout,
 	}
+	go c.writeLoop()
+	return c
 }
 
 // UpdateHeartbeat updates the last heartbeat time
@@ -60,6 +93,44 @@ func (c *ClientConnection) SetMetadata(key, value string) {
 	c.Metadata[key] = value
 }
 
+// SetCapabilities records the capabilities announced in the client's hello
+func (c *ClientConnection) SetCapabilities(capabilities sharedModels.ClientCapabilities) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Capabilities = capabilities
+	c.HelloReceived = true
+}
+
+// GetCapabilities safely retrieves the client's capabilities
+func (c *ClientConnection) GetCapabilities() (sharedModels.ClientCapabilities, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Capabilities, c.HelloReceived
+}
+
+// Supports reports whether the client can handle the given action
+func (c *ClientConnection) Supports(action sharedModels.CommandAction) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Capabilities.Supports(action)
+}
+
+// SetToken records the claims of the client's current token, on connect
+// and after each refresh
+func (c *ClientConnection) SetToken(claims *auth.Claims) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = claims
+}
+
+// GetToken returns the claims of the client's current token, or nil if it
+// connected without one
+func (c *ClientConnection) GetToken() *auth.Claims {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.token
+}
+
 // IsAlive checks if the client is still alive based on heartbeat
 func (c *ClientConnection) IsAlive(timeout time.Duration) bool {
 	c.mu.RLock()
@@ -69,38 +140,82 @@ func (c *ClientConnection) IsAlive(timeout time.Duration) bool {
 
 // UploadStatus represents the status of an ongoing upload
 type UploadStatus struct {
-	UploadID       string // Internal upload ID for tracking
-	S3UploadID     string // S3 multipart upload ID from CreateMultipartUpload
-	ClientID       string
-	FilePath       string
-	S3Bucket       string
-	S3Key          string
-	FileSize       int64
-	ChunkSize      int64
-	TotalParts     int
-	CompletedParts int
-	BytesUploaded  int64
-	Status         UploadState
-	StartTime      time.Time
-	EndTime        *time.Time
-	Error          string
-	ETags          map[int]string // part number -> ETag
-	mu             sync.RWMutex
+	UploadID          string // Internal upload ID for tracking
+	S3UploadID        string // S3 multipart upload ID from CreateMultipartUpload
+	ClientID          string
+	FilePath          string
+	S3Bucket          string
+	S3Key             string
+	FileSize          int64
+	FileModTime       time.Time // Modification time reported by stat_file
+	FileHash          string    // Hex SHA-256 reported by stat_file, if requested
+	ChunkSize         int64
+	ChecksumAlgorithm sharedModels.ChecksumAlgorithm // Part checksum S3 verifies; empty for none
+	PartChecksums     []string                       // Base64 checksum of each part reported by stat_file
+	Checksum          string                         // Composite checksum S3 reported for the completed object
+	TotalParts        int
+	CompletedParts    int
+	BytesUploaded     int64
+	Retries           int    // Part retries reported by the client
+	LastError         string // Most recent transient failure reported by the client
+	Status            UploadState
+	StartTime         time.Time
+	EndTime           *time.Time
+	Error             string
+	ErrorCode         sharedModels.ErrorCode // Set when the client classified the failure, e.g. forbidden_path
+	ETags             map[int]string         // part number -> ETag
+	Metadata          map[string]string
+	ComputeHash       bool              // Whether stat_file asks the client for a hash
+	QueueExpiresAt    *time.Time        // When a queued upload gives up waiting for its client
+	Transitions       []StateTransition // State history, oldest first
+	onTransition      TransitionFunc
+	progressSavedAt   time.Time // When a progress report was last persisted
+	mu                sync.RWMutex
+}
+
+// TransitionFunc is called after an upload changes state. It runs with the
+// upload locked, so it must not call back into the upload.
+type TransitionFunc func(uploadID, clientID string, transition StateTransition)
+
+// StateTransition records a change of upload state
+type StateTransition struct {
+	From   UploadState `json:"from,omitempty"`
+	To     UploadState `json:"to"`
+	At     time.Time   `json:"at"`
+	Reason string      `json:"reason,omitempty"`
 }
 
 // UploadState represents the state of an upload
 type UploadState string
 
 const (
+	UploadStateQueued     UploadState = "queued" // Waiting for an offline client to connect
 	UploadStatePending    UploadState = "pending"
 	UploadStateInProgress UploadState = "in_progress"
 	UploadStateCompleted  UploadState = "completed"
 	UploadStateFailed     UploadState = "failed"
+	UploadStateCancelling UploadState = "cancelling" // Waiting for the client to confirm
 	UploadStateCancelled  UploadState = "cancelled"
 )
 
+// IsValid reports whether the state is one of the known upload states
+func (s UploadState) IsValid() bool {
+	switch s {
+	case UploadStateQueued, UploadStatePending, UploadStateInProgress, UploadStateCompleted,
+		UploadStateFailed, UploadStateCancelling, UploadStateCancelled:
+		return true
+	}
+	return false
+}
+
+// IsFinal reports whether no further transitions can happen from the state
+func (s UploadState) IsFinal() bool {
+	return s == UploadStateCompleted || s == UploadStateFailed || s == UploadStateCancelled
+}
+
 // NewUploadStatus creates a new upload status
 func NewUploadStatus(uploadID, clientID, filePath, bucket, key string, fileSize, chunkSize int64, totalParts int) *UploadStatus {
+	now := time.Now()
 	return &UploadStatus{
 		UploadID:       uploadID,
 		ClientID:       clientID,
@@ -113,9 +228,74 @@ func NewUploadStatus(uploadID, clientID, filePath, bucket, key string, fileSize,
 		CompletedParts: 0,
 		BytesUploaded:  0,
 		Status:         UploadStatePending,
-		StartTime:      time.Now(),
+		StartTime:      now,
 		ETags:          make(map[int]string),
+		Transitions:    []StateTransition{{To: UploadStatePending, At: now}},
+	}
+}
+
+// Queue makes a new upload wait for its client to connect until expiresAt;
+// call it before the upload is registered
+func (u *UploadStatus) Queue(expiresAt time.Time) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.Status = UploadStateQueued
+	u.QueueExpiresAt = &expiresAt
+	u.Transitions = []StateTransition{{To: UploadStateQueued, At: u.StartTime}}
+}
+
+// Dispatch moves a queued upload to pending once its client has connected;
+// it returns false if the upload is no longer queued
+func (u *UploadStatus) Dispatch() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.Status != UploadStateQueued {
+		return false
+	}
+	u.setState(UploadStatePending, "client connected")
+	u.QueueExpiresAt = nil
+	return true
+}
+
+// ExpireQueued fails a queued upload whose client did not connect in time;
+// it returns true if the upload expired
+func (u *UploadStatus) ExpireQueued(now time.Time) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.Status != UploadStateQueued || u.QueueExpiresAt == nil || now.Before(*u.QueueExpiresAt) {
+		return false
 	}
+	reason := "client did not connect before the queued request expired"
+	u.setState(UploadStateFailed, reason)
+	u.Error = reason
+	u.EndTime = &now
+	return true
+}
+
+// setState moves the upload to a new state and records the transition; the
+// caller must hold the lock
+func (u *UploadStatus) setState(to UploadState, reason string) {
+	if u.Status == to {
+		return
+	}
+	u.Transitions = append(u.Transitions, StateTransition{
+		From:   u.Status,
+		To:     to,
+		At:     time.Now(),
+		Reason: reason,
+	})
+	u.Status = to
+
+	if u.onTransition != nil {
+		u.onTransition(u.UploadID, u.ClientID, u.Transitions[len(u.Transitions)-1])
+	}
+}
+
+// OnTransition sets the function called on every later state change
+func (u *UploadStatus) OnTransition(fn TransitionFunc) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.onTransition = fn
 }
 
 // UpdateProgress updates the upload progress
@@ -130,8 +310,79 @@ func (u *UploadStatus) UpdateProgress(partNumber int, etag string, bytesUploaded
 	u.BytesUploaded = bytesUploaded
 
 	if u.Status == UploadStatePending {
-		u.Status = UploadStateInProgress
+		u.setState(UploadStateInProgress, "")
+	}
+}
+
+// ReconcileParts replaces the known parts with those S3 reports as uploaded
+func (u *UploadStatus) ReconcileParts(etags map[int]string, bytesUploaded int64) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.ETags = make(map[int]string, len(etags))
+	for partNumber, etag := range etags {
+		u.ETags[partNumber] = etag
+	}
+	u.CompletedParts = len(etags)
+	u.BytesUploaded = bytesUploaded
+
+	if u.Status == UploadStatePending {
+		u.setState(UploadStateInProgress, "")
+	}
+}
+
+// UpdateClientProgress records the progress reported in a client status
+// message and returns the number of parts completed before it
+func (u *UploadStatus) UpdateClientProgress(bytesUploaded int64, completedParts, retries int, lastError string) int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	previousParts := u.CompletedParts
+	u.BytesUploaded = bytesUploaded
+	u.CompletedParts = completedParts
+	u.Retries = retries
+	u.LastError = lastError
+	return previousParts
+}
+
+// ProgressSaveDue reports whether progress was last persisted more than
+// interval ago, restarting the interval if so
+func (u *UploadStatus) ProgressSaveDue(interval time.Duration) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	now := time.Now()
+	if now.Sub(u.progressSavedAt) < interval {
+		return false
 	}
+	u.progressSavedAt = now
+	return true
+}
+
+// SetFileInfo records the file details reported by the client before the
+// multipart upload is initiated
+func (u *UploadStatus) SetFileInfo(fileSize int64, modTime time.Time, hash string, totalParts int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.FileSize = fileSize
+	u.FileModTime = modTime
+	u.FileHash = hash
+	u.TotalParts = totalParts
+}
+
+// SetChecksums records the algorithm and per-part checksums S3 verifies the
+// upload's parts against
+func (u *UploadStatus) SetChecksums(algorithm sharedModels.ChecksumAlgorithm, parts []string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.ChecksumAlgorithm = algorithm
+	u.PartChecksums = parts
+}
+
+// GetChecksums safely retrieves the checksum algorithm and per-part checksums
+func (u *UploadStatus) GetChecksums() (sharedModels.ChecksumAlgorithm, []string) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return u.ChecksumAlgorithm, u.PartChecksums
 }
 
 // SetS3UploadID sets the S3 multipart upload ID
@@ -148,32 +399,85 @@ func (u *UploadStatus) GetS3UploadID() string {
 	return u.S3UploadID
 }
 
-// MarkCompleted marks the upload as completed
-func (u *UploadStatus) MarkCompleted() {
+// MarkCompleted marks the upload as completed, recording the checksum S3
+// reported for the object; it returns false if the upload had already
+// finished, e.g. was cancelled meanwhile
+func (u *UploadStatus) MarkCompleted(checksum string) bool {
 	u.mu.Lock()
 	defer u.mu.Unlock()
-	u.Status = UploadStateCompleted
+	if u.Status.IsFinal() {
+		return false
+	}
+	u.Checksum = checksum
+	u.setState(UploadStateCompleted, "")
 	now := time.Now()
 	u.EndTime = &now
+	return true
 }
 
-// MarkFailed marks the upload as failed
-func (u *UploadStatus) MarkFailed(err string) {
+// MarkFailed marks the upload as failed; it returns false if the upload had
+// already finished
+func (u *UploadStatus) MarkFailed(err string) bool {
+	return u.MarkFailedWithCode(err, "")
+}
+
+// MarkFailedWithCode marks the upload as failed for a reason the client
+// classified, such as a path its sandbox refused; it returns false if the
+// upload had already finished
+func (u *UploadStatus) MarkFailedWithCode(err string, code sharedModels.ErrorCode) bool {
 	u.mu.Lock()
 	defer u.mu.Unlock()
-	u.Status = UploadStateFailed
+	if u.Status.IsFinal() {
+		return false
+	}
 	u.Error = err
+	u.ErrorCode = code
+	u.setState(UploadStateFailed, err)
 	now := time.Now()
 	u.EndTime = &now
+	return true
+}
+
+// RequestCancel moves the upload to cancelling until the client confirms;
+// it returns false if the upload already finished
+func (u *UploadStatus) RequestCancel() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.Status.IsFinal() {
+		return false
+	}
+	u.setState(UploadStateCancelling, "cancel requested")
+	return true
+}
+
+// CancelRejected returns a cancelling upload to in progress
+func (u *UploadStatus) CancelRejected() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.Status == UploadStateCancelling {
+		u.setState(UploadStateInProgress, "cancel rejected by client")
+	}
 }
 
-// MarkCancelled marks the upload as cancelled
-func (u *UploadStatus) MarkCancelled() {
+// MarkCancelled marks the upload as cancelled; it returns false if the
+// upload had already finished
+func (u *UploadStatus) MarkCancelled() bool {
 	u.mu.Lock()
 	defer u.mu.Unlock()
-	u.Status = UploadStateCancelled
+	if u.Status.IsFinal() {
+		return false
+	}
+	u.setState(UploadStateCancelled, "")
 	now := time.Now()
 	u.EndTime = &now
+	return true
+}
+
+// GetStatus safely retrieves the upload state
+func (u *UploadStatus) GetStatus() UploadState {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return u.Status
 }
 
 // GetProgress returns the current progress percentage
@@ -201,29 +505,42 @@ func (u *UploadStatus) GetETags() map[int]string {
 
 // ClientStatus represents the overall status of a client
 type ClientStatus struct {
-	ClientID       string      `json:"client_id"`
-	Connected      bool        `json:"connected"`
-	ConnectedAt    *time.Time  `json:"connected_at,omitempty"`
-	LastHeartbeat  *time.Time  `json:"last_heartbeat,omitempty"`
-	LastActivity   *time.Time  `json:"last_activity,omitempty"`
-	CurrentUpload  *UploadInfo `json:"current_upload,omitempty"`
-	TotalUploads   int         `json:"total_uploads"`
-	SuccessUploads int         `json:"success_uploads"`
-	FailedUploads  int         `json:"failed_uploads"`
+	ClientID       string                           `json:"client_id"`
+	Connected      bool                             `json:"connected"`
+	ConnectedAt    *time.Time                       `json:"connected_at,omitempty"`
+	LastHeartbeat  *time.Time                       `json:"last_heartbeat,omitempty"`
+	LastActivity   *time.Time                       `json:"last_activity,omitempty"`
+	Capabilities   *sharedModels.ClientCapabilities `json:"capabilities,omitempty"`
+	HelloReceived  bool                             `json:"hello_received,omitempty"`
+	TokenID        string                           `json:"token_id,omitempty"`         // jti of the token the client authenticated with
+	TokenExpiresAt *time.Time                       `json:"token_expires_at,omitempty"` // When that token expires
+	CurrentUpload  *UploadInfo                      `json:"current_upload,omitempty"`
+	TotalUploads   int                              `json:"total_uploads"`
+	SuccessUploads int                              `json:"success_uploads"`
+	FailedUploads  int                              `json:"failed_uploads"`
 }
 
 // UploadInfo contains information about an upload
 type UploadInfo struct {
-	UploadID       string      `json:"upload_id"`
-	FilePath       string      `json:"file_path"`
-	S3Key          string      `json:"s3_key"`
-	FileSize       int64       `json:"file_size"`
-	Status         UploadState `json:"status"`
-	Progress       float64     `json:"progress"`
-	CompletedParts int         `json:"completed_parts"`
-	TotalParts     int         `json:"total_parts"`
-	BytesUploaded  int64       `json:"bytes_uploaded"`
-	StartTime      time.Time   `json:"start_time"`
-	EndTime        *time.Time  `json:"end_time,omitempty"`
-	Error          string      `json:"error,omitempty"`
+	UploadID          string                         `json:"upload_id"`
+	ClientID          string                         `json:"client_id,omitempty"`
+	FilePath          string                         `json:"file_path"`
+	S3Key             string                         `json:"s3_key"`
+	FileSize          int64                          `json:"file_size"`
+	FileHash          string                         `json:"file_hash,omitempty"`
+	ChecksumAlgorithm sharedModels.ChecksumAlgorithm `json:"checksum_algorithm,omitempty"`
+	Checksum          string                         `json:"checksum,omitempty"`
+	Status            UploadState                    `json:"status"`
+	QueueExpiresAt    *time.Time                     `json:"queue_expires_at,omitempty"`
+	Progress          float64                        `json:"progress"`
+	CompletedParts    int                            `json:"completed_parts"`
+	TotalParts        int                            `json:"total_parts"`
+	BytesUploaded     int64                          `json:"bytes_uploaded"`
+	Retries           int                            `json:"retries,omitempty"`
+	LastError         string                         `json:"last_error,omitempty"`
+	StartTime         time.Time                      `json:"start_time"`
+	EndTime           *time.Time                     `json:"end_time,omitempty"`
+	Error             string                         `json:"error,omitempty"`
+	ErrorCode         sharedModels.ErrorCode         `json:"error_code,omitempty"`
+	Transitions       []StateTransition              `json:"transitions,omitempty"`
 }
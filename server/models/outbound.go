@@ -0,0 +1,105 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var (
+	// ErrSendQueueFull is returned when a client is not draining its messages
+	// fast enough
+	ErrSendQueueFull = errors.New("send queue full")
+
+	// ErrConnectionClosed is returned when sending on a closed connection
+	ErrConnectionClosed = errors.New("connection closed")
+)
+
+// outboundMessage is an encoded message waiting for the writer goroutine
+type outboundMessage struct {
+	data       []byte
+	closeAfter bool // Close the connection once the message is written
+}
+
+// Send queues a message for the writer goroutine without blocking; it fails
+// with ErrSendQueueFull if the queue is full
+func (c *ClientConnection) Send(v interface{}) error {
+	return c.enqueue(v, false)
+}
+
+// SendAndClose queues a final message and closes the connection once it has
+// been written
+func (c *ClientConnection) SendAndClose(v interface{}) error {
+	return c.enqueue(v, true)
+}
+
+// EnableWriteCompression turns per-message compression on or off for
+// subsequent writes
+func (c *ClientConnection) EnableWriteCompression(enable bool) {
+	c.compress.Store(enable)
+}
+
+// Close stops the writer goroutine and closes the underlying connection
+func (c *ClientConnection) Close() {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.Connection.Close()
+	})
+}
+
+// Done is closed once the connection has been closed
+func (c *ClientConnection) Done() <-chan struct{} {
+	return c.closed
+}
+
+// enqueue encodes a message and adds it to the outbound queue
+func (c *ClientConnection) enqueue(v interface{}, closeAfter bool) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	select {
+	case <-c.closed:
+		return ErrConnectionClosed
+	default:
+	}
+
+	select {
+	case c.send <- outboundMessage{data: data, closeAfter: closeAfter}:
+		return nil
+	case <-c.closed:
+		return ErrConnectionClosed
+	default:
+		return fmt.Errorf("%w: %d messages pending for client %s", ErrSendQueueFull, cap(c.send), c.ClientID)
+	}
+}
+
+// writeLoop is the only goroutine writing to the connection
+func (c *ClientConnection) writeLoop() {
+	for {
+		select {
+		case <-c.closed:
+			return
+		case msg := <-c.send:
+			c.Connection.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+			c.Connection.EnableWriteCompression(c.compress.Load())
+			if err := c.Connection.WriteMessage(websocket.TextMessage, msg.data); err != nil {
+				// The read loop sees the closed connection and unregisters the client
+				log.Printf("Failed to write to client %s: %v", c.ClientID, err)
+				c.Close()
+				return
+			}
+			if msg.closeAfter {
+				closeMsg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "")
+				c.Connection.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(c.writeTimeout))
+				c.Close()
+				return
+			}
+		}
+	}
+}
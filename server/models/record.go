@@ -0,0 +1,155 @@
+package models
+
+import (
+	"time"
+
+	sharedModels "github.com/iriyanto1027/file-download-system/shared/models"
+)
+
+// UploadRecord is the persisted form of an UploadStatus
+type UploadRecord struct {
+	UploadID          string                         `json:"upload_id"`
+	S3UploadID        string                         `json:"s3_upload_id,omitempty"`
+	ClientID          string                         `json:"client_id"`
+	FilePath          string                         `json:"file_path"`
+	S3Bucket          string                         `json:"s3_bucket"`
+	S3Key             string                         `json:"s3_key"`
+	FileSize          int64                          `json:"file_size"`
+	FileModTime       time.Time                      `json:"file_mod_time,omitempty"`
+	FileHash          string                         `json:"file_hash,omitempty"`
+	ChunkSize         int64                          `json:"chunk_size"`
+	ChecksumAlgorithm sharedModels.ChecksumAlgorithm `json:"checksum_algorithm,omitempty"`
+	PartChecksums     []string                       `json:"part_checksums,omitempty"`
+	Checksum          string                         `json:"checksum,omitempty"`
+	TotalParts        int                            `json:"total_parts"`
+	CompletedParts    int                            `json:"completed_parts"`
+	BytesUploaded     int64                          `json:"bytes_uploaded"`
+	Retries           int                            `json:"retries,omitempty"`
+	LastError         string                         `json:"last_error,omitempty"`
+	Status            UploadState                    `json:"status"`
+	StartTime         time.Time                      `json:"start_time"`
+	EndTime           *time.Time                     `json:"end_time,omitempty"`
+	Error             string                         `json:"error,omitempty"`
+	ErrorCode         sharedModels.ErrorCode         `json:"error_code,omitempty"`
+	ETags             map[int]string                 `json:"etags,omitempty"`
+	Metadata          map[string]string              `json:"metadata,omitempty"`
+	ComputeHash       bool                           `json:"compute_hash,omitempty"`
+	QueueExpiresAt    *time.Time                     `json:"queue_expires_at,omitempty"`
+	Transitions       []StateTransition              `json:"transitions,omitempty"`
+}
+
+// Record returns a consistent snapshot of the upload for persistence
+func (u *UploadStatus) Record() *UploadRecord {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	record := &UploadRecord{
+		UploadID:          u.UploadID,
+		S3UploadID:        u.S3UploadID,
+		ClientID:          u.ClientID,
+		FilePath:          u.FilePath,
+		S3Bucket:          u.S3Bucket,
+		S3Key:             u.S3Key,
+		FileSize:          u.FileSize,
+		FileModTime:       u.FileModTime,
+		FileHash:          u.FileHash,
+		ChunkSize:         u.ChunkSize,
+		ChecksumAlgorithm: u.ChecksumAlgorithm,
+		PartChecksums:     append([]string(nil), u.PartChecksums...),
+		Checksum:          u.Checksum,
+		TotalParts:        u.TotalParts,
+		CompletedParts:    u.CompletedParts,
+		BytesUploaded:     u.BytesUploaded,
+		Retries:           u.Retries,
+		LastError:         u.LastError,
+		Status:            u.Status,
+		StartTime:         u.StartTime,
+		Error:             u.Error,
+		ErrorCode:         u.ErrorCode,
+		ETags:             make(map[int]string, len(u.ETags)),
+		Metadata:          make(map[string]string, len(u.Metadata)),
+		ComputeHash:       u.ComputeHash,
+		Transitions:       append([]StateTransition(nil), u.Transitions...),
+	}
+	if u.EndTime != nil {
+		endTime := *u.EndTime
+		record.EndTime = &endTime
+	}
+	if u.QueueExpiresAt != nil {
+		expiresAt := *u.QueueExpiresAt
+		record.QueueExpiresAt = &expiresAt
+	}
+	for k, v := range u.ETags {
+		record.ETags[k] = v
+	}
+	for k, v := range u.Metadata {
+		record.Metadata[k] = v
+	}
+	return record
+}
+
+// NewUploadStatusFromRecord restores an upload from its persisted form
+func NewUploadStatusFromRecord(record *UploadRecord) *UploadStatus {
+	upload := &UploadStatus{
+		UploadID:          record.UploadID,
+		S3UploadID:        record.S3UploadID,
+		ClientID:          record.ClientID,
+		FilePath:          record.FilePath,
+		S3Bucket:          record.S3Bucket,
+		S3Key:             record.S3Key,
+		FileSize:          record.FileSize,
+		FileModTime:       record.FileModTime,
+		FileHash:          record.FileHash,
+		ChunkSize:         record.ChunkSize,
+		ChecksumAlgorithm: record.ChecksumAlgorithm,
+		PartChecksums:     record.PartChecksums,
+		Checksum:          record.Checksum,
+		TotalParts:        record.TotalParts,
+		CompletedParts:    record.CompletedParts,
+		BytesUploaded:     record.BytesUploaded,
+		Retries:           record.Retries,
+		LastError:         record.LastError,
+		Status:            record.Status,
+		StartTime:         record.StartTime,
+		EndTime:           record.EndTime,
+		Error:             record.Error,
+		ErrorCode:         record.ErrorCode,
+		ETags:             record.ETags,
+		Metadata:          record.Metadata,
+		ComputeHash:       record.ComputeHash,
+		QueueExpiresAt:    record.QueueExpiresAt,
+		Transitions:       record.Transitions,
+	}
+	if upload.ETags == nil {
+		upload.ETags = make(map[int]string)
+	}
+	return upload
+}
+
+// Info returns the upload as reported by the API
+func (u *UploadStatus) Info() UploadInfo {
+	record := u.Record()
+	return UploadInfo{
+		UploadID:          record.UploadID,
+		ClientID:          record.ClientID,
+		FilePath:          record.FilePath,
+		S3Key:             record.S3Key,
+		FileSize:          record.FileSize,
+		FileHash:          record.FileHash,
+		ChecksumAlgorithm: record.ChecksumAlgorithm,
+		Checksum:          record.Checksum,
+		Status:            record.Status,
+		QueueExpiresAt:    record.QueueExpiresAt,
+		Progress:          u.GetProgress(),
+		CompletedParts:    record.CompletedParts,
+		TotalParts:        record.TotalParts,
+		BytesUploaded:     record.BytesUploaded,
+		Retries:           record.Retries,
+		LastError:         record.LastError,
+		StartTime:         record.StartTime,
+		EndTime:           record.EndTime,
+		Error:             record.Error,
+		ErrorCode:         record.ErrorCode,
+		Transitions:       record.Transitions,
+	}
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Revocation rejects a single token, or every token issued to a client up
+// to the time of the revocation
+type Revocation struct {
+	TokenID   string    `json:"token_id,omitempty"`  // The revoked token's jti; empty when revoking a client
+	ClientID  string    `json:"client_id,omitempty"` // The client whose tokens are revoked, or the token's client if known
+	Reason    string    `json:"reason,omitempty"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+// Key identifies the revocation in the store; revoking a client again
+// replaces its earlier revocation
+func (r *Revocation) Key() string {
+	if r.TokenID != "" {
+		return "token/" + r.TokenID
+	}
+	return "client/" + r.ClientID
+}
+
+// IsClientRevocation reports whether the revocation covers every token
+// issued to the client rather than a single token
+func (r *Revocation) IsClientRevocation() bool {
+	return r.TokenID == ""
+}
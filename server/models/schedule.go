@@ -0,0 +1,55 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Schedule triggers a download on a recurring cron schedule
+type Schedule struct {
+	ID            string            `json:"id"`
+	Name          string            `json:"name,omitempty"`
+	Cron          string            `json:"cron"`               // Five-field cron expression or descriptor such as @daily
+	Timezone      string            `json:"timezone,omitempty"` // IANA zone for the expression; server local time if empty
+	Selector      ClientSelector    `json:"selector"`
+	FilePath      string            `json:"file_path,omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	ComputeHash   bool              `json:"compute_hash,omitempty"`
+	JitterSeconds int               `json:"jitter_seconds,omitempty"` // Random delay per client, up to this
+	SkipMissed    bool              `json:"skip_missed,omitempty"`    // Don't run for clients that were offline when it fired
+	CreatedAt     time.Time         `json:"created_at"`
+	LastRunAt     *time.Time        `json:"last_run_at,omitempty"`
+	LastBatchID   string            `json:"last_batch_id,omitempty"`
+
+	// KnownClients maps the clients selected by earlier runs to the labels
+	// they were last seen online with, so those offline at a later run can
+	// be told apart from clients that left or no longer match
+	KnownClients map[string]map[string]string `json:"known_client_labels,omitempty"`
+
+	// Missed maps clients that were offline when the schedule fired to the
+	// time of the first run they missed; they run once they reconnect
+	Missed map[string]time.Time `json:"missed,omitempty"`
+}
+
+// UnmarshalJSON also reads schedules stored when KnownClients was a plain
+// list under known_clients. Their labels weren't recorded, so those clients
+// are taken to carry the selector's labels until they are seen online again.
+func (s *Schedule) UnmarshalJSON(data []byte) error {
+	type plain Schedule
+	var stored struct {
+		plain
+		LegacyKnownClients []string `json:"known_clients,omitempty"`
+	}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return err
+	}
+	*s = Schedule(stored.plain)
+
+	if s.KnownClients == nil && len(stored.LegacyKnownClients) > 0 {
+		s.KnownClients = make(map[string]map[string]string, len(stored.LegacyKnownClients))
+		for _, clientID := range stored.LegacyKnownClients {
+			s.KnownClients[clientID] = s.Selector.Labels
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package models
+
+import "errors"
+
+var errSelectorMode = errors.New("selector needs exactly one of client_ids, all or labels")
+
+// ClientSelector chooses the clients of a bulk operation; exactly one field
+// must be set
+type ClientSelector struct {
+	ClientIDs []string          `json:"client_ids,omitempty"` // Explicit list, connected or not
+	All       bool              `json:"all,omitempty"`        // Every connected client
+	Labels    map[string]string `json:"labels,omitempty"`     // Connected clients carrying all these labels
+}
+
+// Matches reports whether the selector picks the client when it carries the
+// given labels, connected or not
+func (s ClientSelector) Matches(clientID string, labels map[string]string) bool {
+	switch {
+	case s.All:
+		return true
+	case len(s.Labels) > 0:
+		for key, value := range s.Labels {
+			if labels[key] != value {
+				return false
+			}
+		}
+		return true
+	default:
+		for _, selected := range s.ClientIDs {
+			if selected == clientID {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Validate checks that exactly one way of selecting clients is used
+func (s ClientSelector) Validate() error {
+	modes := 0
+	if len(s.ClientIDs) > 0 {
+		modes++
+	}
+	if s.All {
+		modes++
+	}
+	if len(s.Labels) > 0 {
+		modes++
+	}
+	if modes != 1 {
+		return errSelectorMode
+	}
+	return nil
+}
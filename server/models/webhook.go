@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// WebhookEvent is an upload lifecycle event delivered to webhooks
+type WebhookEvent string
+
+const (
+	WebhookEventTriggered WebhookEvent = "upload.triggered"
+	WebhookEventCompleted WebhookEvent = "upload.completed" // The object is in S3
+	WebhookEventFailed    WebhookEvent = "upload.failed"
+	WebhookEventCancelled WebhookEvent = "upload.cancelled"
+)
+
+// IsValid reports whether the event is one webhooks can subscribe to
+func (e WebhookEvent) IsValid() bool {
+	switch e {
+	case WebhookEventTriggered, WebhookEventCompleted, WebhookEventFailed, WebhookEventCancelled:
+		return true
+	}
+	return false
+}
+
+// WebhookEventForTransition returns the lifecycle event a state change
+// represents, if any
+func WebhookEventForTransition(transition StateTransition) (WebhookEvent, bool) {
+	switch {
+	case transition.From == "":
+		return WebhookEventTriggered, true
+	case transition.To == UploadStateCompleted:
+		return WebhookEventCompleted, true
+	case transition.To == UploadStateFailed:
+		return WebhookEventFailed, true
+	case transition.To == UploadStateCancelled:
+		return WebhookEventCancelled, true
+	}
+	return "", false
+}
+
+// WebhookSubscription is an endpoint notified of upload lifecycle events
+type WebhookSubscription struct {
+	ID        string         `json:"id"`
+	URL       string         `json:"url"`
+	Secret    string         `json:"secret"`              // HMAC-SHA256 key for the signature header
+	Events    []WebhookEvent `json:"events,omitempty"`    // Events to deliver; empty means all
+	ClientID  string         `json:"client_id,omitempty"` // Only uploads from this client, if set
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// Matches reports whether the subscription wants an event for a client's upload
+func (s *WebhookSubscription) Matches(event WebhookEvent, clientID string) bool {
+	if s.ClientID != "" && s.ClientID != clientID {
+		return false
+	}
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, e := range s.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
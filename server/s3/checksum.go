@@ -0,0 +1,41 @@
+package s3
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	sharedModels "github.com/iriyanto1027/file-download-system/shared/models"
+)
+
+// Checksums are the expected additional checksums of a multipart upload's parts
+type Checksums struct {
+	Algorithm sharedModels.ChecksumAlgorithm // Empty when the upload has none
+	Parts     []string                       // Base64 checksum of each part, first part first
+}
+
+// Part returns the checksum of a part, empty if there is none
+func (c Checksums) Part(partNumber int) string {
+	if c.Algorithm == "" || partNumber < 1 || partNumber > len(c.Parts) {
+		return ""
+	}
+	return c.Parts[partNumber-1]
+}
+
+// CompositeChecksum returns the checksum S3 reports for an object uploaded in
+// parts: the checksum of the concatenated part checksums, suffixed with the
+// number of parts
+func CompositeChecksum(algorithm sharedModels.ChecksumAlgorithm, parts []string) (string, error) {
+	h, err := algorithm.NewHash()
+	if err != nil {
+		return "", err
+	}
+
+	for i, part := range parts {
+		raw, err := base64.StdEncoding.DecodeString(part)
+		if err != nil {
+			return "", fmt.Errorf("invalid checksum for part %d: %w", i+1, err)
+		}
+		h.Write(raw)
+	}
+	return fmt.Sprintf("%s-%d", base64.StdEncoding.EncodeToString(h.Sum(nil)), len(parts)), nil
+}
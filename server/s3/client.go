@@ -2,6 +2,7 @@ package s3
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -11,8 +12,12 @@ import (
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	sharedModels "github.com/iriyanto1027/file-download-system/shared/models"
 )
 
+// ErrNoSuchUpload is returned when a multipart upload no longer exists on S3
+var ErrNoSuchUpload = errors.New("multipart upload does not exist")
+
 // Client wraps the AWS S3 client
 type Client struct {
 	s3Client           *s3.Client
@@ -93,6 +98,11 @@ func NewClient(ctx context.Context, cfg Config) (*Client, error) {
 	return client, nil
 }
 
+// Bucket returns the name of the bucket uploads are written to
+func (c *Client) Bucket() string {
+	return c.bucket
+}
+
 // ensureBucket ensures the S3 bucket exists, creates it if not
 func (c *Client) ensureBucket(ctx context.Context) error {
 	// Check if bucket exists
@@ -119,13 +129,15 @@ func (c *Client) ensureBucket(ctx context.Context) error {
 
 // MultipartUploadConfig contains configuration for multipart upload
 type MultipartUploadConfig struct {
-	Key       string
-	FileSize  int64
-	ChunkSize int64
-	Metadata  map[string]string
+	Key               string
+	FileSize          int64
+	ChunkSize         int64
+	Metadata          map[string]string
+	ChecksumAlgorithm sharedModels.ChecksumAlgorithm // Set to have S3 verify every part; empty for none
 }
 
-// InitiateMultipartUpload starts a multipart upload and generates presigned URLs
+// InitiateMultipartUpload starts a multipart upload; presigned URLs for its
+// parts are generated on demand with PresignUploadParts
 func (c *Client) InitiateMultipartUpload(ctx context.Context, cfg MultipartUploadConfig) (*MultipartUpload, error) {
 	// Initiate multipart upload
 	input := &s3.CreateMultipartUploadInput{
@@ -137,6 +149,9 @@ func (c *Client) InitiateMultipartUpload(ctx context.Context, cfg MultipartUploa
 	if len(cfg.Metadata) > 0 {
 		input.Metadata = cfg.Metadata
 	}
+	if cfg.ChecksumAlgorithm != "" {
+		input.ChecksumAlgorithm = types.ChecksumAlgorithm(cfg.ChecksumAlgorithm)
+	}
 
 	output, err := c.s3Client.CreateMultipartUpload(ctx, input)
 	if err != nil {
@@ -152,57 +167,79 @@ func (c *Client) InitiateMultipartUpload(ctx context.Context, cfg MultipartUploa
 		totalParts++
 	}
 
-	// Generate presigned URLs for each part
+	return &MultipartUpload{
+		UploadID:   uploadID,
+		Bucket:     c.bucket,
+		Key:        cfg.Key,
+		TotalParts: totalParts,
+		ChunkSize:  cfg.ChunkSize,
+	}, nil
+}
+
+// PresignUploadParts generates fresh presigned URLs for the given parts of a
+// multipart upload. With checksums, each URL is signed for its part's
+// checksum, so S3 only accepts a part whose data matches it.
+func (c *Client) PresignUploadParts(ctx context.Context, key, uploadID string, partNumbers []int, checksums Checksums) ([]PresignedURL, error) {
 	presignClient := s3.NewPresignClient(c.s3Client)
-	presignedURLs := make([]PresignedURL, totalParts)
+	presignedURLs := make([]PresignedURL, len(partNumbers))
 
-	for i := 0; i < totalParts; i++ {
-		partNumber := i + 1
-		request, err := presignClient.PresignUploadPart(ctx, &s3.UploadPartInput{
+	for i, partNumber := range partNumbers {
+		expiresAt := time.Now().Add(c.presignedURLExpiry)
+		input := &s3.UploadPartInput{
 			Bucket:     aws.String(c.bucket),
-			Key:        aws.String(cfg.Key),
+			Key:        aws.String(key),
 			UploadId:   aws.String(uploadID),
 			PartNumber: aws.Int32(int32(partNumber)),
-		}, func(opts *s3.PresignOptions) {
+		}
+		checksum := checksums.Part(partNumber)
+		if checksum != "" {
+			input.ChecksumAlgorithm = types.ChecksumAlgorithm(checksums.Algorithm)
+			switch checksums.Algorithm {
+			case sharedModels.ChecksumAlgorithmSHA256:
+				input.ChecksumSHA256 = aws.String(checksum)
+			case sharedModels.ChecksumAlgorithmCRC32C:
+				input.ChecksumCRC32C = aws.String(checksum)
+			}
+		}
+
+		request, err := presignClient.PresignUploadPart(ctx, input, func(opts *s3.PresignOptions) {
 			opts.Expires = c.presignedURLExpiry
 		})
 
 		if err != nil {
-			// Abort the multipart upload if we fail to generate presigned URLs
-			c.AbortMultipartUpload(ctx, cfg.Key, uploadID)
 			return nil, fmt.Errorf("failed to generate presigned URL for part %d: %w", partNumber, err)
 		}
 
-		if i == 0 {
-			// Log first presigned URL to verify it points to correct endpoint
-			log.Printf("📍 Sample presigned URL (part 1): %s", request.URL)
-			log.Printf("   UploadID in presigned URL: %s", uploadID)
-		}
-
 		presignedURLs[i] = PresignedURL{
 			PartNumber: partNumber,
 			URL:        request.URL,
+			ExpiresAt:  expiresAt,
+			Checksum:   checksum,
 		}
 	}
 
-	return &MultipartUpload{
-		UploadID:      uploadID,
-		Bucket:        c.bucket,
-		Key:           cfg.Key,
-		TotalParts:    totalParts,
-		ChunkSize:     cfg.ChunkSize,
-		PresignedURLs: presignedURLs,
-	}, nil
+	return presignedURLs, nil
 }
 
-// CompleteMultipartUpload completes a multipart upload
-func (c *Client) CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []CompletedPart) error {
+// CompleteMultipartUpload completes a multipart upload. With a checksum
+// algorithm, S3 checks each part's checksum against the one it stored and
+// the object's composite checksum is returned.
+func (c *Client) CompleteMultipartUpload(ctx context.Context, key, uploadID string, checksumAlgorithm sharedModels.ChecksumAlgorithm, parts []CompletedPart) (string, error) {
 	completedParts := make([]types.CompletedPart, len(parts))
 	for i, part := range parts {
 		completedParts[i] = types.CompletedPart{
 			ETag:       aws.String(part.ETag),
 			PartNumber: aws.Int32(int32(part.PartNumber)),
 		}
+		if part.Checksum == "" {
+			continue
+		}
+		switch checksumAlgorithm {
+		case sharedModels.ChecksumAlgorithmSHA256:
+			completedParts[i].ChecksumSHA256 = aws.String(part.Checksum)
+		case sharedModels.ChecksumAlgorithmCRC32C:
+			completedParts[i].ChecksumCRC32C = aws.String(part.Checksum)
+		}
 	}
 
 	fmt.Printf("📋 CompleteMultipartUpload: uploadID=%s, key=%s, parts=%d\n", uploadID, key, len(parts))
@@ -210,7 +247,7 @@ func (c *Client) CompleteMultipartUpload(ctx context.Context, key, uploadID stri
 		fmt.Printf("   Part %d: PartNumber=%d, ETag=%s\n", i+1, part.PartNumber, part.ETag)
 	}
 
-	_, err := c.s3Client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+	output, err := c.s3Client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
 		Bucket:   aws.String(c.bucket),
 		Key:      aws.String(key),
 		UploadId: aws.String(uploadID),
@@ -220,10 +257,86 @@ func (c *Client) CompleteMultipartUpload(ctx context.Context, key, uploadID stri
 	})
 
 	if err != nil {
-		return fmt.Errorf("failed to complete multipart upload: %w", err)
+		return "", fmt.Errorf("failed to complete multipart upload: %w", err)
 	}
 
-	return nil
+	switch checksumAlgorithm {
+	case sharedModels.ChecksumAlgorithmSHA256:
+		return aws.ToString(output.ChecksumSHA256), nil
+	case sharedModels.ChecksumAlgorithmCRC32C:
+		return aws.ToString(output.ChecksumCRC32C), nil
+	}
+	return "", nil
+}
+
+// ListParts returns the parts S3 has already received for a multipart upload
+func (c *Client) ListParts(ctx context.Context, key, uploadID string) ([]CompletedPart, error) {
+	paginator := s3.NewListPartsPaginator(c.s3Client, &s3.ListPartsInput{
+		Bucket:   aws.String(c.bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+
+	parts := make([]CompletedPart, 0)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			var noSuchUpload *types.NoSuchUpload
+			if errors.As(err, &noSuchUpload) {
+				return nil, ErrNoSuchUpload
+			}
+			return nil, fmt.Errorf("failed to list parts: %w", err)
+		}
+
+		for _, part := range page.Parts {
+			checksum := aws.ToString(part.ChecksumSHA256)
+			if checksum == "" {
+				checksum = aws.ToString(part.ChecksumCRC32C)
+			}
+			parts = append(parts, CompletedPart{
+				PartNumber: int(aws.ToInt32(part.PartNumber)),
+				ETag:       aws.ToString(part.ETag),
+				Size:       aws.ToInt64(part.Size),
+				Checksum:   checksum,
+			})
+		}
+	}
+
+	return parts, nil
+}
+
+// PendingUpload is a multipart upload that was initiated but neither
+// completed nor aborted
+type PendingUpload struct {
+	Key       string
+	UploadID  string
+	Initiated time.Time
+}
+
+// ListMultipartUploads lists the multipart uploads in progress under prefix
+func (c *Client) ListMultipartUploads(ctx context.Context, prefix string) ([]PendingUpload, error) {
+	paginator := s3.NewListMultipartUploadsPaginator(c.s3Client, &s3.ListMultipartUploadsInput{
+		Bucket: aws.String(c.bucket),
+		Prefix: aws.String(prefix),
+	})
+
+	uploads := make([]PendingUpload, 0)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list multipart uploads: %w", err)
+		}
+
+		for _, upload := range page.Uploads {
+			uploads = append(uploads, PendingUpload{
+				Key:       aws.ToString(upload.Key),
+				UploadID:  aws.ToString(upload.UploadId),
+				Initiated: aws.ToTime(upload.Initiated),
+			})
+		}
+	}
+
+	return uploads, nil
 }
 
 // AbortMultipartUpload aborts a multipart upload
@@ -278,24 +391,27 @@ func (c *Client) DeleteObject(ctx context.Context, key string) error {
 
 // MultipartUpload contains information about a multipart upload
 type MultipartUpload struct {
-	UploadID      string
-	Bucket        string
-	Key           string
-	TotalParts    int
-	ChunkSize     int64
-	PresignedURLs []PresignedURL
+	UploadID   string
+	Bucket     string
+	Key        string
+	TotalParts int
+	ChunkSize  int64
 }
 
 // PresignedURL contains a presigned URL for a specific part
 type PresignedURL struct {
-	PartNumber int    `json:"part_number"`
-	URL        string `json:"url"`
+	PartNumber int       `json:"part_number"`
+	URL        string    `json:"url"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Checksum   string    `json:"checksum,omitempty"` // Base64 checksum the URL is signed for
 }
 
 // CompletedPart represents a completed upload part
 type CompletedPart struct {
 	PartNumber int
 	ETag       string
+	Size       int64  // Only set by ListParts
+	Checksum   string // Base64 additional checksum, if the upload has them
 }
 
 // ObjectMetadata contains metadata about an S3 object
@@ -0,0 +1,362 @@
+package scheduler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/iriyanto1027/file-download-system/server/models"
+	"github.com/iriyanto1027/file-download-system/server/store"
+	"github.com/robfig/cron/v3"
+)
+
+var (
+	// ErrNotFound is returned for an unknown schedule ID
+	ErrNotFound = errors.New("schedule not found")
+
+	// ErrInvalidSchedule is returned when a schedule fails validation
+	ErrInvalidSchedule = errors.New("invalid schedule")
+)
+
+// Runner starts the downloads of a schedule
+type Runner interface {
+	// ResolveClients returns the clients a selector matches; an explicit
+	// list may include offline clients
+	ResolveClients(selector models.ClientSelector) ([]string, error)
+
+	// IsClientConnected reports whether a client is online
+	IsClientConnected(clientID string) bool
+
+	// ClientLabels returns the labels of a connected client
+	ClientLabels(clientID string) (map[string]string, bool)
+
+	// StartScheduledBatch starts the schedule's download on the clients and
+	// returns the batch ID
+	StartScheduledBatch(schedule *models.Schedule, clientIDs []string) string
+}
+
+// Info is a schedule with its next run time
+type Info struct {
+	*models.Schedule
+	NextRunAt *time.Time `json:"next_run_at,omitempty"`
+}
+
+// Scheduler fires schedules on their cron expressions and catches up on
+// runs missed by offline clients when they reconnect
+type Scheduler struct {
+	cron      *cron.Cron
+	store     store.ScheduleStore
+	runner    Runner
+	mu        sync.Mutex
+	schedules map[string]*models.Schedule
+	entries   map[string]cron.EntryID
+}
+
+// New creates a scheduler and registers the stored schedules
+func New(scheduleStore store.ScheduleStore, runner Runner) (*Scheduler, error) {
+	s := &Scheduler{
+		cron:      cron.New(),
+		store:     scheduleStore,
+		runner:    runner,
+		schedules: make(map[string]*models.Schedule),
+		entries:   make(map[string]cron.EntryID),
+	}
+
+	stored, err := scheduleStore.ListSchedules()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load schedules: %w", err)
+	}
+	for _, schedule := range stored {
+		if err := s.register(schedule); err != nil {
+			log.Printf("⚠️ Skipping schedule %s: %v", schedule.ID, err)
+		}
+	}
+
+	return s, nil
+}
+
+// Start runs the cron loop in the background
+func (s *Scheduler) Start() {
+	s.cron.Start()
+}
+
+// Stop stops firing schedules; runs already started continue
+func (s *Scheduler) Stop() {
+	s.cron.Stop()
+}
+
+// Create validates, stores and registers a new schedule
+func (s *Scheduler) Create(schedule models.Schedule) (*Info, error) {
+	if err := schedule.Selector.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	if schedule.JitterSeconds < 0 {
+		return nil, fmt.Errorf("%w: jitter_seconds must not be negative", ErrInvalidSchedule)
+	}
+	if _, err := parseSpec(&schedule); err != nil {
+		return nil, err
+	}
+
+	schedule.ID = "sch_" + randomHex(8)
+	schedule.CreatedAt = time.Now()
+	schedule.LastRunAt = nil
+	schedule.LastBatchID = ""
+	schedule.KnownClients = nil
+	schedule.Missed = nil
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.store.SaveSchedule(&schedule); err != nil {
+		return nil, fmt.Errorf("failed to save schedule: %w", err)
+	}
+	if err := s.register(&schedule); err != nil {
+		s.store.DeleteSchedule(schedule.ID)
+		return nil, err
+	}
+
+	log.Printf("⏰ Schedule %s created: %q", schedule.ID, schedule.Cron)
+	return s.info(&schedule), nil
+}
+
+// Get returns a schedule by ID
+func (s *Scheduler) Get(id string) (*Info, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedule, exists := s.schedules[id]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return s.info(schedule), nil
+}
+
+// List returns every schedule, oldest first
+func (s *Scheduler) List() []*Info {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	infos := make([]*Info, 0, len(s.schedules))
+	for _, schedule := range s.schedules {
+		infos = append(infos, s.info(schedule))
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].CreatedAt.Before(infos[j].CreatedAt)
+	})
+	return infos
+}
+
+// Delete unregisters and removes a schedule
+func (s *Scheduler) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.schedules[id]; !exists {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err := s.store.DeleteSchedule(id); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+
+	s.cron.Remove(s.entries[id])
+	delete(s.entries, id)
+	delete(s.schedules, id)
+	log.Printf("⏰ Schedule %s deleted", id)
+	return nil
+}
+
+// ClientConnected runs the schedules a client missed while it was offline
+func (s *Scheduler) ClientConnected(clientID string) {
+	labels, _ := s.runner.ClientLabels(clientID)
+
+	s.mu.Lock()
+	var due []*models.Schedule
+	for _, schedule := range s.schedules {
+		if _, missed := schedule.Missed[clientID]; !missed {
+			continue
+		}
+		delete(schedule.Missed, clientID)
+		s.save(schedule)
+		// It may have come back with labels the selector no longer matches
+		if !schedule.Selector.Matches(clientID, labels) {
+			continue
+		}
+		due = append(due, copySchedule(schedule))
+	}
+	s.mu.Unlock()
+
+	// One catch-up run per schedule, however many runs were missed
+	for _, schedule := range due {
+		batchID := s.runner.StartScheduledBatch(schedule, []string{clientID})
+		log.Printf("⏰ Schedule %s: running missed download for %s (batch %s)", schedule.ID, clientID, batchID)
+	}
+}
+
+// register adds a schedule to the cron loop; the caller must hold the lock
+// unless the scheduler is not shared yet
+func (s *Scheduler) register(schedule *models.Schedule) error {
+	spec, err := parseSpec(schedule)
+	if err != nil {
+		return err
+	}
+
+	id := schedule.ID
+	entryID := s.cron.Schedule(spec, cron.FuncJob(func() { s.fire(id) }))
+	s.schedules[id] = schedule
+	s.entries[id] = entryID
+	return nil
+}
+
+// fire runs a schedule: online clients start a batch, offline ones are
+// remembered so they run when they reconnect
+func (s *Scheduler) fire(id string) {
+	s.mu.Lock()
+	schedule, exists := s.schedules[id]
+	if !exists {
+		s.mu.Unlock()
+		return
+	}
+
+	clientIDs, err := s.runner.ResolveClients(schedule.Selector)
+	if err != nil {
+		s.mu.Unlock()
+		log.Printf("❌ Schedule %s: %v", id, err)
+		return
+	}
+
+	now := time.Now()
+	selected := make(map[string]bool, len(clientIDs))
+	known := make(map[string]map[string]string, len(clientIDs))
+	var online, offline []string
+	for _, clientID := range clientIDs {
+		selected[clientID] = true
+		if labels, connected := s.runner.ClientLabels(clientID); connected {
+			online = append(online, clientID)
+			known[clientID] = labels
+		} else {
+			offline = append(offline, clientID)
+		}
+	}
+
+	// A known client the selector didn't return is offline if the selector
+	// still matches the labels it was last seen with. Clients that are
+	// connected but unselected, or no longer match, are forgotten.
+	for clientID, labels := range schedule.KnownClients {
+		if _, seen := known[clientID]; seen || s.runner.IsClientConnected(clientID) {
+			continue
+		}
+		if !selected[clientID] && !schedule.Selector.Matches(clientID, labels) {
+			continue
+		}
+		known[clientID] = labels
+		if !selected[clientID] {
+			offline = append(offline, clientID)
+		}
+	}
+	schedule.KnownClients = known
+
+	// Drop missed runs of clients the schedule no longer selects
+	for clientID := range schedule.Missed {
+		if _, stillKnown := known[clientID]; !stillKnown && !selected[clientID] {
+			delete(schedule.Missed, clientID)
+		}
+	}
+
+	if !schedule.SkipMissed && len(offline) > 0 {
+		if schedule.Missed == nil {
+			schedule.Missed = make(map[string]time.Time)
+		}
+		for _, clientID := range offline {
+			if _, already := schedule.Missed[clientID]; !already {
+				schedule.Missed[clientID] = now
+			}
+		}
+	}
+
+	schedule.LastRunAt = &now
+	run := copySchedule(schedule)
+	s.mu.Unlock()
+
+	log.Printf("⏰ Schedule %s fired: %d clients online, %d offline", id, len(online), len(offline))
+	if len(online) == 0 {
+		s.mu.Lock()
+		s.save(schedule)
+		s.mu.Unlock()
+		return
+	}
+
+	batchID := s.runner.StartScheduledBatch(run, online)
+
+	s.mu.Lock()
+	schedule.LastBatchID = batchID
+	s.save(schedule)
+	s.mu.Unlock()
+}
+
+// save persists a schedule; the caller must hold the lock
+func (s *Scheduler) save(schedule *models.Schedule) {
+	if err := s.store.SaveSchedule(schedule); err != nil {
+		log.Printf("Failed to persist schedule %s: %v", schedule.ID, err)
+	}
+}
+
+// info builds the API view of a schedule; the caller must hold the lock
+func (s *Scheduler) info(schedule *models.Schedule) *Info {
+	info := &Info{Schedule: copySchedule(schedule)}
+	if entry := s.cron.Entry(s.entries[schedule.ID]); entry.Valid() {
+		next := entry.Next
+		if next.IsZero() {
+			// The cron loop computes Next once it is running
+			next = entry.Schedule.Next(time.Now())
+		}
+		info.NextRunAt = &next
+	}
+	return info
+}
+
+// parseSpec parses the schedule's cron expression in its timezone
+func parseSpec(schedule *models.Schedule) (cron.Schedule, error) {
+	expression := schedule.Cron
+	if schedule.Timezone != "" {
+		if _, err := time.LoadLocation(schedule.Timezone); err != nil {
+			return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidSchedule, schedule.Timezone)
+		}
+		expression = "CRON_TZ=" + schedule.Timezone + " " + expression
+	}
+
+	spec, err := cron.ParseStandard(expression)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	return spec, nil
+}
+
+// copySchedule returns a deep copy safe to use without the lock
+func copySchedule(schedule *models.Schedule) *models.Schedule {
+	c := *schedule
+	if schedule.KnownClients != nil {
+		c.KnownClients = make(map[string]map[string]string, len(schedule.KnownClients))
+		for clientID, labels := range schedule.KnownClients {
+			c.KnownClients[clientID] = labels
+		}
+	}
+	if schedule.Missed != nil {
+		c.Missed = make(map[string]time.Time, len(schedule.Missed))
+		for clientID, at := range schedule.Missed {
+			c.Missed[clientID] = at
+		}
+	}
+	return &c
+}
+
+// randomHex returns n random bytes as hex
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,253 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/iriyanto1027/file-download-system/server/models"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	uploadsBucket     = []byte("uploads")
+	webhooksBucket    = []byte("webhooks")
+	schedulesBucket   = []byte("schedules")
+	revocationsBucket = []byte("revocations")
+	auditBucket       = []byte("audit")
+)
+
+// BoltStore keeps records in an embedded BoltDB file
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// OpenBoltStore opens or creates the database at path
+func OpenBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open upload store %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{uploadsBucket, webhooksBucket, schedulesBucket, revocationsBucket, auditBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize upload store: %w", err)
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+// Save implements UploadStore
+func (s *BoltStore) Save(record *models.UploadRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode upload %s: %w", record.UploadID, err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(uploadsBucket).Put([]byte(record.UploadID), data)
+	})
+}
+
+// Get implements UploadStore
+func (s *BoltStore) Get(uploadID string) (*models.UploadRecord, error) {
+	var record *models.UploadRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(uploadsBucket).Get([]byte(uploadID))
+		if data == nil {
+			return ErrNotFound
+		}
+		var err error
+		record, err = decodeRecord(data)
+		return err
+	})
+	return record, err
+}
+
+// List implements UploadStore
+func (s *BoltStore) List() ([]*models.UploadRecord, error) {
+	var records []*models.UploadRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(uploadsBucket).ForEach(func(_, data []byte) error {
+			record, err := decodeRecord(data)
+			if err != nil {
+				return err
+			}
+			records = append(records, record)
+			return nil
+		})
+	})
+	return records, err
+}
+
+// Close implements UploadStore
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// SaveWebhook implements WebhookStore
+func (s *BoltStore) SaveWebhook(subscription *models.WebhookSubscription) error {
+	data, err := json.Marshal(subscription)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook %s: %w", subscription.ID, err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(webhooksBucket).Put([]byte(subscription.ID), data)
+	})
+}
+
+// DeleteWebhook implements WebhookStore
+func (s *BoltStore) DeleteWebhook(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(webhooksBucket)
+		if bucket.Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+		return bucket.Delete([]byte(id))
+	})
+}
+
+// ListWebhooks implements WebhookStore
+func (s *BoltStore) ListWebhooks() ([]*models.WebhookSubscription, error) {
+	var subscriptions []*models.WebhookSubscription
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(webhooksBucket).ForEach(func(_, data []byte) error {
+			var subscription models.WebhookSubscription
+			if err := json.Unmarshal(data, &subscription); err != nil {
+				return fmt.Errorf("failed to decode webhook: %w", err)
+			}
+			subscriptions = append(subscriptions, &subscription)
+			return nil
+		})
+	})
+	return subscriptions, err
+}
+
+// SaveSchedule implements ScheduleStore
+func (s *BoltStore) SaveSchedule(schedule *models.Schedule) error {
+	data, err := json.Marshal(schedule)
+	if err != nil {
+		return fmt.Errorf("failed to encode schedule %s: %w", schedule.ID, err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(schedulesBucket).Put([]byte(schedule.ID), data)
+	})
+}
+
+// DeleteSchedule implements ScheduleStore
+func (s *BoltStore) DeleteSchedule(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(schedulesBucket)
+		if bucket.Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+		return bucket.Delete([]byte(id))
+	})
+}
+
+// ListSchedules implements ScheduleStore
+func (s *BoltStore) ListSchedules() ([]*models.Schedule, error) {
+	var schedules []*models.Schedule
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(schedulesBucket).ForEach(func(_, data []byte) error {
+			var schedule models.Schedule
+			if err := json.Unmarshal(data, &schedule); err != nil {
+				return fmt.Errorf("failed to decode schedule: %w", err)
+			}
+			schedules = append(schedules, &schedule)
+			return nil
+		})
+	})
+	return schedules, err
+}
+
+// SaveRevocation implements RevocationStore
+func (s *BoltStore) SaveRevocation(revocation *models.Revocation) error {
+	data, err := json.Marshal(revocation)
+	if err != nil {
+		return fmt.Errorf("failed to encode revocation %s: %w", revocation.Key(), err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(revocationsBucket).Put([]byte(revocation.Key()), data)
+	})
+}
+
+// ListRevocations implements RevocationStore
+func (s *BoltStore) ListRevocations() ([]*models.Revocation, error) {
+	var revocations []*models.Revocation
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(revocationsBucket).ForEach(func(_, data []byte) error {
+			var revocation models.Revocation
+			if err := json.Unmarshal(data, &revocation); err != nil {
+				return fmt.Errorf("failed to decode revocation: %w", err)
+			}
+			revocations = append(revocations, &revocation)
+			return nil
+		})
+	})
+	return revocations, err
+}
+
+// AppendAudit implements AuditStore; keys are the big-endian IDs, so the
+// bucket iterates in the order entries were written
+func (s *BoltStore) AppendAudit(entry *models.AuditEntry) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(auditBucket)
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		entry.ID = id
+
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to encode audit entry: %w", err)
+		}
+		return bucket.Put(auditKey(id), data)
+	})
+}
+
+// ScanAudit implements AuditStore
+func (s *BoltStore) ScanAudit(afterID uint64, fn func(entry *models.AuditEntry) bool) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(auditBucket).Cursor()
+		for key, data := cursor.Seek(auditKey(afterID + 1)); key != nil; key, data = cursor.Next() {
+			var entry models.AuditEntry
+			if err := json.Unmarshal(data, &entry); err != nil {
+				return fmt.Errorf("failed to decode audit entry: %w", err)
+			}
+			if !fn(&entry) {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+// auditKey encodes an audit entry ID as a sortable key
+func auditKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
+
+// decodeRecord parses a stored record
+func decodeRecord(data []byte) (*models.UploadRecord, error) {
+	var record models.UploadRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to decode upload record: %w", err)
+	}
+	return &record, nil
+}
@@ -0,0 +1,202 @@
+package store
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/iriyanto1027/file-download-system/server/models"
+)
+
+// MemoryStore keeps records in memory; nothing survives a restart
+type MemoryStore struct {
+	records     map[string][]byte
+	webhooks    map[string]models.WebhookSubscription
+	schedules   map[string][]byte
+	revocations map[string]models.Revocation
+	audit       [][]byte // Encoded entries; an entry's ID is its index plus one
+	mu          sync.RWMutex
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records:     make(map[string][]byte),
+		webhooks:    make(map[string]models.WebhookSubscription),
+		schedules:   make(map[string][]byte),
+		revocations: make(map[string]models.Revocation),
+	}
+}
+
+// Save implements UploadStore
+func (s *MemoryStore) Save(record *models.UploadRecord) error {
+	// Store an encoded copy so later changes to the record don't leak in
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[record.UploadID] = data
+	return nil
+}
+
+// Get implements UploadStore
+func (s *MemoryStore) Get(uploadID string) (*models.UploadRecord, error) {
+	s.mu.RLock()
+	data, exists := s.records[uploadID]
+	s.mu.RUnlock()
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return decodeRecord(data)
+}
+
+// List implements UploadStore
+func (s *MemoryStore) List() ([]*models.UploadRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	records := make([]*models.UploadRecord, 0, len(s.records))
+	for _, data := range s.records {
+		record, err := decodeRecord(data)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Close implements UploadStore
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// SaveWebhook implements WebhookStore
+func (s *MemoryStore) SaveWebhook(subscription *models.WebhookSubscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := *subscription
+	stored.Events = append([]models.WebhookEvent(nil), subscription.Events...)
+	s.webhooks[subscription.ID] = stored
+	return nil
+}
+
+// DeleteWebhook implements WebhookStore
+func (s *MemoryStore) DeleteWebhook(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.webhooks[id]; !exists {
+		return ErrNotFound
+	}
+	delete(s.webhooks, id)
+	return nil
+}
+
+// ListWebhooks implements WebhookStore
+func (s *MemoryStore) ListWebhooks() ([]*models.WebhookSubscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	subscriptions := make([]*models.WebhookSubscription, 0, len(s.webhooks))
+	for _, stored := range s.webhooks {
+		subscription := stored
+		subscriptions = append(subscriptions, &subscription)
+	}
+	return subscriptions, nil
+}
+
+// SaveSchedule implements ScheduleStore
+func (s *MemoryStore) SaveSchedule(schedule *models.Schedule) error {
+	data, err := json.Marshal(schedule)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.schedules[schedule.ID] = data
+	return nil
+}
+
+// DeleteSchedule implements ScheduleStore
+func (s *MemoryStore) DeleteSchedule(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.schedules[id]; !exists {
+		return ErrNotFound
+	}
+	delete(s.schedules, id)
+	return nil
+}
+
+// ListSchedules implements ScheduleStore
+func (s *MemoryStore) ListSchedules() ([]*models.Schedule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	schedules := make([]*models.Schedule, 0, len(s.schedules))
+	for _, data := range s.schedules {
+		var schedule models.Schedule
+		if err := json.Unmarshal(data, &schedule); err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, &schedule)
+	}
+	return schedules, nil
+}
+
+// SaveRevocation implements RevocationStore
+func (s *MemoryStore) SaveRevocation(revocation *models.Revocation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revocations[revocation.Key()] = *revocation
+	return nil
+}
+
+// ListRevocations implements RevocationStore
+func (s *MemoryStore) ListRevocations() ([]*models.Revocation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	revocations := make([]*models.Revocation, 0, len(s.revocations))
+	for _, stored := range s.revocations {
+		revocation := stored
+		revocations = append(revocations, &revocation)
+	}
+	return revocations, nil
+}
+
+// AppendAudit implements AuditStore
+func (s *MemoryStore) AppendAudit(entry *models.AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry.ID = uint64(len(s.audit)) + 1
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	s.audit = append(s.audit, data)
+	return nil
+}
+
+// ScanAudit implements AuditStore
+func (s *MemoryStore) ScanAudit(afterID uint64, fn func(entry *models.AuditEntry) bool) error {
+	s.mu.RLock()
+	entries := s.audit
+	s.mu.RUnlock()
+
+	// Appends never change the entries already in the slice
+	for i := afterID; i < uint64(len(entries)); i++ {
+		var entry models.AuditEntry
+		if err := json.Unmarshal(entries[i], &entry); err != nil {
+			return err
+		}
+		if !fn(&entry) {
+			return nil
+		}
+	}
+	return nil
+}
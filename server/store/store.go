@@ -0,0 +1,69 @@
+package store
+
+import (
+	"errors"
+
+	"github.com/iriyanto1027/file-download-system/server/models"
+)
+
+// ErrNotFound is returned when no record exists for an ID
+var ErrNotFound = errors.New("not found")
+
+// UploadStore persists upload records so they survive server restarts
+type UploadStore interface {
+	// Save inserts or replaces the record for an upload
+	Save(record *models.UploadRecord) error
+
+	// Get returns the record for an upload or ErrNotFound
+	Get(uploadID string) (*models.UploadRecord, error)
+
+	// List returns every stored record
+	List() ([]*models.UploadRecord, error)
+
+	// Close releases the store's resources
+	Close() error
+}
+
+// WebhookStore persists webhook subscriptions
+type WebhookStore interface {
+	// SaveWebhook inserts or replaces a subscription
+	SaveWebhook(subscription *models.WebhookSubscription) error
+
+	// DeleteWebhook removes a subscription or returns ErrNotFound
+	DeleteWebhook(id string) error
+
+	// ListWebhooks returns every stored subscription
+	ListWebhooks() ([]*models.WebhookSubscription, error)
+}
+
+// ScheduleStore persists download schedules
+type ScheduleStore interface {
+	// SaveSchedule inserts or replaces a schedule
+	SaveSchedule(schedule *models.Schedule) error
+
+	// DeleteSchedule removes a schedule or returns ErrNotFound
+	DeleteSchedule(id string) error
+
+	// ListSchedules returns every stored schedule
+	ListSchedules() ([]*models.Schedule, error)
+}
+
+// RevocationStore persists revoked tokens and clients
+type RevocationStore interface {
+	// SaveRevocation inserts or replaces a revocation by its key
+	SaveRevocation(revocation *models.Revocation) error
+
+	// ListRevocations returns every stored revocation
+	ListRevocations() ([]*models.Revocation, error)
+}
+
+// AuditStore keeps the append-only audit log; entries are never changed or
+// removed
+type AuditStore interface {
+	// AppendAudit stores an entry, assigning it the next ID
+	AppendAudit(entry *models.AuditEntry) error
+
+	// ScanAudit calls fn with every entry after afterID in ID order until fn
+	// returns false
+	ScanAudit(afterID uint64, fn func(entry *models.AuditEntry) bool) error
+}
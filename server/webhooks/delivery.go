@@ -0,0 +1,57 @@
+package webhooks
+
+import (
+	"time"
+
+	"github.com/iriyanto1027/file-download-system/server/models"
+)
+
+// DeliveryStatus is the outcome of a webhook delivery
+type DeliveryStatus string
+
+const (
+	DeliveryStatusPending   DeliveryStatus = "pending" // Being sent or waiting to retry
+	DeliveryStatusSucceeded DeliveryStatus = "succeeded"
+	DeliveryStatusFailed    DeliveryStatus = "failed" // Gave up
+)
+
+// Delivery records the attempts to send one event to one subscription
+type Delivery struct {
+	ID             string              `json:"id"`
+	SubscriptionID string              `json:"subscription_id"`
+	Event          models.WebhookEvent `json:"event"`
+	UploadID       string              `json:"upload_id"`
+	Status         DeliveryStatus      `json:"status"`
+	Attempts       []DeliveryAttempt   `json:"attempts"`
+	CreatedAt      time.Time           `json:"created_at"`
+	NextAttemptAt  *time.Time          `json:"next_attempt_at,omitempty"`
+}
+
+// DeliveryAttempt is a single POST to the subscriber
+type DeliveryAttempt struct {
+	Attempt    int       `json:"attempt"`
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+// Payload is the JSON body posted to subscribers
+type Payload struct {
+	ID        string              `json:"id"` // Delivery ID, the same on every retry
+	Event     models.WebhookEvent `json:"event"`
+	Timestamp time.Time           `json:"timestamp"`
+	S3Bucket  string              `json:"s3_bucket"`
+	Upload    models.UploadInfo   `json:"upload"`
+}
+
+// copyDelivery returns a copy safe to hand out while retries continue
+func copyDelivery(d *Delivery) Delivery {
+	c := *d
+	c.Attempts = append([]DeliveryAttempt(nil), d.Attempts...)
+	if d.NextAttemptAt != nil {
+		next := *d.NextAttemptAt
+		c.NextAttemptAt = &next
+	}
+	return c
+}
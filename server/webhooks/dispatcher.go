@@ -0,0 +1,418 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/iriyanto1027/file-download-system/server/events"
+	"github.com/iriyanto1027/file-download-system/server/models"
+	"github.com/iriyanto1027/file-download-system/server/store"
+)
+
+var (
+	// ErrNotFound is returned for an unknown subscription ID
+	ErrNotFound = errors.New("webhook not found")
+
+	// ErrInvalidSubscription is returned when a subscription fails validation
+	ErrInvalidSubscription = errors.New("invalid webhook")
+)
+
+// Signature headers sent with every delivery. The signature is
+// hex(HMAC-SHA256(secret, timestamp + "." + body)).
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// UploadLookup finds an upload by ID
+type UploadLookup func(uploadID string) (*models.UploadStatus, bool)
+
+// Config holds the dispatcher configuration
+type Config struct {
+	MaxAttempts     int           // Attempts per delivery, including the first
+	InitialBackoff  time.Duration // Delay before the first retry; doubles each time
+	MaxBackoff      time.Duration
+	Timeout         time.Duration // Per-request timeout
+	DeliveryLogSize int           // Deliveries kept per subscription for GET .../deliveries
+}
+
+// Dispatcher delivers upload lifecycle events to webhook subscriptions
+type Dispatcher struct {
+	config        Config
+	store         store.WebhookStore
+	broker        *events.Broker
+	lookup        UploadLookup
+	httpClient    *http.Client
+	mu            sync.RWMutex
+	subscriptions map[string]*models.WebhookSubscription
+	deliveries    map[string][]*Delivery // Subscription ID -> recent deliveries, oldest first
+	wg            sync.WaitGroup
+}
+
+// NewDispatcher creates a dispatcher and loads the stored subscriptions
+func NewDispatcher(cfg Config, webhookStore store.WebhookStore, broker *events.Broker, lookup UploadLookup) (*Dispatcher, error) {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.InitialBackoff == 0 {
+		cfg.InitialBackoff = 2 * time.Second
+	}
+	if cfg.MaxBackoff == 0 {
+		cfg.MaxBackoff = 5 * time.Minute
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.DeliveryLogSize == 0 {
+		cfg.DeliveryLogSize = 100
+	}
+
+	stored, err := webhookStore.ListWebhooks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load webhooks: %w", err)
+	}
+
+	subscriptions := make(map[string]*models.WebhookSubscription, len(stored))
+	for _, subscription := range stored {
+		subscriptions[subscription.ID] = subscription
+	}
+
+	return &Dispatcher{
+		config:        cfg,
+		store:         webhookStore,
+		broker:        broker,
+		lookup:        lookup,
+		httpClient:    &http.Client{Timeout: cfg.Timeout},
+		subscriptions: subscriptions,
+		deliveries:    make(map[string][]*Delivery),
+	}, nil
+}
+
+// Start delivers events until ctx is cancelled, then waits for in-flight
+// deliveries to stop; pending retries are abandoned
+func (d *Dispatcher) Start(ctx context.Context) {
+	sub := d.broker.Subscribe(events.Filter{}, 0)
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+
+		var lastEventID uint64
+		for {
+			select {
+			case <-ctx.Done():
+				sub.Close()
+				return
+
+			case event, ok := <-sub.Events():
+				if !ok {
+					// Dropped for falling behind; pick up where we left off
+					log.Printf("⚠️ Webhook dispatcher fell behind, resubscribing from event %d", lastEventID)
+					sub = d.broker.Subscribe(events.Filter{}, lastEventID)
+					continue
+				}
+				lastEventID = event.ID
+				d.handleEvent(ctx, event)
+			}
+		}
+	}()
+}
+
+// Wait blocks until the dispatcher and its deliveries have stopped
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+// Create validates and stores a new subscription, generating its ID and,
+// if none is given, its secret
+func (d *Dispatcher) Create(subscription models.WebhookSubscription) (*models.WebhookSubscription, error) {
+	if err := validateSubscription(&subscription); err != nil {
+		return nil, err
+	}
+
+	subscription.ID = "wh_" + randomHex(12)
+	if subscription.Secret == "" {
+		subscription.Secret = "whsec_" + randomHex(24)
+	}
+	subscription.CreatedAt = time.Now()
+
+	if err := d.store.SaveWebhook(&subscription); err != nil {
+		return nil, fmt.Errorf("failed to save webhook: %w", err)
+	}
+
+	d.mu.Lock()
+	d.subscriptions[subscription.ID] = &subscription
+	d.mu.Unlock()
+
+	log.Printf("🪝 Webhook %s created for %s", subscription.ID, subscription.URL)
+	created := subscription
+	return &created, nil
+}
+
+// Get returns a subscription by ID
+func (d *Dispatcher) Get(id string) (*models.WebhookSubscription, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	subscription, exists := d.subscriptions[id]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	found := *subscription
+	return &found, nil
+}
+
+// List returns every subscription, oldest first
+func (d *Dispatcher) List() []*models.WebhookSubscription {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	subscriptions := make([]*models.WebhookSubscription, 0, len(d.subscriptions))
+	for _, subscription := range d.subscriptions {
+		found := *subscription
+		subscriptions = append(subscriptions, &found)
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].CreatedAt.Before(subscriptions[j].CreatedAt)
+	})
+	return subscriptions
+}
+
+// Delete removes a subscription; deliveries already in flight still finish
+func (d *Dispatcher) Delete(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, exists := d.subscriptions[id]; !exists {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err := d.store.DeleteWebhook(id); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	delete(d.subscriptions, id)
+	delete(d.deliveries, id)
+	log.Printf("🪝 Webhook %s deleted", id)
+	return nil
+}
+
+// Deliveries returns a subscription's recent deliveries, newest first
+func (d *Dispatcher) Deliveries(id string) ([]Delivery, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if _, exists := d.subscriptions[id]; !exists {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	recent := d.deliveries[id]
+	deliveries := make([]Delivery, 0, len(recent))
+	for i := len(recent) - 1; i >= 0; i-- {
+		deliveries = append(deliveries, copyDelivery(recent[i]))
+	}
+	return deliveries, nil
+}
+
+// handleEvent starts a delivery to every subscription wanting the event
+func (d *Dispatcher) handleEvent(ctx context.Context, event events.Event) {
+	if event.Type != events.EventTypeState || event.State == nil {
+		return
+	}
+	webhookEvent, ok := models.WebhookEventForTransition(models.StateTransition{
+		From: event.State.From,
+		To:   event.State.To,
+	})
+	if !ok {
+		return
+	}
+
+	upload, exists := d.lookup(event.UploadID)
+	if !exists {
+		return
+	}
+	info := upload.Info()
+	info.Transitions = nil
+	bucket := upload.Record().S3Bucket
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, subscription := range d.subscriptions {
+		if !subscription.Matches(webhookEvent, event.ClientID) {
+			continue
+		}
+
+		delivery := &Delivery{
+			ID:             "dlv_" + randomHex(12),
+			SubscriptionID: subscription.ID,
+			Event:          webhookEvent,
+			UploadID:       event.UploadID,
+			Status:         DeliveryStatusPending,
+			CreatedAt:      time.Now(),
+		}
+		body, err := json.Marshal(Payload{
+			ID:        delivery.ID,
+			Event:     webhookEvent,
+			Timestamp: event.Time,
+			S3Bucket:  bucket,
+			Upload:    info,
+		})
+		if err != nil {
+			log.Printf("❌ Failed to encode webhook payload: %v", err)
+			continue
+		}
+
+		d.recordDelivery(delivery)
+		target := *subscription
+
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.deliver(ctx, &target, delivery, body)
+		}()
+	}
+}
+
+// recordDelivery adds a delivery to its subscription's log, trimming the
+// oldest; the caller must hold the lock
+func (d *Dispatcher) recordDelivery(delivery *Delivery) {
+	deliveries := append(d.deliveries[delivery.SubscriptionID], delivery)
+	if len(deliveries) > d.config.DeliveryLogSize {
+		deliveries = deliveries[len(deliveries)-d.config.DeliveryLogSize:]
+	}
+	d.deliveries[delivery.SubscriptionID] = deliveries
+}
+
+// deliver posts the payload, retrying with exponential backoff
+func (d *Dispatcher) deliver(ctx context.Context, subscription *models.WebhookSubscription, delivery *Delivery, body []byte) {
+	backoff := d.config.InitialBackoff
+
+	for attempt := 1; attempt <= d.config.MaxAttempts; attempt++ {
+		result, retry := d.post(ctx, subscription, delivery, body)
+		result.Attempt = attempt
+
+		d.mu.Lock()
+		delivery.Attempts = append(delivery.Attempts, result)
+		delivery.NextAttemptAt = nil
+		switch {
+		case result.Error == "":
+			delivery.Status = DeliveryStatusSucceeded
+		case !retry || attempt == d.config.MaxAttempts:
+			delivery.Status = DeliveryStatusFailed
+		default:
+			next := time.Now().Add(backoff)
+			delivery.NextAttemptAt = &next
+		}
+		status := delivery.Status
+		d.mu.Unlock()
+
+		switch status {
+		case DeliveryStatusSucceeded:
+			log.Printf("🪝 Delivered %s for upload %s to webhook %s", delivery.Event, delivery.UploadID, subscription.ID)
+			return
+		case DeliveryStatusFailed:
+			log.Printf("❌ Giving up on %s for upload %s to webhook %s after %d attempts: %s",
+				delivery.Event, delivery.UploadID, subscription.ID, attempt, result.Error)
+			return
+		}
+
+		log.Printf("⚠️ Webhook %s attempt %d failed, retrying in %v: %s", subscription.ID, attempt, backoff, result.Error)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > d.config.MaxBackoff {
+			backoff = d.config.MaxBackoff
+		}
+	}
+}
+
+// post makes one signed delivery attempt and reports whether a failure is
+// worth retrying
+func (d *Dispatcher) post(ctx context.Context, subscription *models.WebhookSubscription, delivery *Delivery, body []byte) (DeliveryAttempt, bool) {
+	start := time.Now()
+	result := DeliveryAttempt{At: start}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		result.Error = err.Error()
+		return result, false
+	}
+
+	timestamp := strconv.FormatInt(start.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "file-download-system-webhooks/1.0")
+	req.Header.Set(HeaderEvent, string(delivery.Event))
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, "sha256="+Sign(subscription.Secret, timestamp, body))
+
+	resp, err := d.httpClient.Do(req)
+	result.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result, true
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	result.StatusCode = resp.StatusCode
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return result, false
+	}
+
+	result.Error = fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	// Other client errors mean the request itself is unacceptable
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+	return result, retry
+}
+
+// Sign computes the hex HMAC-SHA256 signature of a delivery
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// validateSubscription checks the URL and event filter
+func validateSubscription(subscription *models.WebhookSubscription) error {
+	parsed, err := url.Parse(subscription.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidSubscription)
+	}
+	for _, event := range subscription.Events {
+		if !event.IsValid() {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalidSubscription, event)
+		}
+	}
+	return nil
+}
+
+// randomHex returns n random bytes as hex
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,121 @@
+package websocket
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+
+	sharedModels "github.com/iriyanto1027/file-download-system/shared/models"
+)
+
+// ProgressFunc receives in_progress responses while a call is running
+type ProgressFunc func(resp *sharedModels.ResponseMessage)
+
+// pendingCall is a command waiting for its final response
+type pendingCall struct {
+	clientID string
+	progress chan *sharedModels.ResponseMessage
+	result   chan *sharedModels.ResponseMessage
+}
+
+// Call sends a command and waits for the client's final response. Responses
+// to the command are not passed to the MessageHandler. The returned error is
+// nil whenever the client answered, even if the response status is error.
+func (m *Manager) Call(ctx context.Context, clientID string, cmd *sharedModels.CommandMessage) (*sharedModels.ResponseMessage, error) {
+	return m.CallWithProgress(ctx, clientID, cmd, nil)
+}
+
+// CallWithProgress is like Call but passes in_progress responses to onProgress
+// as they arrive
+func (m *Manager) CallWithProgress(ctx context.Context, clientID string, cmd *sharedModels.CommandMessage, onProgress ProgressFunc) (*sharedModels.ResponseMessage, error) {
+	client, exists := m.GetClient(clientID)
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrClientNotConnected, clientID)
+	}
+
+	if cmd.MessageID == "" {
+		callID, err := generateCallID()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate call ID: %w", err)
+		}
+		cmd.MessageID = callID
+	}
+
+	call := &pendingCall{
+		clientID: clientID,
+		progress: make(chan *sharedModels.ResponseMessage, 16),
+		result:   make(chan *sharedModels.ResponseMessage, 1),
+	}
+
+	m.callsMu.Lock()
+	if _, exists := m.calls[cmd.MessageID]; exists {
+		m.callsMu.Unlock()
+		return nil, fmt.Errorf("call %s already in flight", cmd.MessageID)
+	}
+	m.calls[cmd.MessageID] = call
+	m.callsMu.Unlock()
+
+	defer func() {
+		m.callsMu.Lock()
+		delete(m.calls, cmd.MessageID)
+		m.callsMu.Unlock()
+	}()
+
+	if err := m.SendCommand(clientID, cmd); err != nil {
+		return nil, err
+	}
+
+	for {
+		select {
+		case resp := <-call.result:
+			return resp, nil
+		case resp := <-call.progress:
+			if onProgress != nil {
+				onProgress(resp)
+			}
+		case <-client.Done():
+			return nil, fmt.Errorf("%w: %s disconnected before answering %s", ErrClientNotConnected, clientID, cmd.Action)
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for %s from client %s: %w", cmd.Action, clientID, ctx.Err())
+		}
+	}
+}
+
+// deliverCallResponse hands a response to the call waiting for it and reports
+// whether there was one
+func (m *Manager) deliverCallResponse(clientID string, resp *sharedModels.ResponseMessage) bool {
+	m.callsMu.Lock()
+	call, exists := m.calls[resp.CommandID]
+	m.callsMu.Unlock()
+	if !exists || call.clientID != clientID {
+		return false
+	}
+
+	if resp.Status == sharedModels.ResponseStatusInProgress {
+		select {
+		case call.progress <- resp:
+		default:
+			// A slow caller only misses intermediate updates
+			log.Printf("Dropping progress update for call %s from client %s", resp.CommandID, clientID)
+		}
+		return true
+	}
+
+	select {
+	case call.result <- resp:
+	default:
+		log.Printf("Ignoring duplicate response for call %s from client %s", resp.CommandID, clientID)
+	}
+	return true
+}
+
+// generateCallID generates a random command ID for a call
+func generateCallID() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
@@ -8,17 +8,44 @@ import (
 	"github.com/iriyanto1027/file-download-system/shared/auth"
 )
 
+// AuthMode controls whether clients must present a token to connect
+type AuthMode string
+
+const (
+	// AuthModeOptional validates a token only if the client sends one
+	AuthModeOptional AuthMode = "optional"
+
+	// AuthModeEnforce rejects clients without a valid token for their ID
+	AuthModeEnforce AuthMode = "enforce"
+
+	// AuthModeMTLS rejects clients without a verified client certificate;
+	// tokens are validated only if sent
+	AuthModeMTLS AuthMode = "mtls"
+)
+
+// IsValid reports whether the mode is one of the known auth modes
+func (m AuthMode) IsValid() bool {
+	return m == AuthModeOptional || m == AuthModeEnforce || m == AuthModeMTLS
+}
+
 // Handler handles WebSocket HTTP requests
 type Handler struct {
 	manager      *Manager
 	tokenManager *auth.TokenManager
+	authMode     AuthMode
 }
 
-// NewHandler creates a new WebSocket HTTP handler
-func NewHandler(manager *Manager, tokenManager *auth.TokenManager) *Handler {
+// NewHandler creates a new WebSocket HTTP handler; AuthModeEnforce needs a
+// token manager and AuthModeMTLS a TLS listener that verifies client
+// certificates
+func NewHandler(manager *Manager, tokenManager *auth.TokenManager, authMode AuthMode) *Handler {
+	if authMode == "" {
+		authMode = AuthModeOptional
+	}
 	return &Handler{
 		manager:      manager,
 		tokenManager: tokenManager,
+		authMode:     authMode,
 	}
 }
 
@@ -30,6 +57,26 @@ func (h *Handler) HandleConnect(w http.ResponseWriter, r *http.Request) {
 		clientID = r.Header.Get("X-Client-ID")
 	}
 
+	// A verified client certificate decides the client ID
+	certClientID, err := certificateClientID(r)
+	if err != nil {
+		log.Printf("Rejecting client certificate from %s: %v", r.RemoteAddr, err)
+		http.Error(w, "Invalid client certificate", http.StatusUnauthorized)
+		return
+	}
+	if certClientID != "" {
+		if clientID != "" && clientID != certClientID {
+			log.Printf("Client ID mismatch: certificate=%s, query=%s", certClientID, clientID)
+			http.Error(w, "Client ID mismatch", http.StatusUnauthorized)
+			return
+		}
+		clientID = certClientID
+	} else if h.authMode == AuthModeMTLS {
+		log.Printf("Rejecting client %s without a client certificate", clientID)
+		http.Error(w, "Client certificate required", http.StatusUnauthorized)
+		return
+	}
+
 	if clientID == "" {
 		http.Error(w, "Client ID is required", http.StatusBadRequest)
 		return
@@ -41,7 +88,7 @@ func (h *Handler) HandleConnect(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Optional: Validate JWT token if provided
+	// Validate the JWT token if provided; in enforce mode it is required
 	token := r.URL.Query().Get("token")
 	if token == "" {
 		// Try to get from Authorization header
@@ -51,8 +98,15 @@ func (h *Handler) HandleConnect(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if h.authMode == AuthModeEnforce && (token == "" || h.tokenManager == nil) {
+		log.Printf("Rejecting unauthenticated client %s", clientID)
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var claims *auth.Claims
 	if token != "" && h.tokenManager != nil {
-		claims, err := h.tokenManager.ValidateToken(token)
+		claims, err = h.tokenManager.ValidateToken(token)
 		if err != nil {
 			log.Printf("Invalid token for client %s: %v", clientID, err)
 			http.Error(w, "Invalid token", http.StatusUnauthorized)
@@ -74,8 +128,28 @@ func (h *Handler) HandleConnect(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("WebSocket connection established for client: %s", clientID)
+	switch {
+	case claims != nil:
+		log.Printf("WebSocket connection established for client: %s (token %s)", clientID, claims.ID)
+	case certClientID != "":
+		log.Printf("WebSocket connection established for client: %s (certificate)", clientID)
+	default:
+		log.Printf("WebSocket connection established for client: %s", clientID)
+	}
 
 	// Handle the client connection
-	h.manager.HandleClient(r.Context(), clientID, conn)
+	session := Session{RemoteAddr: r.RemoteAddr, Claims: claims}
+	if certClientID != "" {
+		session.Certificate = r.TLS.VerifiedChains[0][0].Subject.String()
+	}
+	h.manager.HandleClient(r.Context(), clientID, conn, session)
+}
+
+// certificateClientID returns the client ID named by the request's verified
+// client certificate, or "" if it sent none
+func certificateClientID(r *http.Request) (string, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return "", nil
+	}
+	return auth.ClientIDFromCertificate(r.TLS.VerifiedChains[0][0])
 }
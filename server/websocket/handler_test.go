@@ -0,0 +1,242 @@
+package websocket
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/iriyanto1027/file-download-system/shared/auth"
+)
+
+// testPKI is a local CA with the files the server and clients load
+type testPKI struct {
+	dir  string
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+	p := &testPKI{dir: t.TempDir()}
+	p.key = generateKey(t)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &p.key.PublicKey, p.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.cert, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, filepath.Join(p.dir, "ca.pem"), "CERTIFICATE", der)
+	return p
+}
+
+// issue writes a certificate and key signed by the CA, returning their paths
+func (p *testPKI) issue(t *testing.T, name string, template *x509.Certificate) (string, string) {
+	t.Helper()
+	key := generateKey(t)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = serial
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	der, err := x509.CreateCertificate(rand.Reader, template, p.cert, &key.PublicKey, p.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(p.dir, name+".pem")
+	keyFile := filepath.Join(p.dir, name+"-key.pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+// issueClient issues a client certificate for the common name and DNS names
+func (p *testPKI) issueClient(t *testing.T, name, commonName string, dnsNames ...string) (string, string) {
+	return p.issue(t, name, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: commonName},
+		DNSNames:    dnsNames,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+}
+
+func generateKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// startTLSServer serves /ws/connect over TLS, verifying client certificates
+// against the CA the way the server's TLS_CLIENT_CA does
+func startTLSServer(t *testing.T, p *testPKI, authMode AuthMode, tokens *auth.TokenManager) (*httptest.Server, *Manager) {
+	t.Helper()
+	certFile, keyFile := p.issue(t, "server", &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "server"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	tlsConfig, err := auth.ServerTLSConfig(certFile, keyFile, filepath.Join(p.dir, "ca.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager := NewManager(Config{}, nil)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws/connect", NewHandler(manager, tokens, authMode).HandleConnect)
+
+	server := httptest.NewUnstartedServer(mux)
+	server.TLS = tlsConfig
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server, manager
+}
+
+// dial connects with the client certificate, if any, returning the HTTP
+// status of the handshake
+func dial(t *testing.T, p *testPKI, server *httptest.Server, query, certFile, keyFile string) (*websocket.Conn, int, error) {
+	t.Helper()
+	tlsConfig, err := auth.ClientTLSConfig(certFile, keyFile, filepath.Join(p.dir, "ca.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dialer := websocket.Dialer{TLSClientConfig: tlsConfig, HandshakeTimeout: 5 * time.Second}
+	url := "wss" + strings.TrimPrefix(server.URL, "https") + "/ws/connect?" + query
+	conn, resp, err := dialer.Dial(url, nil)
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if conn != nil {
+		t.Cleanup(func() { conn.Close() })
+	}
+	return conn, status, err
+}
+
+// waitForClient waits until the manager has registered the client
+func waitForClient(t *testing.T, manager *Manager, clientID string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, exists := manager.GetClient(clientID); exists {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("client %s was not registered", clientID)
+}
+
+func TestHandleConnectClientIDFromCertificate(t *testing.T) {
+	p := newTestPKI(t)
+	server, manager := startTLSServer(t, p, AuthModeMTLS, nil)
+
+	t.Run("common name", func(t *testing.T) {
+		certFile, keyFile := p.issueClient(t, "cn", "restaurant-1")
+		if _, status, err := dial(t, p, server, "", certFile, keyFile); err != nil {
+			t.Fatalf("dial failed with status %d: %v", status, err)
+		}
+		waitForClient(t, manager, "restaurant-1")
+	})
+
+	t.Run("SAN", func(t *testing.T) {
+		certFile, keyFile := p.issueClient(t, "san", "", "restaurant-2")
+		if _, status, err := dial(t, p, server, "client_id=restaurant-2", certFile, keyFile); err != nil {
+			t.Fatalf("dial failed with status %d: %v", status, err)
+		}
+		waitForClient(t, manager, "restaurant-2")
+	})
+
+	t.Run("query names another client", func(t *testing.T) {
+		certFile, keyFile := p.issueClient(t, "query", "restaurant-3")
+		if _, status, err := dial(t, p, server, "client_id=restaurant-9", certFile, keyFile); err == nil || status != http.StatusUnauthorized {
+			t.Fatalf("status = %d, err = %v, want 401", status, err)
+		}
+	})
+}
+
+func TestHandleConnectRejectsTokenForAnotherClient(t *testing.T) {
+	p := newTestPKI(t)
+	tokens := auth.NewTokenManager("test-secret", "test")
+	server, manager := startTLSServer(t, p, AuthModeMTLS, tokens)
+	certFile, keyFile := p.issueClient(t, "client", "restaurant-1")
+
+	other, err := tokens.GenerateToken("restaurant-2", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, status, err := dial(t, p, server, "token="+other, certFile, keyFile); err == nil || status != http.StatusUnauthorized {
+		t.Fatalf("status = %d, err = %v, want 401", status, err)
+	}
+
+	own, err := tokens.GenerateToken("restaurant-1", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, status, err := dial(t, p, server, "token="+own, certFile, keyFile); err != nil {
+		t.Fatalf("dial with matching token failed with status %d: %v", status, err)
+	}
+	waitForClient(t, manager, "restaurant-1")
+}
+
+func TestHandleConnectMTLSRequiresCertificate(t *testing.T) {
+	p := newTestPKI(t)
+	server, _ := startTLSServer(t, p, AuthModeMTLS, nil)
+
+	if _, status, err := dial(t, p, server, "client_id=restaurant-1", "", ""); err == nil || status != http.StatusUnauthorized {
+		t.Fatalf("status = %d, err = %v, want 401", status, err)
+	}
+
+	// A certificate from another CA fails the TLS handshake itself
+	untrusted := newTestPKI(t)
+	certFile, keyFile := untrusted.issueClient(t, "client", "restaurant-1")
+	if _, _, err := dial(t, p, server, "", certFile, keyFile); err == nil {
+		t.Fatal("expected a certificate from an untrusted CA to be rejected")
+	}
+}
+
+func TestHandleConnectOptionalWithoutCertificate(t *testing.T) {
+	p := newTestPKI(t)
+	server, manager := startTLSServer(t, p, AuthModeOptional, nil)
+
+	if _, status, err := dial(t, p, server, "client_id=restaurant-1", "", ""); err != nil {
+		t.Fatalf("dial failed with status %d: %v", status, err)
+	}
+	waitForClient(t, manager, "restaurant-1")
+}
@@ -3,39 +3,77 @@ package websocket
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/iriyanto1027/file-download-system/server/audit"
+	"github.com/iriyanto1027/file-download-system/server/events"
 	"github.com/iriyanto1027/file-download-system/server/models"
+	"github.com/iriyanto1027/file-download-system/server/store"
+	"github.com/iriyanto1027/file-download-system/shared/auth"
 	sharedModels "github.com/iriyanto1027/file-download-system/shared/models"
 )
 
+var (
+	// ErrClientNotConnected is returned when sending to a client that is offline
+	ErrClientNotConnected = errors.New("client not connected")
+
+	// ErrUnsupportedAction is returned when a client's build cannot handle a command
+	ErrUnsupportedAction = errors.New("action not supported by client")
+)
+
 // Manager manages WebSocket connections
 type Manager struct {
 	clients        map[string]*models.ClientConnection
 	uploads        map[string]*models.UploadStatus
+	store          store.UploadStore
+	events         *events.Broker
 	mu             sync.RWMutex
 	upgrader       websocket.Upgrader
 	pingInterval   time.Duration
 	clientTimeout  time.Duration
+	connOptions    models.ConnectionOptions
 	messageHandler MessageHandler
+	calls          map[string]*pendingCall // Calls awaiting a response, by command ID
+	callsMu        sync.Mutex
+	audit          *audit.Log // Records client sessions; nothing is recorded if nil
+}
+
+// Session describes how a client authenticated when it connected
+type Session struct {
+	RemoteAddr  string
+	Claims      *auth.Claims // The client's token, if it sent one
+	Certificate string       // Subject of the client's verified certificate, if any
 }
 
 // MessageHandler handles incoming WebSocket messages
 type MessageHandler interface {
 	HandleResponse(clientID string, msg *sharedModels.ResponseMessage) error
 	HandleStatus(clientID string, msg *sharedModels.StatusMessage) error
+	HandleRequest(clientID string, msg *sharedModels.RequestMessage) error
+}
+
+// ReadyHandler is optionally implemented by a MessageHandler that wants to
+// know when a client's hello has been accepted
+type ReadyHandler interface {
+	HandleClientReady(clientID string)
 }
 
 // Config contains the manager configuration
 type Config struct {
-	PingInterval  time.Duration
-	ClientTimeout time.Duration
-	ReadLimit     int64
+	PingInterval   time.Duration
+	ClientTimeout  time.Duration
+	ReadLimit      int64
+	SendBufferSize int               // Outbound messages queued per client
+	WriteTimeout   time.Duration     // Deadline for writing a single message
+	Store          store.UploadStore // Persists uploads; in memory if nil
+	Events         *events.Broker    // Receives upload state changes; created if nil
 }
 
 // NewManager creates a new WebSocket manager
@@ -49,44 +87,70 @@ func NewManager(cfg Config, handler MessageHandler) *Manager {
 	if cfg.ReadLimit == 0 {
 		cfg.ReadLimit = 1024 * 1024 // 1MB
 	}
+	if cfg.SendBufferSize == 0 {
+		cfg.SendBufferSize = 64
+	}
+	if cfg.WriteTimeout == 0 {
+		cfg.WriteTimeout = 10 * time.Second
+	}
+	if cfg.Store == nil {
+		cfg.Store = store.NewMemoryStore()
+	}
+	if cfg.Events == nil {
+		cfg.Events = events.NewBroker(events.Config{})
+	}
 
 	return &Manager{
 		clients: make(map[string]*models.ClientConnection),
 		uploads: make(map[string]*models.UploadStatus),
+		store:   cfg.Store,
+		events:  cfg.Events,
+		calls:   make(map[string]*pendingCall),
 		upgrader: websocket.Upgrader{
-			ReadBufferSize:  1024,
-			WriteBufferSize: 1024,
+			ReadBufferSize:    1024,
+			WriteBufferSize:   1024,
+			EnableCompression: true,
 			CheckOrigin: func(r *http.Request) bool {
 				// TODO: Implement proper origin checking in production
 				return true
 			},
 		},
-		pingInterval:   cfg.PingInterval,
-		clientTimeout:  cfg.ClientTimeout,
+		pingInterval:  cfg.PingInterval,
+		clientTimeout: cfg.ClientTimeout,
+		connOptions: models.ConnectionOptions{
+			SendBufferSize: cfg.SendBufferSize,
+			WriteTimeout:   cfg.WriteTimeout,
+		},
 		messageHandler: handler,
 	}
 }
 
+// Events returns the broker that upload events are published to
+func (m *Manager) Events() *events.Broker {
+	return m.events
+}
+
 // SetMessageHandler sets the message handler
 func (m *Manager) SetMessageHandler(handler MessageHandler) {
 	m.messageHandler = handler
 }
 
 // RegisterClient registers a new client connection
-func (m *Manager) RegisterClient(clientID string, conn *websocket.Conn) {
+func (m *Manager) RegisterClient(clientID string, conn *websocket.Conn) *models.ClientConnection {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	// Close existing connection if any
 	if existingConn, exists := m.clients[clientID]; exists {
-		existingConn.Connection.Close()
+		existingConn.Close()
 		log.Printf("Closed existing connection for client %s", clientID)
 	}
 
-	client := models.NewClientConnection(clientID, conn)
+	client := models.NewClientConnection(clientID, conn, m.connOptions)
 	m.clients[clientID] = client
 
 	log.Printf("Client registered: %s", clientID)
+	return client
 }
 
 // UnregisterClient removes a client connection
@@ -95,12 +159,25 @@ func (m *Manager) UnregisterClient(clientID string) {
 	defer m.mu.Unlock()
 
 	if client, exists := m.clients[clientID]; exists {
-		client.Connection.Close()
+		client.Close()
 		delete(m.clients, clientID)
 		log.Printf("Client unregistered: %s", clientID)
 	}
 }
 
+// removeClient closes a connection and unregisters it unless the client has
+// already reconnected on a newer connection
+func (m *Manager) removeClient(client *models.ClientConnection) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	client.Close()
+	if current, exists := m.clients[client.ClientID]; exists && current == client {
+		delete(m.clients, client.ClientID)
+		log.Printf("Client unregistered: %s", client.ClientID)
+	}
+}
+
 // GetClient retrieves a client connection
 func (m *Manager) GetClient(clientID string) (*models.ClientConnection, bool) {
 	m.mu.RLock()
@@ -121,6 +198,46 @@ func (m *Manager) GetAllClients() []string {
 	return clientIDs
 }
 
+// FindClientsByLabels returns the connected clients carrying every given
+// label, sorted by ID
+func (m *Manager) FindClientsByLabels(labels map[string]string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	clientIDs := make([]string, 0)
+	for clientID, client := range m.clients {
+		capabilities, _ := client.GetCapabilities()
+		if capabilities.HasLabels(labels) {
+			clientIDs = append(clientIDs, clientID)
+		}
+	}
+	sort.Strings(clientIDs)
+	return clientIDs
+}
+
+// DisconnectRevoked closes every session whose token has been revoked and
+// returns the disconnected client IDs; sessions without a token are kept
+func (m *Manager) DisconnectRevoked(checker auth.RevocationChecker) []string {
+	m.mu.RLock()
+	var revoked []*models.ClientConnection
+	for _, client := range m.clients {
+		if claims := client.GetToken(); claims != nil && checker.IsRevoked(claims) {
+			revoked = append(revoked, client)
+		}
+	}
+	m.mu.RUnlock()
+
+	clientIDs := make([]string, 0, len(revoked))
+	for _, client := range revoked {
+		// The read loop sees the closed connection and unregisters the client
+		client.Close()
+		clientIDs = append(clientIDs, client.ClientID)
+		log.Printf("🚫 Disconnected client %s, its token was revoked", client.ClientID)
+	}
+	sort.Strings(clientIDs)
+	return clientIDs
+}
+
 // IsClientConnected checks if a client is connected
 func (m *Manager) IsClientConnected(clientID string) bool {
 	m.mu.RLock()
@@ -129,19 +246,41 @@ func (m *Manager) IsClientConnected(clientID string) bool {
 	return exists
 }
 
+// CheckSupport returns ErrUnsupportedAction if the client cannot handle any
+// of the given actions
+func (m *Manager) CheckSupport(clientID string, actions ...sharedModels.CommandAction) error {
+	client, exists := m.GetClient(clientID)
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrClientNotConnected, clientID)
+	}
+
+	capabilities, _ := client.GetCapabilities()
+	for _, action := range actions {
+		if !capabilities.Supports(action) {
+			return fmt.Errorf("%w: client %s (protocol v%d, build %q) cannot handle %s",
+				ErrUnsupportedAction, clientID, capabilities.ProtocolVersion, capabilities.ClientBuild, action)
+		}
+	}
+	return nil
+}
+
 // SendCommand sends a command to a specific client
 func (m *Manager) SendCommand(clientID string, cmd *sharedModels.CommandMessage) error {
 	client, exists := m.GetClient(clientID)
 	if !exists {
-		return fmt.Errorf("client %s not connected", clientID)
+		return fmt.Errorf("%w: %s", ErrClientNotConnected, clientID)
+	}
+
+	if err := m.CheckSupport(clientID, cmd.Action); err != nil {
+		return err
 	}
 
 	// Set timestamp and message type
 	cmd.Timestamp = time.Now()
 	cmd.Type = sharedModels.MessageTypeCommand
 
-	// Send message
-	if err := client.Connection.WriteJSON(cmd); err != nil {
+	// Queue message for the connection's writer
+	if err := client.Send(cmd); err != nil {
 		return fmt.Errorf("failed to send command: %w", err)
 	}
 
@@ -149,11 +288,31 @@ func (m *Manager) SendCommand(clientID string, cmd *sharedModels.CommandMessage)
 	return nil
 }
 
+// SendResponse sends a reply to a request made by a specific client
+func (m *Manager) SendResponse(clientID string, resp *sharedModels.ResponseMessage) error {
+	client, exists := m.GetClient(clientID)
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrClientNotConnected, clientID)
+	}
+
+	// Set timestamp and message type
+	resp.Timestamp = time.Now()
+	resp.Type = sharedModels.MessageTypeResponse
+
+	// Queue message for the connection's writer
+	if err := client.Send(resp); err != nil {
+		return fmt.Errorf("failed to send response: %w", err)
+	}
+
+	client.UpdateActivity()
+	return nil
+}
+
 // SendPing sends a ping message to a client
 func (m *Manager) SendPing(clientID string) error {
 	client, exists := m.GetClient(clientID)
 	if !exists {
-		return fmt.Errorf("client %s not connected", clientID)
+		return fmt.Errorf("%w: %s", ErrClientNotConnected, clientID)
 	}
 
 	ping := &sharedModels.PingMessage{
@@ -163,26 +322,48 @@ func (m *Manager) SendPing(clientID string) error {
 		},
 	}
 
-	if err := client.Connection.WriteJSON(ping); err != nil {
+	if err := client.Send(ping); err != nil {
 		return fmt.Errorf("failed to send ping: %w", err)
 	}
 
 	return nil
 }
 
+// SetAuditLog makes the manager record client sessions in the audit log
+func (m *Manager) SetAuditLog(auditLog *audit.Log) {
+	m.audit = auditLog
+}
+
 // HandleClient handles a client WebSocket connection
-func (m *Manager) HandleClient(ctx context.Context, clientID string, conn *websocket.Conn) {
-	m.RegisterClient(clientID, conn)
-	defer m.UnregisterClient(clientID)
+func (m *Manager) HandleClient(ctx context.Context, clientID string, conn *websocket.Conn, session Session) {
+	client := m.RegisterClient(clientID, conn)
+	client.SetToken(session.Claims)
+	defer m.removeClient(client)
+
+	entry := models.AuditEntry{
+		Action:      models.AuditActionClientConnected,
+		ClientID:    clientID,
+		RemoteAddr:  session.RemoteAddr,
+		Certificate: session.Certificate,
+	}
+	if session.Claims != nil {
+		entry.TokenSubject = session.Claims.Subject
+		entry.TokenID = session.Claims.ID
+	}
+	m.audit.Record(entry)
+	defer func() {
+		entry.Action = models.AuditActionClientDisconnected
+		entry.Time = time.Time{}
+		entry.Details = map[string]string{"duration": time.Since(client.ConnectedAt).Round(time.Second).String()}
+		m.audit.Record(entry)
+	}()
 
 	// Set read limit and deadline
 	conn.SetReadLimit(1024 * 1024) // 1MB
 	conn.SetReadDeadline(time.Now().Add(m.clientTimeout))
 	conn.SetPongHandler(func(string) error {
 		conn.SetReadDeadline(time.Now().Add(m.clientTimeout))
-		if client, exists := m.GetClient(clientID); exists {
-			client.UpdateHeartbeat()
-		}
+		client.UpdateHeartbeat()
 		return nil
 	})
 
@@ -232,6 +413,10 @@ func (m *Manager) handleMessage(clientID string, message []byte) error {
 		if err := json.Unmarshal(message, &responseMsg); err != nil {
 			return fmt.Errorf("failed to parse response message: %w", err)
 		}
+		// Responses to a Call go only to the waiting caller
+		if m.deliverCallResponse(clientID, &responseMsg) {
+			return nil
+		}
 		if m.messageHandler != nil {
 			return m.messageHandler.HandleResponse(clientID, &responseMsg)
 		}
@@ -245,6 +430,22 @@ func (m *Manager) handleMessage(clientID string, message []byte) error {
 			return m.messageHandler.HandleStatus(clientID, &statusMsg)
 		}
 
+	case sharedModels.MessageTypeRequest:
+		var requestMsg sharedModels.RequestMessage
+		if err := json.Unmarshal(message, &requestMsg); err != nil {
+			return fmt.Errorf("failed to parse request message: %w", err)
+		}
+		if m.messageHandler != nil {
+			return m.messageHandler.HandleRequest(clientID, &requestMsg)
+		}
+
+	case sharedModels.MessageTypeHello:
+		var helloMsg sharedModels.HelloMessage
+		if err := json.Unmarshal(message, &helloMsg); err != nil {
+			return fmt.Errorf("failed to parse hello message: %w", err)
+		}
+		return m.handleHello(clientID, &helloMsg)
+
 	case sharedModels.MessageTypePong:
 		// Pong received, already handled by SetPongHandler
 		return nil
@@ -256,6 +457,53 @@ func (m *Manager) handleMessage(clientID string, message []byte) error {
 	return nil
 }
 
+// handleHello records the client's capabilities and answers with the
+// negotiated protocol version; clients that are too old are disconnected
+func (m *Manager) handleHello(clientID string, hello *sharedModels.HelloMessage) error {
+	client, exists := m.GetClient(clientID)
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrClientNotConnected, clientID)
+	}
+
+	ack := &sharedModels.HelloAckMessage{
+		WebSocketMessage: sharedModels.WebSocketMessage{
+			Type:      sharedModels.MessageTypeHelloAck,
+			Timestamp: time.Now(),
+			MessageID: hello.MessageID,
+		},
+		ProtocolVersion: min(hello.ProtocolVersion, sharedModels.ProtocolVersion),
+		Accepted:        true,
+	}
+
+	if hello.ProtocolVersion < sharedModels.MinProtocolVersion {
+		ack.Accepted = false
+		ack.Error = fmt.Sprintf("protocol version %d is not supported, minimum is %d",
+			hello.ProtocolVersion, sharedModels.MinProtocolVersion)
+		log.Printf("Rejecting client %s: %s", clientID, ack.Error)
+		if err := client.SendAndClose(ack); err != nil {
+			client.Close()
+			return fmt.Errorf("failed to send hello ack: %w", err)
+		}
+		return nil
+	}
+
+	client.SetCapabilities(hello.ClientCapabilities)
+	// Only compress once the client's hello says it can handle it
+	client.EnableWriteCompression(hello.Compression)
+	log.Printf("Client %s says hello: protocol v%d, build %q, actions %v, concurrency %d, compression %v",
+		clientID, hello.ProtocolVersion, hello.ClientBuild, hello.SupportedActions, hello.MaxConcurrency, hello.Compression)
+
+	if err := client.Send(ack); err != nil {
+		return fmt.Errorf("failed to send hello ack: %w", err)
+	}
+
+	// Run outside the read loop so the hook can talk to the client
+	if handler, ok := m.messageHandler.(ReadyHandler); ok {
+		go handler.HandleClientReady(clientID)
+	}
+	return nil
+}
+
 // pingRoutine sends periodic ping messages to keep the connection alive
 func (m *Manager) pingRoutine(ctx context.Context, clientID string, done chan struct{}) {
 	ticker := time.NewTicker(m.pingInterval)
@@ -282,6 +530,72 @@ func (m *Manager) RegisterUpload(upload *models.UploadStatus) {
 	defer m.mu.Unlock()
 	m.uploads[upload.UploadID] = upload
 	log.Printf("Upload registered: %s for client %s", upload.UploadID, upload.ClientID)
+
+	upload.OnTransition(m.publishTransition)
+	m.publishTransition(upload.UploadID, upload.ClientID, models.StateTransition{
+		To: upload.GetStatus(),
+		At: upload.StartTime,
+	})
+
+	if err := m.store.Save(upload.Record()); err != nil {
+		log.Printf("Failed to persist upload %s: %v", upload.UploadID, err)
+	}
+}
+
+// SaveUpload persists the current state of an upload; call it after every
+// change that must survive a restart
+func (m *Manager) SaveUpload(upload *models.UploadStatus) {
+	if err := m.store.Save(upload.Record()); err != nil {
+		log.Printf("Failed to persist upload %s: %v", upload.UploadID, err)
+	}
+}
+
+// progressSaveInterval limits how often progress reports are persisted;
+// state changes are saved right away and parts are reconciled with S3 after
+// a restart, so only the history shown between saves is lost
+const progressSaveInterval = 10 * time.Second
+
+// SaveUploadProgress persists an upload after a progress report, at most
+// once per progressSaveInterval
+func (m *Manager) SaveUploadProgress(upload *models.UploadStatus) {
+	if upload.ProgressSaveDue(progressSaveInterval) {
+		m.SaveUpload(upload)
+	}
+}
+
+// LoadUploads restores all persisted uploads into memory and returns them
+func (m *Manager) LoadUploads() ([]*models.UploadStatus, error) {
+	records, err := m.store.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load uploads: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	uploads := make([]*models.UploadStatus, 0, len(records))
+	for _, record := range records {
+		upload := models.NewUploadStatusFromRecord(record)
+		upload.OnTransition(m.publishTransition)
+		m.uploads[upload.UploadID] = upload
+		uploads = append(uploads, upload)
+	}
+	return uploads, nil
+}
+
+// publishTransition publishes an upload's change of state
+func (m *Manager) publishTransition(uploadID, clientID string, transition models.StateTransition) {
+	m.events.Publish(events.Event{
+		Type:     events.EventTypeState,
+		UploadID: uploadID,
+		ClientID: clientID,
+		Time:     transition.At,
+		State: &events.StateChange{
+			From:   transition.From,
+			To:     transition.To,
+			Reason: transition.Reason,
+		},
+	})
 }
 
 // GetUpload retrieves an upload status
@@ -292,6 +606,18 @@ func (m *Manager) GetUpload(uploadID string) (*models.UploadStatus, bool) {
 	return upload, exists
 }
 
+// GetAllUploads returns every known upload
+func (m *Manager) GetAllUploads() []*models.UploadStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	uploads := make([]*models.UploadStatus, 0, len(m.uploads))
+	for _, upload := range m.uploads {
+		uploads = append(uploads, upload)
+	}
+	return uploads
+}
+
 // GetClientUploads returns all uploads for a specific client
 func (m *Manager) GetClientUploads(clientID string) []*models.UploadStatus {
 	m.mu.RLock()
@@ -306,6 +632,24 @@ func (m *Manager) GetClientUploads(clientID string) []*models.UploadStatus {
 	return uploads
 }
 
+// QueuedUploads returns the uploads waiting for a client to connect, oldest
+// first
+func (m *Manager) QueuedUploads(clientID string) []*models.UploadStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	uploads := make([]*models.UploadStatus, 0)
+	for _, upload := range m.uploads {
+		if upload.ClientID == clientID && upload.GetStatus() == models.UploadStateQueued {
+			uploads = append(uploads, upload)
+		}
+	}
+	sort.Slice(uploads, func(i, j int) bool {
+		return uploads[i].StartTime.Before(uploads[j].StartTime)
+	})
+	return uploads
+}
+
 // GetClientStatus returns the status of a client
 func (m *Manager) GetClientStatus(clientID string) *models.ClientStatus {
 	m.mu.RLock()
@@ -322,6 +666,15 @@ func (m *Manager) GetClientStatus(clientID string) *models.ClientStatus {
 		status.ConnectedAt = &client.ConnectedAt
 		status.LastHeartbeat = &client.LastHeartbeat
 		status.LastActivity = &client.LastActivity
+		capabilities, helloReceived := client.GetCapabilities()
+		status.Capabilities = &capabilities
+		status.HelloReceived = helloReceived
+		if claims := client.GetToken(); claims != nil {
+			status.TokenID = claims.ID
+			if claims.ExpiresAt != nil {
+				status.TokenExpiresAt = &claims.ExpiresAt.Time
+			}
+		}
 	}
 
 	// Count uploads
@@ -332,7 +685,7 @@ func (m *Manager) GetClientStatus(clientID string) *models.ClientStatus {
 		}
 
 		status.TotalUploads++
-		switch upload.Status {
+		switch upload.GetStatus() {
 		case models.UploadStateCompleted:
 			status.SuccessUploads++
 		case models.UploadStateFailed:
@@ -344,19 +697,20 @@ func (m *Manager) GetClientStatus(clientID string) *models.ClientStatus {
 
 	// Set current upload info
 	if currentUpload != nil {
+		record := currentUpload.Record()
 		status.CurrentUpload = &models.UploadInfo{
-			UploadID:       currentUpload.UploadID,
-			FilePath:       currentUpload.FilePath,
-			S3Key:          currentUpload.S3Key,
-			FileSize:       currentUpload.FileSize,
-			Status:         currentUpload.Status,
+			UploadID:       record.UploadID,
+			FilePath:       record.FilePath,
+			S3Key:          record.S3Key,
+			FileSize:       record.FileSize,
+			Status:         record.Status,
 			Progress:       currentUpload.GetProgress(),
-			CompletedParts: currentUpload.CompletedParts,
-			TotalParts:     currentUpload.TotalParts,
-			BytesUploaded:  currentUpload.BytesUploaded,
-			StartTime:      currentUpload.StartTime,
-			EndTime:        currentUpload.EndTime,
-			Error:          currentUpload.Error,
+			CompletedParts: record.CompletedParts,
+			TotalParts:     record.TotalParts,
+			BytesUploaded:  record.BytesUploaded,
+			StartTime:      record.StartTime,
+			EndTime:        record.EndTime,
+			Error:          record.Error,
 		}
 	}
 
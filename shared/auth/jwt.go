@@ -4,6 +4,8 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
+	"fmt"
+	"sort"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -13,8 +15,15 @@ var (
 	ErrInvalidToken     = errors.New("invalid token")
 	ErrExpiredToken     = errors.New("token has expired")
 	ErrInvalidSignature = errors.New("invalid token signature")
+	ErrRevokedToken     = errors.New("token has been revoked")
 )
 
+// RevocationChecker reports whether a token has been revoked, by its ID or
+// by a revocation of every token issued to its client
+type RevocationChecker interface {
+	IsRevoked(claims *Claims) bool
+}
+
 // Claims represents the JWT claims
 type Claims struct {
 	ClientID string `json:"client_id"`
@@ -24,11 +33,15 @@ type Claims struct {
 
 // TokenManager handles JWT token operations
 type TokenManager struct {
-	secretKey []byte
-	issuer    string
+	secretKey  []byte          // HS256 secret; nil when only asymmetric keys are used
+	signingKey *Key            // Signs new tokens instead of the secret, if set
+	keys       map[string]*Key // Verification keys by kid
+	issuer     string
+	revoked    RevocationChecker
 }
 
-// NewTokenManager creates a new token manager
+// NewTokenManager creates a new token manager that signs and verifies
+// tokens with an HS256 shared secret
 func NewTokenManager(secretKey, issuer string) *TokenManager {
 	return &TokenManager{
 		secretKey: []byte(secretKey),
@@ -36,12 +49,73 @@ func NewTokenManager(secretKey, issuer string) *TokenManager {
 	}
 }
 
+// NewKeyTokenManager creates a token manager that signs tokens with an RS256
+// or EdDSA key and verifies tokens signed by that key or any of the
+// verification keys, selected by kid. signingKey may be nil on replicas that
+// only verify. A non-empty secretKey keeps verifying HS256 tokens issued
+// before the switch to keys, but is never used to sign.
+func NewKeyTokenManager(issuer string, signingKey *Key, verificationKeys []*Key, secretKey string) (*TokenManager, error) {
+	tm := &TokenManager{
+		signingKey: signingKey,
+		keys:       make(map[string]*Key),
+		issuer:     issuer,
+	}
+	if secretKey != "" {
+		tm.secretKey = []byte(secretKey)
+	}
+
+	if signingKey != nil {
+		if !signingKey.CanSign() {
+			return nil, fmt.Errorf("%w: signing key %s has no private key", ErrUnsupportedKey, signingKey.ID)
+		}
+		tm.keys[signingKey.ID] = signingKey
+	}
+	for _, key := range verificationKeys {
+		if _, exists := tm.keys[key.ID]; !exists {
+			tm.keys[key.ID] = key
+		}
+	}
+	if len(tm.keys) == 0 {
+		return nil, errors.New("at least one signing or verification key is required")
+	}
+
+	return tm, nil
+}
+
+// SetRevocationChecker makes validation reject revoked tokens
+func (tm *TokenManager) SetRevocationChecker(checker RevocationChecker) {
+	tm.revoked = checker
+}
+
+// JWKS returns the public verification keys; HS256 secrets are never
+// published, so it is empty for secret-only managers
+func (tm *TokenManager) JWKS() JWKS {
+	ids := make([]string, 0, len(tm.keys))
+	for id := range tm.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	jwks := JWKS{Keys: make([]JWK, 0, len(ids))}
+	for _, id := range ids {
+		jwks.Keys = append(jwks.Keys, tm.keys[id].JWK())
+	}
+	return jwks
+}
+
 // GenerateToken creates a new JWT token for a client
 func (tm *TokenManager) GenerateToken(clientID string, expiresIn time.Duration) (string, error) {
+	// The token ID (jti) lets a single token be revoked
+	tokenID, err := GenerateRandomToken(16)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token ID: %w", err)
+	}
+
 	now := time.Now()
 	claims := &Claims{
 		ClientID: clientID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
 			Issuer:    tm.issuer,
 			Subject:   clientID,
 			IssuedAt:  jwt.NewNumericDate(now),
@@ -49,6 +123,19 @@ func (tm *TokenManager) GenerateToken(clientID string, expiresIn time.Duration)
 			NotBefore: jwt.NewNumericDate(now),
 		},
 	}
+	return tm.sign(claims)
+}
+
+// sign signs claims with the signing key, or the HS256 secret without one
+func (tm *TokenManager) sign(claims *Claims) (string, error) {
+	if tm.signingKey != nil {
+		token := jwt.NewWithClaims(tm.signingKey.signingMethod(), claims)
+		token.Header["kid"] = tm.signingKey.ID
+		return token.SignedString(tm.signingKey.privateKey)
+	}
+	if tm.secretKey == nil {
+		return "", ErrNoSigningKey
+	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(tm.secretKey)
@@ -56,13 +143,13 @@ func (tm *TokenManager) GenerateToken(clientID string, expiresIn time.Duration)
 
 // ValidateToken validates a JWT token and returns the claims
 func (tm *TokenManager) ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		// Verify signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidSignature
-		}
-		return tm.secretKey, nil
-	})
+	return tm.parse(tokenString)
+}
+
+// parse verifies a token's signature, that it hasn't been revoked and its
+// claims
+func (tm *TokenManager) parse(tokenString string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, tm.verificationKey)
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
@@ -75,23 +162,61 @@ func (tm *TokenManager) ValidateToken(tokenString string) (*Claims, error) {
 	if !ok || !token.Valid {
 		return nil, ErrInvalidToken
 	}
+	if tm.revoked != nil && tm.revoked.IsRevoked(claims) {
+		return nil, ErrRevokedToken
+	}
 
 	return claims, nil
 }
 
-// RefreshToken creates a new token with extended expiration
+// verificationKey picks the key that verifies a token: the key named by its
+// kid, whose algorithm must match the token's, or the HS256 secret
+func (tm *TokenManager) verificationKey(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if tm.secretKey == nil || token.Method != jwt.SigningMethodHS256 {
+			return nil, ErrInvalidSignature
+		}
+		return tm.secretKey, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, exists := tm.keys[kid]
+	if !exists {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, ErrInvalidSignature
+	}
+	return key.publicKey, nil
+}
+
+// RefreshToken creates a new token with extended expiration; the old token
+// must still be valid, so a leaked token can't be renewed once it expired
 func (tm *TokenManager) RefreshToken(oldToken string, expiresIn time.Duration) (string, error) {
 	claims, err := tm.ValidateToken(oldToken)
 	if err != nil {
-		// Allow refresh even if expired, but not if invalid
-		if !errors.Is(err, ErrExpiredToken) {
-			return "", err
-		}
+		return "", err
 	}
 
 	return tm.GenerateToken(claims.ClientID, expiresIn)
 }
 
+// TokenLifetime reads when a token was issued and when it expires without
+// verifying it, so a client can schedule its refresh
+func TokenLifetime(tokenString string) (issuedAt, expiresAt time.Time, err error) {
+	var claims Claims
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, &claims); err != nil {
+		return time.Time{}, time.Time{}, ErrInvalidToken
+	}
+	if claims.ExpiresAt == nil {
+		return time.Time{}, time.Time{}, ErrInvalidToken
+	}
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+	return issuedAt, claims.ExpiresAt.Time, nil
+}
+
 // GenerateRandomToken generates a random token for simple authentication
 func GenerateRandomToken(length int) (string, error) {
 	bytes := make([]byte, length)
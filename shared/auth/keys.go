@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnsupportedKey = errors.New("unsupported key")
+	ErrUnknownKey     = errors.New("unknown signing key")
+	ErrNoSigningKey   = errors.New("no signing key configured")
+)
+
+// minRSAKeyBits is the smallest RSA key accepted for RS256
+const minRSAKeyBits = 2048
+
+// Key is an asymmetric key used to sign or verify tokens
+type Key struct {
+	ID         string // The token header's kid, the key's RFC 7638 thumbprint
+	Algorithm  string // RS256 or EdDSA
+	publicKey  crypto.PublicKey
+	privateKey crypto.Signer // Nil for verification-only keys
+}
+
+// CanSign reports whether the key holds a private key
+func (k *Key) CanSign() bool {
+	return k.privateKey != nil
+}
+
+// LoadKeyFile reads a PEM-encoded RSA or Ed25519 key; private keys can sign
+// and verify, public keys can only verify
+func LoadKeyFile(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key %s: %w", path, err)
+	}
+	key, err := ParseKeyPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load key %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// ParseKeyPEM parses a PKCS#1 or PKCS#8 private key, or a PKIX public key
+func ParseKeyPEM(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%w: no PEM block found", ErrUnsupportedKey)
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%w: PEM block %q", ErrUnsupportedKey, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedKey, err)
+	}
+
+	return newKey(parsed)
+}
+
+// newKey wraps a parsed RSA or Ed25519 key
+func newKey(parsed interface{}) (*Key, error) {
+	key := &Key{}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.privateKey, key.publicKey = k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.publicKey = k
+	case ed25519.PrivateKey:
+		key.privateKey, key.publicKey = k, k.Public()
+	case ed25519.PublicKey:
+		key.publicKey = k
+	default:
+		return nil, fmt.Errorf("%w: %T, expected RSA or Ed25519", ErrUnsupportedKey, parsed)
+	}
+
+	switch pub := key.publicKey.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("%w: RSA keys need at least %d bits", ErrUnsupportedKey, minRSAKeyBits)
+		}
+		key.Algorithm = jwt.SigningMethodRS256.Alg()
+	case ed25519.PublicKey:
+		key.Algorithm = jwt.SigningMethodEdDSA.Alg()
+	}
+
+	key.ID = key.thumbprint()
+	return key, nil
+}
+
+// signingMethod returns the JWT signing method for the key's algorithm
+func (k *Key) signingMethod() jwt.SigningMethod {
+	if k.Algorithm == jwt.SigningMethodEdDSA.Alg() {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// JWK is a public key in JSON Web Key form
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`   // RSA modulus
+	E         string `json:"e,omitempty"`   // RSA exponent
+	Curve     string `json:"crv,omitempty"` // OKP curve
+	X         string `json:"x,omitempty"`   // OKP public key
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK returns the public half of the key
+func (k *Key) JWK() JWK {
+	jwk := JWK{KeyID: k.ID, Use: "sig", Algorithm: k.Algorithm}
+	switch pub := k.publicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	}
+	return jwk
+}
+
+// thumbprint computes the RFC 7638 thumbprint of the public key, so every
+// replica derives the same kid from the same key
+func (k *Key) thumbprint() string {
+	jwk := k.JWK()
+
+	// The members must be in lexicographic order with no whitespace
+	var canonical interface{}
+	if jwk.KeyType == "RSA" {
+		canonical = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.KeyType, jwk.N}
+	} else {
+		canonical = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Curve, jwk.KeyType, jwk.X}
+	}
+
+	data, _ := json.Marshal(canonical)
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrInvalidRole is returned for a role that isn't viewer, operator or admin
+var ErrInvalidRole = errors.New("invalid role")
+
+// Role is an operator's permission level; each role includes the
+// permissions of the roles below it
+type Role string
+
+const (
+	// RoleViewer reads clients, uploads, batches and schedules
+	RoleViewer Role = "viewer"
+
+	// RoleOperator also triggers and cancels downloads and manages schedules
+	RoleOperator Role = "operator"
+
+	// RoleAdmin also manages webhooks, client tokens and revocations
+	RoleAdmin Role = "admin"
+)
+
+// roleLevels orders the roles; unknown roles have level 0 and allow nothing
+var roleLevels = map[Role]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+// IsValid reports whether the role is one of the known roles
+func (r Role) IsValid() bool {
+	return roleLevels[r] > 0
+}
+
+// Allows reports whether the role grants the permissions of required
+func (r Role) Allows(required Role) bool {
+	return r.IsValid() && roleLevels[r] >= roleLevels[required]
+}
+
+// GenerateOperatorToken creates a JWT for an operator of the HTTP API. It
+// has no client ID, so it can't connect as a client.
+func (tm *TokenManager) GenerateOperatorToken(subject string, role Role, expiresIn time.Duration) (string, error) {
+	if !role.IsValid() {
+		return "", fmt.Errorf("%w: %q", ErrInvalidRole, role)
+	}
+
+	tokenID, err := GenerateRandomToken(16)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token ID: %w", err)
+	}
+
+	now := time.Now()
+	return tm.sign(&Claims{
+		Role: string(role),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			Issuer:    tm.issuer,
+			Subject:   subject,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	})
+}
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCA is a throwaway certificate authority for issuing client certificates
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key}
+}
+
+// issue signs a client certificate with the given subject common name and
+// DNS names, and verifies it against the CA as the server would
+func (ca *testCA) issue(t *testing.T, commonName string, dnsNames ...string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	if _, err := cert.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}); err != nil {
+		t.Fatalf("issued certificate does not verify: %v", err)
+	}
+	return cert
+}
+
+func TestClientIDFromCertificate(t *testing.T) {
+	ca := newTestCA(t)
+
+	tests := []struct {
+		name       string
+		commonName string
+		dnsNames   []string
+		want       string
+		wantErr    error
+	}{
+		{name: "common name", commonName: "restaurant-1", want: "restaurant-1"},
+		{name: "common name wins over SAN", commonName: "restaurant-1", dnsNames: []string{"restaurant-2"}, want: "restaurant-1"},
+		{name: "single SAN", dnsNames: []string{"restaurant-3"}, want: "restaurant-3"},
+		{name: "several SANs", dnsNames: []string{"restaurant-3", "restaurant-4"}, wantErr: ErrNoClientID},
+		{name: "no name", wantErr: ErrNoClientID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ClientIDFromCertificate(ca.issue(t, tt.commonName, tt.dnsNames...))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("client ID = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("invalid client ID", func(t *testing.T) {
+		if _, err := ClientIDFromCertificate(ca.issue(t, strings.Repeat("a", 129))); err == nil {
+			t.Error("expected an error for an over-long client ID")
+		}
+	})
+}
@@ -0,0 +1,289 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+var (
+	ErrUnknownAction    = errors.New("unknown action")
+	ErrEmptyPayload     = errors.New("payload is empty")
+	ErrPayloadType      = errors.New("payload type does not match action")
+	ErrInvalidPayload   = errors.New("invalid payload")
+	ErrUnexpectedAction = errors.New("action carries no payload")
+)
+
+// Validator is implemented by payloads that can check their own fields
+type Validator interface {
+	Validate() error
+}
+
+// payloadTypes maps each action to the payload sent with the command or
+// request; a nil entry means the action carries no payload
+var payloadTypes = map[CommandAction]reflect.Type{
+	CommandActionDownloadFile:    reflect.TypeOf(DownloadFilePayload{}),
+	CommandActionCancelUpload:    reflect.TypeOf(CancelUploadPayload{}),
+	CommandActionHealthCheck:     nil,
+	CommandActionStatFile:        reflect.TypeOf(StatFilePayload{}),
+	CommandActionRequestPartURLs: reflect.TypeOf(RequestPartURLsPayload{}),
+	CommandActionResumeUpload:    reflect.TypeOf(ResumeUploadPayload{}),
+	CommandActionRefreshToken:    reflect.TypeOf(RefreshTokenPayload{}),
+}
+
+// responsePayloadTypes maps each action to the payload of its response
+var responsePayloadTypes = map[CommandAction]reflect.Type{
+	CommandActionDownloadFile:    reflect.TypeOf(DownloadFileResponse{}),
+	CommandActionCancelUpload:    reflect.TypeOf(CancelUploadPayload{}),
+	CommandActionHealthCheck:     reflect.TypeOf(HealthCheckResponse{}),
+	CommandActionStatFile:        reflect.TypeOf(StatFileResponse{}),
+	CommandActionRequestPartURLs: reflect.TypeOf(PartURLsResponse{}),
+	CommandActionResumeUpload:    reflect.TypeOf(ResumeUploadResponse{}),
+	CommandActionRefreshToken:    reflect.TypeOf(RefreshTokenResponse{}),
+}
+
+// NewCommandMessage builds a command with its payload encoded and validated
+func NewCommandMessage(action CommandAction, messageID string, payload interface{}) (*CommandMessage, error) {
+	raw, err := encodePayload(payloadTypes, action, payload)
+	if err != nil {
+		return nil, err
+	}
+	return &CommandMessage{
+		WebSocketMessage: WebSocketMessage{
+			Type:      MessageTypeCommand,
+			Timestamp: time.Now(),
+			MessageID: messageID,
+		},
+		Action:  action,
+		Payload: raw,
+	}, nil
+}
+
+// NewRequestMessage builds a request with its payload encoded and validated
+func NewRequestMessage(action CommandAction, messageID string, payload interface{}) (*RequestMessage, error) {
+	raw, err := encodePayload(payloadTypes, action, payload)
+	if err != nil {
+		return nil, err
+	}
+	return &RequestMessage{
+		WebSocketMessage: WebSocketMessage{
+			Type:      MessageTypeRequest,
+			Timestamp: time.Now(),
+			MessageID: messageID,
+		},
+		Action:  action,
+		Payload: raw,
+	}, nil
+}
+
+// NewResponseMessage builds a response with its payload encoded and
+// validated; payload may be nil, e.g. for errors
+func NewResponseMessage(status ResponseStatus, commandID string, action CommandAction, payload interface{}, errMsg string) (*ResponseMessage, error) {
+	raw, err := encodePayload(responsePayloadTypes, action, payload)
+	if err != nil {
+		return nil, err
+	}
+	return &ResponseMessage{
+		WebSocketMessage: WebSocketMessage{
+			Type:      MessageTypeResponse,
+			Timestamp: time.Now(),
+			MessageID: commandID,
+		},
+		Status:    status,
+		CommandID: commandID,
+		Action:    action,
+		Payload:   raw,
+		Error:     errMsg,
+	}, nil
+}
+
+// DecodePayload decodes the command payload into v, which must be a pointer
+// to the type registered for the action
+func (m *CommandMessage) DecodePayload(v interface{}) error {
+	return decodePayload(payloadTypes, m.Action, m.Payload, v)
+}
+
+// DecodePayload decodes the request payload into v, which must be a pointer
+// to the type registered for the action
+func (m *RequestMessage) DecodePayload(v interface{}) error {
+	return decodePayload(payloadTypes, m.Action, m.Payload, v)
+}
+
+// DecodePayload decodes the response payload into v, which must be a
+// pointer to the type registered for the action
+func (m *ResponseMessage) DecodePayload(v interface{}) error {
+	return decodePayload(responsePayloadTypes, m.Action, m.Payload, v)
+}
+
+// HasPayload reports whether the response carries a payload
+func (m *ResponseMessage) HasPayload() bool {
+	return len(m.Payload) > 0 && string(m.Payload) != "null"
+}
+
+// encodePayload checks the payload against the registry, validates it and
+// marshals it
+func encodePayload(registry map[CommandAction]reflect.Type, action CommandAction, payload interface{}) (json.RawMessage, error) {
+	if payload == nil {
+		if _, ok := registry[action]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownAction, action)
+		}
+		return nil, nil
+	}
+
+	if err := checkPayloadType(registry, action, payload); err != nil {
+		return nil, err
+	}
+	if err := validatePayload(action, payload); err != nil {
+		return nil, err
+	}
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s payload: %w", action, err)
+	}
+	return raw, nil
+}
+
+// decodePayload unmarshals a raw payload into v and validates it
+func decodePayload(registry map[CommandAction]reflect.Type, action CommandAction, raw json.RawMessage, v interface{}) error {
+	if reflect.TypeOf(v) == nil || reflect.TypeOf(v).Kind() != reflect.Pointer {
+		return fmt.Errorf("%w: decode target must be a pointer", ErrPayloadType)
+	}
+	if err := checkPayloadType(registry, action, v); err != nil {
+		return err
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return fmt.Errorf("%w: %s", ErrEmptyPayload, action)
+	}
+
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidPayload, action, err)
+	}
+	return validatePayload(action, v)
+}
+
+// checkPayloadType ensures the value, or the value it points to, has the
+// type registered for the action
+func checkPayloadType(registry map[CommandAction]reflect.Type, action CommandAction, v interface{}) error {
+	expected, ok := registry[action]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownAction, action)
+	}
+	if expected == nil {
+		return fmt.Errorf("%w: %s", ErrUnexpectedAction, action)
+	}
+
+	actual := reflect.TypeOf(v)
+	if actual.Kind() == reflect.Pointer {
+		actual = actual.Elem()
+	}
+	if actual != expected {
+		return fmt.Errorf("%w: %s expects %s, got %s", ErrPayloadType, action, expected, actual)
+	}
+	return nil
+}
+
+// validatePayload runs the payload's own validation, if any
+func validatePayload(action CommandAction, v interface{}) error {
+	validator, ok := v.(Validator)
+	if !ok {
+		// Value payloads implement Validator on the pointer receiver
+		ptr := reflect.New(reflect.TypeOf(v))
+		ptr.Elem().Set(reflect.ValueOf(v))
+		validator, ok = ptr.Interface().(Validator)
+	}
+	if !ok {
+		return nil
+	}
+	if err := validator.Validate(); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidPayload, action, err)
+	}
+	return nil
+}
+
+// Validate checks the download command payload
+func (p *DownloadFilePayload) Validate() error {
+	return p.UploadConfig.Validate()
+}
+
+// Validate checks the upload configuration
+func (c *UploadConfig) Validate() error {
+	if c.UploadID == "" {
+		return errors.New("upload_id is required")
+	}
+	if c.ChunkSize <= 0 {
+		return errors.New("chunk_size must be positive")
+	}
+	for _, presignedURL := range c.PresignedURLs {
+		if presignedURL.PartNumber < 1 || presignedURL.URL == "" {
+			return fmt.Errorf("invalid presigned URL for part %d", presignedURL.PartNumber)
+		}
+	}
+	return nil
+}
+
+// Validate checks the cancel payload
+func (p *CancelUploadPayload) Validate() error {
+	if p.UploadID == "" {
+		return errors.New("upload_id is required")
+	}
+	return nil
+}
+
+// Validate checks the part URLs request
+func (p *RequestPartURLsPayload) Validate() error {
+	if p.UploadID == "" {
+		return errors.New("upload_id is required")
+	}
+	if len(p.PartNumbers) == 0 {
+		return errors.New("part_numbers is required")
+	}
+	return nil
+}
+
+// Validate checks the resume request
+func (p *ResumeUploadPayload) Validate() error {
+	if p.UploadID == "" || p.S3UploadID == "" {
+		return errors.New("upload_id and s3_upload_id are required")
+	}
+	return nil
+}
+
+// Validate checks the download response
+func (p *DownloadFileResponse) Validate() error {
+	if p.UploadID == "" {
+		return errors.New("upload_id is required")
+	}
+	return nil
+}
+
+// Validate checks the stat response
+func (p *StatFileResponse) Validate() error {
+	if p.FileSize < 0 {
+		return errors.New("file_size must not be negative")
+	}
+	return nil
+}
+
+// Validate checks the resume response
+func (p *ResumeUploadResponse) Validate() error {
+	return p.UploadConfig.Validate()
+}
+
+// Validate checks the token refresh request
+func (p *RefreshTokenPayload) Validate() error {
+	if p.Token == "" {
+		return errors.New("token is required")
+	}
+	return nil
+}
+
+// Validate checks the token refresh response
+func (p *RefreshTokenResponse) Validate() error {
+	if p.Token == "" {
+		return errors.New("token is required")
+	}
+	return nil
+}
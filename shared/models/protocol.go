@@ -1,6 +1,13 @@
 package models
 
-import "time"
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"time"
+)
 
 // MessageType defines the type of WebSocket message
 type MessageType string
@@ -9,8 +16,19 @@ const (
 	MessageTypeCommand  MessageType = "command"
 	MessageTypeResponse MessageType = "response"
 	MessageTypeStatus   MessageType = "status"
+	MessageTypeRequest  MessageType = "request"
 	MessageTypePing     MessageType = "ping"
 	MessageTypePong     MessageType = "pong"
+	MessageTypeHello    MessageType = "hello"
+	MessageTypeHelloAck MessageType = "hello_ack"
+)
+
+const (
+	// ProtocolVersion is the protocol version spoken by this build
+	ProtocolVersion = 2
+
+	// MinProtocolVersion is the oldest protocol version still accepted
+	MinProtocolVersion = 1
 )
 
 // CommandAction defines the action to be performed by the client
@@ -20,6 +38,12 @@ const (
 	CommandActionDownloadFile CommandAction = "download_file"
 	CommandActionCancelUpload CommandAction = "cancel_upload"
 	CommandActionHealthCheck  CommandAction = "health_check"
+	CommandActionStatFile     CommandAction = "stat_file"
+
+	// Actions requested by the client and answered by the server
+	CommandActionRequestPartURLs CommandAction = "request_part_urls"
+	CommandActionResumeUpload    CommandAction = "resume_upload"
+	CommandActionRefreshToken    CommandAction = "refresh_token"
 )
 
 // ResponseStatus defines the status of a command execution
@@ -32,6 +56,46 @@ const (
 	ResponseStatusCancelled  ResponseStatus = "cancelled"
 )
 
+// ChecksumAlgorithm is the S3 additional checksum computed for each part
+type ChecksumAlgorithm string
+
+const (
+	ChecksumAlgorithmSHA256 ChecksumAlgorithm = "SHA256"
+	ChecksumAlgorithmCRC32C ChecksumAlgorithm = "CRC32C"
+)
+
+// IsValid reports whether the algorithm is one both sides can compute
+func (a ChecksumAlgorithm) IsValid() bool {
+	return a == ChecksumAlgorithmSHA256 || a == ChecksumAlgorithmCRC32C
+}
+
+// NewHash returns a hash computing the algorithm's checksum the way S3 does
+func (a ChecksumAlgorithm) NewHash() (hash.Hash, error) {
+	switch a {
+	case ChecksumAlgorithmSHA256:
+		return sha256.New(), nil
+	case ChecksumAlgorithmCRC32C:
+		return crc32.New(castagnoli), nil
+	}
+	return nil, fmt.Errorf("unsupported checksum algorithm %q", a)
+}
+
+// castagnoli is the CRC32C table S3 uses
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrorCode classifies an error response so the server can act on it
+// without parsing the message; most errors carry none
+type ErrorCode string
+
+const (
+	ErrorCodeForbiddenPath ErrorCode = "forbidden_path" // The client's allowlist refuses the file
+	ErrorCodeFileTooLarge  ErrorCode = "file_too_large" // The file is over the client's size limit
+
+	// ErrorCodeChecksumMismatch means the data uploaded doesn't match the
+	// checksums the file was stat'ed with, e.g. it changed mid-upload
+	ErrorCodeChecksumMismatch ErrorCode = "checksum_mismatch"
+)
+
 // WebSocketMessage is the base message structure for WebSocket communication
 type WebSocketMessage struct {
 	Type      MessageType `json:"type"`
@@ -42,8 +106,8 @@ type WebSocketMessage struct {
 // CommandMessage is sent from server to client
 type CommandMessage struct {
 	WebSocketMessage
-	Action  CommandAction `json:"action"`
-	Payload interface{}   `json:"payload"`
+	Action  CommandAction   `json:"action"`
+	Payload json.RawMessage `json:"payload,omitempty"` // See NewCommandMessage and DecodePayload
 }
 
 // DownloadFilePayload contains the details for a download command
@@ -53,30 +117,107 @@ type DownloadFilePayload struct {
 	Metadata     map[string]string `json:"metadata,omitempty"`
 }
 
+// CancelUploadPayload identifies the upload a cancel_upload command targets
+type CancelUploadPayload struct {
+	UploadID string `json:"upload_id"`
+}
+
+// StatFilePayload asks the client to describe a file before an upload is
+// initiated; with a checksum algorithm, the client also hashes the file and
+// checksums each ChunkSize part of it
+type StatFilePayload struct {
+	FilePath          string            `json:"file_path"`
+	ComputeHash       bool              `json:"compute_hash,omitempty"`
+	ChunkSize         int64             `json:"chunk_size,omitempty"`
+	ChecksumAlgorithm ChecksumAlgorithm `json:"checksum_algorithm,omitempty"`
+}
+
 // UploadConfig contains S3 upload configuration
 type UploadConfig struct {
 	UploadID      string         `json:"upload_id"`
+	S3UploadID    string         `json:"s3_upload_id,omitempty"` // Kept by the client so the upload can be resumed
 	Bucket        string         `json:"bucket"`
 	Key           string         `json:"key"`
 	Region        string         `json:"region"`
 	ChunkSize     int64          `json:"chunk_size"`
-	PresignedURLs []PresignedURL `json:"presigned_urls"`
+	FileSize      int64          `json:"file_size,omitempty"` // Size reported by stat_file, used to detect changes
+	TotalParts    int            `json:"total_parts,omitempty"`
+	PresignedURLs []PresignedURL `json:"presigned_urls"` // Initial window, the rest is fetched with request_part_urls
+
+	// ChecksumAlgorithm is set when every part must be sent with the
+	// checksum its presigned URL carries
+	ChecksumAlgorithm ChecksumAlgorithm `json:"checksum_algorithm,omitempty"`
 }
 
 // PresignedURL contains a presigned URL for uploading a specific part
 type PresignedURL struct {
-	PartNumber int    `json:"part_number"`
-	URL        string `json:"url"`
+	PartNumber int       `json:"part_number"`
+	URL        string    `json:"url"`
+	ExpiresAt  time.Time `json:"expires_at,omitempty"`
+	Checksum   string    `json:"checksum,omitempty"` // Base64 checksum the part was stat'ed with, signed into the URL
+}
+
+// RequestMessage is sent from client to server when the client needs
+// something from the server; it is answered with a ResponseMessage whose
+// CommandID is the request's MessageID
+type RequestMessage struct {
+	WebSocketMessage
+	Action  CommandAction   `json:"action"`
+	Payload json.RawMessage `json:"payload,omitempty"` // See NewRequestMessage and DecodePayload
+}
+
+// RequestPartURLsPayload asks the server to presign URLs for the given parts
+type RequestPartURLsPayload struct {
+	UploadID    string `json:"upload_id"`
+	PartNumbers []int  `json:"part_numbers"`
+}
+
+// PartURLsResponse is the payload of the reply to a request_part_urls request
+type PartURLsResponse struct {
+	UploadID      string         `json:"upload_id"`
+	PresignedURLs []PresignedURL `json:"presigned_urls"`
 }
 
-// ResponseMessage is sent from client to server
+// ResumeUploadPayload asks the server to continue an upload the client
+// journaled before a restart or disconnect
+type ResumeUploadPayload struct {
+	UploadID       string         `json:"upload_id"`
+	S3UploadID     string         `json:"s3_upload_id"`
+	FilePath       string         `json:"file_path"`
+	FileSize       int64          `json:"file_size"`
+	ModTime        time.Time      `json:"mod_time"`
+	CompletedParts map[int]string `json:"completed_parts,omitempty"` // part number -> ETag, as journaled
+}
+
+// ResumeUploadResponse is the payload of the reply to a resume_upload request
+type ResumeUploadResponse struct {
+	FilePath       string         `json:"file_path"`
+	UploadConfig   UploadConfig   `json:"upload_config"`             // Presigned URLs cover missing parts only
+	CompletedParts map[int]string `json:"completed_parts,omitempty"` // part number -> ETag, as listed by S3
+}
+
+// RefreshTokenPayload asks the server to replace the client's token before
+// it expires
+type RefreshTokenPayload struct {
+	Token string `json:"token"`
+}
+
+// RefreshTokenResponse is the payload of the reply to a refresh_token request
+type RefreshTokenResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ResponseMessage is sent from client to server in reply to a command, and
+// from server to client in reply to a request
 type ResponseMessage struct {
 	WebSocketMessage
-	Status    ResponseStatus `json:"status"`
-	CommandID string         `json:"command_id,omitempty"`
-	Action    CommandAction  `json:"action,omitempty"`
-	Payload   interface{}    `json:"payload,omitempty"`
-	Error     string         `json:"error,omitempty"`
+	Status    ResponseStatus  `json:"status"`
+	CommandID string          `json:"command_id,omitempty"`
+	Action    CommandAction   `json:"action,omitempty"`
+	Payload   json.RawMessage `json:"payload,omitempty"` // See NewResponseMessage and DecodePayload
+	Error     string          `json:"error,omitempty"`
+	ErrorCode ErrorCode       `json:"error_code,omitempty"`
 }
 
 // DownloadFileResponse is the payload for a download file response
@@ -89,7 +230,26 @@ type DownloadFileResponse struct {
 	StartTime      time.Time      `json:"start_time,omitempty"`
 	EndTime        time.Time      `json:"end_time,omitempty"`
 	S3Key          string         `json:"s3_key,omitempty"`
-	ETags          map[int]string `json:"etags,omitempty"` // part number -> ETag
+	ETags          map[int]string `json:"etags,omitempty"`     // part number -> ETag
+	Checksums      map[int]string `json:"checksums,omitempty"` // part number -> base64 checksum of the data sent
+}
+
+// StatFileResponse is the payload for a stat file response
+type StatFileResponse struct {
+	FilePath string    `json:"file_path"`
+	FileSize int64     `json:"file_size"`
+	ModTime  time.Time `json:"mod_time"`
+	SHA256   string    `json:"sha256,omitempty"` // Hex-encoded, only when requested
+
+	// PartChecksums are the base64 checksums of each part, first part
+	// first, when a checksum algorithm was requested
+	PartChecksums []string `json:"part_checksums,omitempty"`
+}
+
+// HealthCheckResponse is the payload for a health check response
+type HealthCheckResponse struct {
+	Status    string    `json:"status"`
+	Timestamp time.Time `json:"timestamp"`
 }
 
 // StatusMessage is sent periodically from client to server for progress updates
@@ -112,7 +272,9 @@ type UploadStatus struct {
 	Progress       float64        `json:"progress"`
 	StartTime      time.Time      `json:"start_time"`
 	LastUpdate     time.Time      `json:"last_update"`
-	ETags          map[int]string `json:"etags,omitempty"` // part number -> ETag
+	ETags          map[int]string `json:"etags,omitempty"`      // part number -> ETag
+	Retries        int            `json:"retries,omitempty"`    // Part retries so far
+	LastError      string         `json:"last_error,omitempty"` // Most recent transient failure
 }
 
 // SystemInfo contains system information from the client
@@ -125,6 +287,63 @@ type SystemInfo struct {
 	DiskUsage    float64 `json:"disk_usage,omitempty"`
 }
 
+// ClientCapabilities describes what a client build can do
+type ClientCapabilities struct {
+	ProtocolVersion  int               `json:"protocol_version"`
+	ClientBuild      string            `json:"client_build,omitempty"`
+	SupportedActions []CommandAction   `json:"supported_actions"`
+	MaxConcurrency   int               `json:"max_concurrency,omitempty"`
+	Compression      bool              `json:"compression"`
+	Labels           map[string]string `json:"labels,omitempty"` // Operator-defined, e.g. region=eu; used to select clients
+}
+
+// HasLabels reports whether the client carries every given label
+func (c ClientCapabilities) HasLabels(labels map[string]string) bool {
+	for key, value := range labels {
+		if c.Labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Supports reports whether the client can handle the given action
+func (c ClientCapabilities) Supports(action CommandAction) bool {
+	for _, supported := range c.SupportedActions {
+		if supported == action {
+			return true
+		}
+	}
+	return false
+}
+
+// LegacyCapabilities returns the capabilities assumed for clients that
+// connect without sending a hello
+func LegacyCapabilities() ClientCapabilities {
+	return ClientCapabilities{
+		ProtocolVersion: 1,
+		SupportedActions: []CommandAction{
+			CommandActionDownloadFile,
+			CommandActionCancelUpload,
+			CommandActionHealthCheck,
+		},
+	}
+}
+
+// HelloMessage is sent by the client right after connecting
+type HelloMessage struct {
+	WebSocketMessage
+	ClientCapabilities
+}
+
+// HelloAckMessage answers a hello with the negotiated protocol version
+type HelloAckMessage struct {
+	WebSocketMessage
+	ProtocolVersion int    `json:"protocol_version"`
+	Accepted        bool   `json:"accepted"`
+	Error           string `json:"error,omitempty"`
+}
+
 // PingMessage is sent to keep the connection alive
 type PingMessage struct {
 	WebSocketMessage